@@ -0,0 +1,362 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"GophKeeper.ru/internal/client/gcrypto"
+	"GophKeeper.ru/internal/entities"
+	"golang.org/x/exp/slog"
+)
+
+// blobChunkSize размер фрагмента файла до шифрования
+const blobChunkSize = 1 << 20
+
+// Upload - загрузка большого файла фрагментами. Запись сохраняется до
+// загрузки, поэтому прерванную загрузку продолжает повтор команды.
+func (g *GophKeeper) Upload(name, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return fmt.Errorf("%s — каталог", path)
+	}
+
+	// Контрольная сумма считается потоком, файл целиком в память не читается
+	h := sha256.New()
+	if _, err = io.Copy(h, f); err != nil {
+		return err
+	}
+	sum := hex.EncodeToString(h.Sum(nil))
+
+	size := info.Size()
+	chunks := int((size + blobChunkSize - 1) / blobChunkSize)
+	if chunks == 0 {
+		chunks = 1
+	}
+
+	secret, ok := g.find(name)
+	if !ok {
+		found, err := g.lookup(name)
+		if err != nil {
+			return err
+		}
+		if found != nil {
+			secret, ok = *found, true
+		}
+	}
+
+	var ref *entities.BlobRef
+	received := map[int]bool{}
+	if ok && secret.Type == entities.TypeBlob {
+		var prev entities.BlobRef
+		if json.Unmarshal([]byte(secret.Value), &prev) == nil {
+			// Тот же файл — продолжаем прерванную загрузку
+			if prev.SHA256 == sum && prev.Size == size {
+				var status entities.Blob
+				if err = g.getJSON("/api/blobs/"+prev.ID, &status); err == nil {
+					if status.Complete {
+						fmt.Println("Файл уже загружен")
+						return nil
+					}
+					ref = &prev
+					for _, n := range status.Received {
+						received[n] = true
+					}
+					fmt.Printf("Продолжаем загрузку, уже загружено фрагментов: %d из %d\n", len(received), ref.Chunks)
+				}
+			}
+		}
+	}
+
+	if ref == nil {
+		key, err := gcrypto.NewBlobKey()
+		if err != nil {
+			return err
+		}
+
+		// ID записи нужен заранее: по нему сервер удалит фрагменты вместе с записью
+		if !ok {
+			id, err := gcrypto.NewRecordID()
+			if err != nil {
+				return err
+			}
+			secret = entities.Secret{ID: id, Name: name}
+		}
+
+		var b entities.Blob
+		err = g.postJSON("/api/blobs", entities.Blob{
+			Record: secret.ID,
+			Size:   size + int64(chunks)*gcrypto.ChunkOverhead,
+			Chunks: chunks,
+		}, &b)
+		if err != nil {
+			slog.Error(err.Error(), "method:", "func (g *GophKeeper) Upload(name, path string) error")
+			return err
+		}
+
+		ref = &entities.BlobRef{
+			ID:        b.ID,
+			FileName:  filepath.Base(path),
+			Size:      size,
+			ChunkSize: blobChunkSize,
+			Chunks:    chunks,
+			SHA256:    sum,
+			Key:       key,
+		}
+		value, err := json.Marshal(ref)
+		if err != nil {
+			return err
+		}
+		secret.Type = entities.TypeBlob
+		secret.Value = string(value)
+		if err = g.saveSecret(&secret); err != nil {
+			return err
+		}
+	}
+
+	buf := make([]byte, ref.ChunkSize)
+	for n := 0; n < ref.Chunks; n++ {
+		if received[n] {
+			continue
+		}
+
+		k, err := f.ReadAt(buf, int64(n)*int64(ref.ChunkSize))
+		if err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+
+		sealed, err := gcrypto.SealChunk(ref.Key, ref.ID, n, ref.Chunks, buf[:k])
+		if err != nil {
+			return err
+		}
+
+		if err = g.putChunk(ref.ID, n, sealed); err != nil {
+			return fmt.Errorf("загрузка прервана, повторите команду: %w", err)
+		}
+		received[n] = true
+		fmt.Printf("Загружено фрагментов: %d из %d\n", len(received), ref.Chunks)
+	}
+
+	if err = g.postJSON("/api/blobs/"+ref.ID+"/complete", nil, nil); err != nil {
+		slog.Error(err.Error(), "method:", "func (g *GophKeeper) Upload(name, path string) error")
+		return err
+	}
+
+	// Прежний файл остаётся в истории записи, сервер удалит его вместе с версией
+	fmt.Printf("Файл %s загружен в запись %s\n", ref.FileName, name)
+	return nil
+}
+
+// Download - выгрузка большого файла фрагментами в path. Фрагменты
+// дописываются в path.part, прерванную выгрузку продолжает повтор команды.
+func (g *GophKeeper) Download(name, path string) error {
+	secret, err := g.lookup(name)
+	if err != nil {
+		return err
+	}
+	if secret == nil {
+		return fmt.Errorf("запись %s не найдена", name)
+	}
+
+	return g.downloadBlob(secret, path)
+}
+
+// downloadBlob выгружает и расшифровывает фрагменты записи, проверяя
+// контрольную сумму файла целиком
+func (g *GophKeeper) downloadBlob(secret *entities.Secret, path string) error {
+	if secret.Type != entities.TypeBlob {
+		return fmt.Errorf("запись %s не содержит файла", secret.Name)
+	}
+
+	var ref entities.BlobRef
+	if err := json.Unmarshal([]byte(secret.Value), &ref); err != nil || ref.ChunkSize <= 0 {
+		return fmt.Errorf("запись %s повреждена", secret.Name)
+	}
+
+	part := path + ".part"
+	f, err := os.OpenFile(part, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	// Уже выгруженные целые фрагменты не запрашиваются повторно
+	done := int(info.Size() / int64(ref.ChunkSize))
+	if done > ref.Chunks {
+		done = 0
+	}
+	offset := int64(done) * int64(ref.ChunkSize)
+	if err = f.Truncate(offset); err != nil {
+		return err
+	}
+
+	h := sha256.New()
+	if _, err = io.Copy(h, io.LimitReader(f, offset)); err != nil {
+		return err
+	}
+	if _, err = f.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+
+	for n := done; n < ref.Chunks; n++ {
+		sealed, err := g.getChunk(ref.ID, n, ref.ChunkSize+gcrypto.ChunkOverhead)
+		if err != nil {
+			return fmt.Errorf("выгрузка прервана, повторите команду: %w", err)
+		}
+
+		plain, err := gcrypto.OpenChunk(ref.Key, ref.ID, n, ref.Chunks, sealed)
+		if err != nil {
+			return err
+		}
+
+		if _, err = f.Write(plain); err != nil {
+			return err
+		}
+		h.Write(plain)
+		fmt.Printf("Выгружено фрагментов: %d из %d\n", n+1, ref.Chunks)
+	}
+
+	if hex.EncodeToString(h.Sum(nil)) != ref.SHA256 {
+		f.Close()
+		os.Remove(part)
+		return errors.New("контрольная сумма файла не совпала, файл удалён")
+	}
+
+	if err = f.Close(); err != nil {
+		return err
+	}
+	if err = os.Rename(part, path); err != nil {
+		return err
+	}
+
+	fmt.Printf("Файл %s сохранён в %s\n", ref.FileName, path)
+	return nil
+}
+
+// putChunk отправляет зашифрованный фрагмент
+func (g *GophKeeper) putChunk(id string, n int, sealed []byte) error {
+	req, err := http.NewRequest(http.MethodPost,
+		fmt.Sprintf("https://%s/api/blobs/%s/chunks/%d", g.addr, id, n), bytes.NewReader(sealed))
+	if err != nil {
+		slog.Error(err.Error(), "method:", "func (g *GophKeeper) putChunk(id string, n int, sealed []byte) error")
+		return err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+
+	resp, err := g.Client.Do(req)
+	if err != nil {
+		slog.Error(err.Error(), "method:", "func (g *GophKeeper) putChunk(id string, n int, sealed []byte) error")
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		err = apiError(resp)
+		slog.Error(err.Error(), "method:", "func (g *GophKeeper) putChunk(id string, n int, sealed []byte) error")
+		return err
+	}
+
+	return nil
+}
+
+// getChunk получает зашифрованный фрагмент не длиннее limit байт
+func (g *GophKeeper) getChunk(id string, n, limit int) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet,
+		fmt.Sprintf("https://%s/api/blobs/%s/chunks/%d", g.addr, id, n), nil)
+	if err != nil {
+		slog.Error(err.Error(), "method:", "func (g *GophKeeper) getChunk(id string, n, limit int) ([]byte, error)")
+		return nil, err
+	}
+
+	resp, err := g.Client.Do(req)
+	if err != nil {
+		slog.Error(err.Error(), "method:", "func (g *GophKeeper) getChunk(id string, n, limit int) ([]byte, error)")
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, apiError(resp)
+	}
+
+	sealed, err := io.ReadAll(io.LimitReader(resp.Body, int64(limit)+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) > limit {
+		return nil, fmt.Errorf("фрагмент %d больше ожидаемого", n)
+	}
+
+	return sealed, nil
+}
+
+// linkBlobs сообщает серверу, какие записи ссылаются на файлы, загруженные
+// до того, как сервер стал хранить эти ссылки. Без связи такие файлы не
+// удаляются ни вместе с записью, ни после замены. Записи корзины
+// проверяются один раз за сеанс, несвязанные записи — после следующего
+// изменения хранилища.
+func (g *GophKeeper) linkBlobs() {
+	g.mu.Lock()
+	links := g.unlinked
+	g.unlinked = nil
+	checkTrash := !g.trashLinked
+	g.mu.Unlock()
+
+	if checkTrash {
+		if items, err := g.fetchTrash(); err == nil {
+			if links == nil {
+				links = map[string]string{}
+			}
+			for _, t := range items {
+				if ref := blobOf(&t.Secret); ref != nil && t.item.Blob != ref.ID {
+					links[t.ID] = ref.ID
+				}
+			}
+
+			g.mu.Lock()
+			g.trashLinked = true
+			g.mu.Unlock()
+		}
+	}
+
+	for record, blob := range links {
+		if err := g.postJSON("/api/blobs/"+blob+"/link", map[string]string{"record": record}, nil); err != nil {
+			slog.Warn("файл записи "+record+" не связан с ней: "+err.Error(), "method:", "func (g *GophKeeper) linkBlobs()")
+		}
+	}
+}
+
+// blobOf ссылка на фрагменты записи, nil — запись не двоичная
+func blobOf(secret *entities.Secret) *entities.BlobRef {
+	if secret.Type != entities.TypeBlob {
+		return nil
+	}
+
+	var ref entities.BlobRef
+	if json.Unmarshal([]byte(secret.Value), &ref) != nil {
+		return nil
+	}
+
+	return &ref
+}
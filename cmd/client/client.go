@@ -13,7 +13,9 @@ import (
 	"net/http/cookiejar"
 	"os"
 	"strconv"
+	"sync"
 	"time"
+	"unicode/utf8"
 
 	"GophKeeper.ru/internal/client/gcrypto"
 	"GophKeeper.ru/internal/entities"
@@ -26,30 +28,51 @@ type GophKeeper struct {
 	user        string
 	pass        string
 	addr        string
-	key         string
-	data        []entities.Record
+	key         string // ключ хранилища, известен после unlock
+	legacyKey   string // прежний -k для записей старых форматов, пуст, если их нет
+	private     string // закрытый ключ X25519 для обмена записями
+	data        []entities.Secret
 	countUpdate int
+	legacy      []entities.Secret // записи старых форматов, ждут перешифровки
+	unlinked    map[string]string // ID записи → ID её файла, о связи которых сервер не знает
+	trashLinked bool              // файлы записей корзины связаны в этом сеансе
+	manifest    manifestState
+	mu          sync.RWMutex // запись на сервер не пересекается с синхронизацией, g.data читается под RLock
 }
 
 // NewGophKeeper - конструктор
-func NewGophKeeper(user, pass, addr, secret_key, certFile string) (*GophKeeper, error) {
-	cert, err := os.ReadFile(certFile)
+func NewGophKeeper(cfg *Config) (*GophKeeper, error) {
+	cert, err := os.ReadFile(cfg.CryptoKey)
 	if err != nil {
 		return nil, err
 	}
 
 	certPool := x509.NewCertPool()
 	if ok := certPool.AppendCertsFromPEM(cert); !ok {
-		log.Fatalf("unable to parse cert from %s", certFile)
+		log.Fatalf("unable to parse cert from %s", cfg.CryptoKey)
 		return nil, err
 	}
 
+	tlsConfig := &tls.Config{
+		RootCAs: certPool,
+	}
+
+	// Клиентский сертификат для mTLS
+	if cfg.ClientCert != "" {
+		pair, err := tls.LoadX509KeyPair(cfg.ClientCert, cfg.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("не удалось загрузить сертификат клиента: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{pair}
+	}
+
 	gophKeeper := GophKeeper{
-		addr: addr,
-		user: user,
-		pass: pass,
-		key:  secret_key,
+		addr: cfg.AddrServer,
+		user: cfg.Username,
+		pass: cfg.Password,
 	}
+	gophKeeper.manifest.store = manifestStore{path: cfg.StateFile, account: cfg.Username + "@" + cfg.AddrServer}
+	gophKeeper.loadManifest()
 
 	jar, err := cookiejar.New(nil)
 	if err != nil {
@@ -58,9 +81,7 @@ func NewGophKeeper(user, pass, addr, secret_key, certFile string) (*GophKeeper,
 
 	gophKeeper.Client = &http.Client{
 		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{
-				RootCAs: certPool,
-			},
+			TLSClientConfig: tlsConfig,
 		},
 		Jar: jar,
 	}
@@ -90,96 +111,343 @@ func (g *GophKeeper) auth() error {
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != 200 {
+	if resp.StatusCode == http.StatusAccepted {
+		var challenge struct {
+			MFAToken string `json:"mfa_token"`
+		}
+		if err = json.NewDecoder(resp.Body).Decode(&challenge); err != nil {
+			slog.Error(err.Error(), "method:", "func (g *GophKeeper) auth() error")
+			return err
+		}
+
+		if err = g.authSecondFactor(challenge.MFAToken); err != nil {
+			return err
+		}
+	} else if resp.StatusCode == http.StatusTooManyRequests {
+		err = fmt.Errorf("слишком много попыток входа, повторите через %s с", resp.Header.Get("Retry-After"))
+		slog.Error(err.Error(), "method:", "func (g *GophKeeper) auth() error")
+		return err
+	} else if resp.StatusCode != 200 {
 		err = fmt.Errorf("%v", resp.StatusCode)
 		slog.Error(err.Error(), "method:", "func (g *GophKeeper) auth() error")
 		return err
 	}
 
+	// Дальше сессия продлевается refresh-токеном, пароль больше не нужен
+	g.pass = ""
+
 	return nil
 }
 
-// UpdateRecord - добавление\обновление данных
-func (g *GophKeeper) UpdateRecord(key, value string) error {
-
-	eKey, err := gcrypto.Encrypt(key, g.key, "GophKeeper")
+// refresh - продление сессии по refresh-токену из cookie
+func (g *GophKeeper) refresh() error {
+	req, err := http.NewRequest(http.MethodPost, "https://"+g.addr+"/api/auth/refresh", nil)
 	if err != nil {
-		err = errors.New("шифрование ключа завершилось с ошибкой")
-		slog.Error(err.Error(), "method:", "func (g *GophKeeper) UpdateRecord(key, value string) error")
+		slog.Error(err.Error(), "method:", "func (g *GophKeeper) refresh() error")
 		return err
 	}
 
-	eValue, err := gcrypto.Encrypt(value, g.key, "GophKeeper")
+	resp, err := g.Client.Do(req)
 	if err != nil {
-		err = errors.New("шифрование значения завершилось с ошибкой")
-		slog.Error(err.Error(), "method:", "func (g *GophKeeper) UpdateRecord(key, value string) error")
+		slog.Error(err.Error(), "method:", "func (g *GophKeeper) refresh() error")
 		return err
 	}
+	defer resp.Body.Close()
 
-	record := entities.Record{
-		Key:   eKey,
-		Value: eValue,
+	if resp.StatusCode != http.StatusOK {
+		err = fmt.Errorf("сессия завершена, требуется повторный вход: %v", resp.StatusCode)
+		slog.Error(err.Error(), "method:", "func (g *GophKeeper) refresh() error")
+		return err
 	}
 
-	data, err := json.Marshal(record)
-	if err != nil {
-		slog.Error(err.Error(), "method:", "func (g *GophKeeper) UpdateRecord(key, value string) error")
+	return nil
+}
+
+// register - регистрация нового пользователя
+func (g *GophKeeper) register() error {
+	if len(g.pass) < 8 {
+		return errors.New("пароль должен содержать не менее 8 символов")
+	}
+
+	user := entities.User{
+		Login:    g.user,
+		Password: utils.Sha256hash(g.pass),
+	}
+
+	if err := user.Validate(); err != nil {
 		return err
 	}
 
-	body := bytes.NewReader(data)
-	req, err := http.NewRequest(http.MethodPost, "https://"+g.addr+"/api/data", body)
+	jsonValue, _ := json.Marshal(user)
+	req, err := http.NewRequest(http.MethodPost, "https://"+g.addr+"/api/register", bytes.NewBuffer(jsonValue))
 	if err != nil {
-		slog.Error(err.Error(), "method:", "func (g *GophKeeper) UpdateRecord(key, value string) error")
+		slog.Error(err.Error(), "method:", "func (g *GophKeeper) register() error")
 		return err
 	}
 
-	_, err = g.Client.Do(req)
+	resp, err := g.Client.Do(req)
 	if err != nil {
-		slog.Error(err.Error(), "method:", "func (g *GophKeeper) UpdateRecord(key, value string) error")
+		slog.Error(err.Error(), "method:", "func (g *GophKeeper) register() error")
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusConflict {
+		return entities.ErrUserExists
+	}
+
+	if resp.StatusCode != http.StatusCreated {
+		err = fmt.Errorf("%v", resp.StatusCode)
+		slog.Error(err.Error(), "method:", "func (g *GophKeeper) register() error")
 		return err
 	}
 
 	return nil
 }
 
-// Remove - удаляет данные их хранилища
-func (g *GophKeeper) Remove(key string) error {
+// UpdateRecord - добавление\обновление данных
+func (g *GophKeeper) UpdateRecord(key, value string) error {
+	return g.updateSecret(key, "", value)
+}
 
-	eKey, err := gcrypto.Encrypt(key, g.key, "GophKeeper")
+// updateSecret - добавление\обновление записи заданного типа
+func (g *GophKeeper) updateSecret(key, typ, value string) error {
+	// Метаданные существующей записи сохраняются
+	secret, ok := g.find(key)
+	if !ok {
+		// Запись могла появиться с другого устройства после синхронизации
+		found, err := g.lookup(key)
+		if err != nil {
+			return err
+		}
+		if found != nil {
+			secret, ok = *found, true
+		}
+	}
+	if !ok {
+		id, err := gcrypto.NewRecordID()
+		if err != nil {
+			slog.Error(err.Error(), "method:", "func (g *GophKeeper) updateSecret(key, typ, value string) error")
+			return err
+		}
+		secret = entities.Secret{ID: id, Name: key}
+	}
+	secret.Type = typ
+	secret.Value = value
+
+	return g.saveSecret(&secret)
+}
+
+// saveSecret шифрует запись целиком, сохраняет её на сервере
+// и отмечает новую версию в манифесте
+func (g *GophKeeper) saveSecret(secret *entities.Secret) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	record, err := g.putSecret(secret)
+	if err != nil {
+		return err
+	}
+
+	if g.manifest.ready {
+		g.manifest.entries[secret.ID] = gcrypto.ManifestEntry{Name: secret.Name, Version: gcrypto.RecordVersion(record)}
+	}
+	return g.writeManifest()
+}
+
+// putSecret шифрует запись целиком и сохраняет её на сервере
+func (g *GophKeeper) putSecret(secret *entities.Secret) (*entities.Record, error) {
+	record, err := gcrypto.EnecryptRecord(secret, g.key)
 	if err != nil {
-		slog.Error(err.Error(), "method:", "func (g *GophKeeper) Remove(key string) error")
-		return errors.New("шифрование ключа завершилось с ошибкой")
+		err = errors.New("шифрование записи завершилось с ошибкой")
+		slog.Error(err.Error(), "method:", "func (g *GophKeeper) putSecret(secret *entities.Secret) (*entities.Record, error)")
+		return nil, err
+	}
+	if ref := blobOf(secret); ref != nil {
+		record.Blob = ref.ID
 	}
 
+	data, err := json.Marshal(record)
+	if err != nil {
+		slog.Error(err.Error(), "method:", "func (g *GophKeeper) putSecret(secret *entities.Secret) (*entities.Record, error)")
+		return nil, err
+	}
+
+	body := bytes.NewReader(data)
+	req, err := http.NewRequest(http.MethodPost, "https://"+g.addr+"/api/data", body)
+	if err != nil {
+		slog.Error(err.Error(), "method:", "func (g *GophKeeper) putSecret(secret *entities.Secret) (*entities.Record, error)")
+		return nil, err
+	}
+
+	resp, err := g.Client.Do(req)
+	if err != nil {
+		slog.Error(err.Error(), "method:", "func (g *GophKeeper) putSecret(secret *entities.Secret) (*entities.Record, error)")
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		err = apiError(resp)
+		slog.Error(err.Error(), "method:", "func (g *GophKeeper) putSecret(secret *entities.Secret) (*entities.Record, error)")
+		return nil, err
+	}
+
+	return record, nil
+}
+
+// Remove - перемещает запись в корзину. Фрагменты большого файла
+// остаются на сервере до окончательного удаления записи.
+func (g *GophKeeper) Remove(key string) error {
+	secret, ok := g.find(key)
+	if !ok {
+		return fmt.Errorf("запись %s не найдена", key)
+	}
+
+	return g.removeRecord(secret.ID)
+}
+
+// removeRecord перемещает запись по ID в корзину и убирает её из манифеста
+func (g *GophKeeper) removeRecord(id string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
 	record := entities.Record{
-		Key: eKey,
+		Key: id,
 	}
 
 	data, err := json.Marshal(record)
 	if err != nil {
-		slog.Error(err.Error(), "method:", "func (g *GophKeeper) Remove(key string) error")
+		slog.Error(err.Error(), "method:", "func (g *GophKeeper) removeRecord(id string) error")
 		return err
 	}
 
 	body := bytes.NewReader(data)
 	req, err := http.NewRequest(http.MethodDelete, "https://"+g.addr+"/api/data", body)
 	if err != nil {
-		slog.Error(err.Error(), "method:", "func (g *GophKeeper) Remove(key string) error")
+		slog.Error(err.Error(), "method:", "func (g *GophKeeper) removeRecord(id string) error")
+		return err
+	}
+
+	resp, err := g.Client.Do(req)
+	if err != nil {
+		slog.Error(err.Error(), "method:", "func (g *GophKeeper) removeRecord(id string) error")
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		err = apiError(resp)
+		slog.Error(err.Error(), "method:", "func (g *GophKeeper) removeRecord(id string) error")
 		return err
 	}
 
-	_, err = g.Client.Do(req)
+	delete(g.manifest.entries, id)
+	return g.writeManifest()
+}
+
+// Get - вывод одной записи, запрошенной с сервера по слепому индексу.
+// Двоичная запись сохраняется в файл path.
+func (g *GophKeeper) Get(name, path string) error {
+	secret, err := g.lookup(name)
 	if err != nil {
-		slog.Error(err.Error(), "method:", "func (g *GophKeeper) Remove(key string) error")
 		return err
 	}
+	if secret == nil {
+		return fmt.Errorf("запись %s не найдена", name)
+	}
 
+	if secret.Type == entities.TypeBinary && path != "" {
+		return saveFile(secret, path)
+	}
+
+	if secret.Type == entities.TypeBlob && path != "" {
+		return g.downloadBlob(secret, path)
+	}
+
+	fmt.Println(formatSecret(secret, true))
 	return nil
 }
 
+// lookup - поиск записи на сервере по имени через слепой индекс, nil — не найдена
+func (g *GophKeeper) lookup(name string) (*entities.Secret, error) {
+	index, err := gcrypto.BlindIndex(name, g.key)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodGet, "https://"+g.addr+"/api/data/"+index, nil)
+	if err != nil {
+		slog.Error(err.Error(), "method:", "func (g *GophKeeper) lookup(name string) (*entities.Secret, error)")
+		return nil, err
+	}
+
+	resp, err := g.Client.Do(req)
+	if err != nil {
+		slog.Error(err.Error(), "method:", "func (g *GophKeeper) lookup(name string) (*entities.Secret, error)")
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, apiError(resp)
+	}
+
+	var record entities.Record
+	if err = json.NewDecoder(resp.Body).Decode(&record); err != nil {
+		return nil, err
+	}
+
+	secret, err := gcrypto.DecryptRecord(&record, g.key)
+	if err != nil {
+		return nil, err
+	}
+
+	// Индекс не защищён шифрованием, сервер мог вернуть другую запись
+	if secret.Name != name {
+		return nil, fmt.Errorf("сервер вернул чужую запись для %s", name)
+	}
+
+	return secret, nil
+}
+
+// find - поиск записи по имени
+func (g *GophKeeper) find(name string) (entities.Secret, bool) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	for _, secret := range g.data {
+		if secret.Name == name {
+			return secret, true
+		}
+	}
+
+	return entities.Secret{}, false
+}
+
+// findID - поиск записи по ID
+func (g *GophKeeper) findID(id string) (entities.Secret, bool) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	for _, secret := range g.data {
+		if secret.ID == id {
+			return secret, true
+		}
+	}
+
+	return entities.Secret{}, false
+}
+
 // sync - синхронзиация с сервером
 func (g *GophKeeper) sync() (int, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
 	req, err := http.NewRequest(http.MethodGet, "https://"+g.addr+"/api/data?update="+strconv.Itoa(g.countUpdate), nil)
 	if err != nil {
 		slog.Error(err.Error(), "method:", "(g *GophKeeper) sync() (int, error)")
@@ -227,43 +495,211 @@ func (g *GophKeeper) applyUpdate(update entities.Update) bool {
 		return false
 	}
 
-	newData := make([]entities.Record, len(update.Data))
+	newData := make([]entities.Secret, 0, len(update.Data))
+	versions := make(map[string]string, len(update.Data))
+	var (
+		legacy   []entities.Secret
+		manifest *entities.Secret
+	)
+	unlinked := map[string]string{}
 
-	for idx, record := range update.Data {
-		key, err := gcrypto.Decrypt(record.Key, g.key, "GophKeeper")
+	accept := func(record *entities.Record, secret *entities.Secret) {
+		versions[secret.ID] = gcrypto.RecordVersion(record)
+		newData = append(newData, *secret)
+		if gcrypto.IsLegacyRecord(record) {
+			legacy = append(legacy, *secret)
+		}
+		if ref := blobOf(secret); ref != nil && record.Blob != ref.ID {
+			unlinked[secret.ID] = ref.ID
+		}
+	}
+
+	// Записи старых форматов проверяются по манифесту, поэтому открываются
+	// после него
+	var deferred []entities.Record
+	for _, record := range update.Data {
+		secret, err := gcrypto.DecryptRecord(&record, g.key)
 		if err != nil {
-			slog.Error(err.Error(), "method:", "(g *GophKeeper) applyUpdate(update entities.Update) bool")
-			return false
+			if g.legacyKey != "" && gcrypto.IsLegacyRecord(&record) {
+				deferred = append(deferred, record)
+				continue
+			}
+			// Подменённая или повреждённая запись не должна скрывать остальные
+			slog.Error("запись "+record.Key+" не расшифрована: "+err.Error(), "method:", "(g *GophKeeper) applyUpdate(update entities.Update) bool")
+			continue
+		}
+
+		if secret.Type == entities.TypeManifest {
+			manifest = secret
+			continue
 		}
-		value, err := gcrypto.Decrypt(record.Value, g.key, "GophKeeper")
+
+		accept(&record, secret)
+	}
+
+	entries := g.trustedEntries(manifest)
+	for _, record := range deferred {
+		secret, err := g.openRecord(&record, entries)
 		if err != nil {
-			slog.Error(err.Error(), "method:", "(g *GophKeeper) applyUpdate(update entities.Update) bool")
-			return false
+			slog.Error("запись "+record.Key+" не расшифрована: "+err.Error(), "method:", "(g *GophKeeper) applyUpdate(update entities.Update) bool")
+			continue
 		}
 
-		newData[idx].Key = key
-		newData[idx].Value = value
+		accept(&record, secret)
 	}
 
 	g.countUpdate = update.Value
 	g.data = newData
-	g.Print()
+	g.legacy = legacy
+	g.unlinked = unlinked
+	g.print()
+	g.verifyManifest(manifest, versions)
 	return true
 }
 
+// migrateLegacy перешифровывает записи старых форматов в запись целиком
+// под новым ID и перемещает старую в корзину. Старая запись удаляется,
+// только когда новая сохранена на сервере, и окончательно не удаляется:
+// расшифровке старого формата нельзя полностью доверять, исходный
+// шифротекст остаётся в корзине до её очистки. Запись с именем, которое не
+// похоже на текст или уже занято, не переносится. Необработанные записи
+// остаются в g.legacy и переносятся при следующей синхронизации.
+func (g *GophKeeper) migrateLegacy() error {
+	g.mu.Lock()
+	legacy := g.legacy
+	g.legacy = nil
+	g.mu.Unlock()
+
+	keep := func(rest []entities.Secret) {
+		g.mu.Lock()
+		g.legacy = rest
+		g.mu.Unlock()
+	}
+
+	isLegacy := make(map[string]bool, len(legacy))
+	for _, secret := range legacy {
+		isLegacy[secret.ID] = true
+	}
+
+	for i, secret := range legacy {
+		oldID := secret.ID
+
+		// Прошлый перенос мог сохранить новую запись, но не удалить старую
+		if !g.migrated(secret, isLegacy) {
+			if err := g.canMigrate(secret); err != nil {
+				slog.Warn("запись старого формата не перенесена: "+err.Error(), "method:", "func (g *GophKeeper) migrateLegacy() error")
+				continue
+			}
+
+			id, err := gcrypto.NewRecordID()
+			if err != nil {
+				slog.Error(err.Error(), "method:", "func (g *GophKeeper) migrateLegacy() error")
+				keep(legacy[i:])
+				return err
+			}
+			secret.ID = id
+
+			if err = g.saveSecret(&secret); err != nil {
+				keep(legacy[i:])
+				return err
+			}
+		}
+
+		if err := g.removeRecord(oldID); err != nil {
+			keep(legacy[i:])
+			return err
+		}
+	}
+
+	return nil
+}
+
+// canMigrate имя записи старого формата — текст и не занято другой записью.
+// Подменённое значение AES-CTR расшифровывается в мусор, переносить его нельзя.
+func (g *GophKeeper) canMigrate(secret entities.Secret) error {
+	if !utf8.ValidString(secret.Name) || !utf8.ValidString(secret.Value) {
+		return fmt.Errorf("запись %s расшифрована не в текст", secret.ID)
+	}
+
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	for _, s := range g.data {
+		if s.ID != secret.ID && s.Name == secret.Name {
+			return fmt.Errorf("имя %q записи %s уже занято", secret.Name, secret.ID)
+		}
+	}
+
+	return nil
+}
+
+// openRecord расшифровывает запись ключом хранилища. Запись старого
+// формата открывается прежним -k, только если хранилище создано поверх
+// таких записей и её шифротекст совпадает с версией в манифесте entries
+// (или записи там нет): значение в AES-CTR не аутентифицировано, и
+// подменённое сервером расшифровалось бы в мусор без ошибки.
+func (g *GophKeeper) openRecord(record *entities.Record, entries map[string]gcrypto.ManifestEntry) (*entities.Secret, error) {
+	secret, err := gcrypto.DecryptRecord(record, g.key)
+	if err == nil || g.legacyKey == "" || !gcrypto.IsLegacyRecord(record) {
+		return secret, err
+	}
+
+	if entry, ok := entries[record.Key]; ok && entry.Version != gcrypto.RecordVersion(record) {
+		return nil, gcrypto.ErrDecrypt
+	}
+
+	return gcrypto.DecryptLegacyRecord(record, g.legacyKey)
+}
+
+// hasLegacy есть записи старых форматов, ждущие перешифровки
+func (g *GophKeeper) hasLegacy() bool {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	return len(g.legacy) > 0
+}
+
+// migrated у записи старого формата уже есть перенесённая копия
+func (g *GophKeeper) migrated(secret entities.Secret, isLegacy map[string]bool) bool {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	for _, s := range g.data {
+		if !isLegacy[s.ID] && s.Name == secret.Name && s.Type == secret.Type && s.Value == secret.Value {
+			return true
+		}
+	}
+
+	return false
+}
+
 // syncData - запускает синхронизацию данных
 func (g *GophKeeper) syncData() {
 	for {
-		handlerSync(g)
+		if handlerSync(g) == nil {
+			if g.hasLegacy() {
+				g.migrateLegacy()
+			}
+			g.linkBlobs()
+			g.flushManifest()
+		}
 		time.Sleep(2 * time.Second)
 	}
 }
 
 // Print - вывод данных
 func (g *GophKeeper) Print() {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	g.print()
+}
+
+// print вывод данных, вызывается под g.mu
+func (g *GophKeeper) print() {
 	fmt.Println("\n========= BEGIN DATA =========")
-	for _, record := range g.data {
-		fmt.Printf("%s:%s\n", record.Key, record.Value)
+	for _, secret := range g.data {
+		fmt.Println(formatSecret(&secret, false))
 	}
 	fmt.Println("=========  END DATA  =========")
 }
@@ -281,15 +717,20 @@ func handlerSync(g *GophKeeper) error {
 	}
 
 	if statusCode == http.StatusUnauthorized {
-		err = g.auth()
+		err = g.refresh()
 		if err != nil {
 			slog.Error(err.Error(), "method:", "handlerRequest(g *GophKeeper) error")
 			return err
 		}
 
 		statusCode, err = g.sync()
+		if err != nil {
+			slog.Error(err.Error(), "method:", "handlerRequest(g *GophKeeper) error")
+			return err
+		}
 
-		if err != nil || statusCode != http.StatusOK {
+		if statusCode != http.StatusOK && statusCode != http.StatusNoContent {
+			err = fmt.Errorf("%v", statusCode)
 			slog.Error(err.Error(), "method:", "handlerRequest(g *GophKeeper) error")
 			return err
 		}
@@ -17,6 +17,10 @@ type Config struct {
 	CryptoKey  string `json:"crypto_key,omitempty"`
 	Username   string `json:"username"`
 	Password   string `json:"password"`
+	ClientCert string `json:"client_cert,omitempty"`
+	ClientKey  string `json:"client_key,omitempty"`
+	StateFile  string `json:"state_file,omitempty"`
+	Command    string `json:"-"`
 }
 
 // GetConfig конструктор конфига
@@ -26,6 +30,8 @@ func GetConfig() (*Config, error) {
 	parseFlags(cfg)
 	parseConfig(cfg)
 
+	cfg.Command = flag.Arg(0)
+
 	if cfg.AddrServer == "" {
 		cfg.AddrServer = "localhost:8080"
 	}
@@ -34,26 +40,47 @@ func GetConfig() (*Config, error) {
 		cfg.LogLevel = "info"
 	}
 
+	if cfg.StateFile == "" {
+		cfg.StateFile = "gophkeeper.state"
+	}
+
 	_, _, err := net.SplitHostPort(cfg.AddrServer)
 	if err != nil {
 		return nil, fmt.Errorf("неверный адрес сервера: %w", err)
 	}
 
-	if cfg.Username == "" || cfg.Password == "" || cfg.SecretKey == "" {
-		return nil, fmt.Errorf("не введен пользователь, пароль или секретный ключ")
+	if (cfg.ClientCert == "") != (cfg.ClientKey == "") {
+		return nil, fmt.Errorf("сертификат клиента и ключ задаются вместе")
+	}
+
+	if cfg.SecretKey == "" {
+		return nil, fmt.Errorf("не введен мастер-пароль")
+	}
+
+	// При входе по сертификату логин и пароль не нужны
+	if !cfg.CertAuth() && (cfg.Username == "" || cfg.Password == "") {
+		return nil, fmt.Errorf("не введен пользователь или пароль")
 	}
 
 	return cfg, nil
 }
 
+// CertAuth вход выполняется по клиентскому сертификату
+func (cfg *Config) CertAuth() bool {
+	return cfg.ClientCert != "" && cfg.Command != "register"
+}
+
 // parseFlags Чтение переданных флагов
 func parseFlags(cfg *Config) {
 	flag.StringVar(&cfg.AddrServer, "a", "", "server and port to run server")
 	flag.StringVar(&cfg.LogLevel, "l", "", "log level")
-	flag.StringVar(&cfg.SecretKey, "k", "", "secret key for encryption")
+	flag.StringVar(&cfg.SecretKey, "k", "", "master password for the vault")
 	flag.StringVar(&cfg.CryptoKey, "cert", "", "path to trusted CA certificate")
 	flag.StringVar(&cfg.Username, "user", "", "username")
 	flag.StringVar(&cfg.Password, "pass", "", "password")
+	flag.StringVar(&cfg.ClientCert, "client-cert", "", "path to client certificate for mTLS")
+	flag.StringVar(&cfg.ClientKey, "client-key", "", "path to client private key for mTLS")
+	flag.StringVar(&cfg.StateFile, "state", "", "path to local file with the last verified manifest version")
 	flag.Parse()
 }
 
@@ -81,13 +108,22 @@ func parseConfig(cfg *Config) {
 	if flag.Lookup("k").Value.String() == "" {
 		cfg.SecretKey = tmpCfg.SecretKey
 	}
-	if flag.Lookup("crypto-key").Value.String() == "" {
+	if flag.Lookup("cert").Value.String() == "" {
 		cfg.CryptoKey = tmpCfg.CryptoKey
 	}
 	if flag.Lookup("user").Value.String() == "" {
 		cfg.Username = tmpCfg.Username
 	}
-	if flag.Lookup("password").Value.String() == "" {
+	if flag.Lookup("pass").Value.String() == "" {
 		cfg.Password = tmpCfg.Password
 	}
+	if flag.Lookup("client-cert").Value.String() == "" {
+		cfg.ClientCert = tmpCfg.ClientCert
+	}
+	if flag.Lookup("client-key").Value.String() == "" {
+		cfg.ClientKey = tmpCfg.ClientKey
+	}
+	if flag.Lookup("state").Value.String() == "" {
+		cfg.StateFile = tmpCfg.StateFile
+	}
 }
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"GophKeeper.ru/internal/client/gcrypto"
+	"GophKeeper.ru/internal/entities"
+	"golang.org/x/exp/slog"
+)
+
+// History - вывод прежних версий записи, новые первыми
+func (g *GophKeeper) History(name string) error {
+	secret, err := g.current(name)
+	if err != nil {
+		return err
+	}
+
+	var versions []entities.Version
+	if err = g.getJSON("/api/data/"+secret.ID+"/history", &versions); err != nil {
+		slog.Error(err.Error(), "method:", "func (g *GophKeeper) History(name string) error")
+		return err
+	}
+
+	fmt.Printf("\n========= HISTORY %s =========\n", name)
+	for _, v := range versions {
+		old, err := openVersion(secret.ID, v, g.key)
+		if err != nil {
+			slog.Error("версия "+strconv.Itoa(v.ID)+" не расшифрована: "+err.Error(), "method:", "func (g *GophKeeper) History(name string) error")
+			continue
+		}
+
+		from := ""
+		if v.RestoredFrom != 0 {
+			from = fmt.Sprintf(" (из #%d)", v.RestoredFrom)
+		}
+		fmt.Printf("#%d%s %s %s\n", v.ID, from, v.CreatedAt.Local().Format("2006-01-02 15:04:05"), formatSecret(old, true))
+	}
+	fmt.Println("==============================")
+	return nil
+}
+
+// Restore - возврат записи к прежней версии, текущая уходит в историю.
+// Версия расшифровывается на клиенте, шифруется заново текущим ключом
+// данных записи и отправляется серверу, который отмечает, из какой версии
+// восстановлено значение.
+func (g *GophKeeper) Restore(name, version string) error {
+	n, err := strconv.Atoi(version)
+	if err != nil {
+		return fmt.Errorf("неверный номер версии %s", version)
+	}
+
+	secret, err := g.current(name)
+	if err != nil {
+		return err
+	}
+
+	var versions []entities.Version
+	if err = g.getJSON("/api/data/"+secret.ID+"/history", &versions); err != nil {
+		slog.Error(err.Error(), "method:", "func (g *GophKeeper) Restore(name, version string) error")
+		return err
+	}
+
+	var old *entities.Secret
+	for _, v := range versions {
+		if v.ID != n {
+			continue
+		}
+		if old, err = openVersion(secret.ID, v, g.key); err != nil {
+			return fmt.Errorf("версия %d не расшифровывается: %w", n, err)
+		}
+	}
+	if old == nil {
+		return entities.ErrVersionNotFound
+	}
+
+	old.ID, old.DEK = secret.ID, secret.DEK
+	return g.restoreSecret(old, n)
+}
+
+// restoreSecret отправляет перешифрованную версию n записи и вносит
+// её в манифест
+func (g *GophKeeper) restoreSecret(secret *entities.Secret, n int) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	record, err := gcrypto.EnecryptRecord(secret, g.key)
+	if err != nil {
+		err = errors.New("шифрование записи завершилось с ошибкой")
+		slog.Error(err.Error(), "method:", "func (g *GophKeeper) restoreSecret(secret *entities.Secret, n int) error")
+		return err
+	}
+	if ref := blobOf(secret); ref != nil {
+		record.Blob = ref.ID
+	}
+
+	path := "/api/data/" + secret.ID + "/history/" + strconv.Itoa(n) + "/restore"
+	if err = g.postJSON(path, record, nil); err != nil {
+		slog.Error(err.Error(), "method:", "func (g *GophKeeper) restoreSecret(secret *entities.Secret, n int) error")
+		return err
+	}
+
+	if g.manifest.ready {
+		g.manifest.entries[secret.ID] = gcrypto.ManifestEntry{Name: secret.Name, Version: gcrypto.RecordVersion(record)}
+	}
+	return g.writeManifest()
+}
+
+// current текущая версия записи: из последней синхронизации или с сервера
+func (g *GophKeeper) current(name string) (*entities.Secret, error) {
+	if secret, ok := g.find(name); ok {
+		return &secret, nil
+	}
+
+	secret, err := g.lookup(name)
+	if err != nil {
+		return nil, err
+	}
+	if secret == nil {
+		return nil, fmt.Errorf("запись %s не найдена", name)
+	}
+
+	return secret, nil
+}
+
+// openVersion расшифровывает прежнюю версию записи id. Ключ данных версии
+// обёрнут ключом хранилища: смена ключа перешифровывает его вместе с записями.
+func openVersion(id string, v entities.Version, key string) (*entities.Secret, error) {
+	record := entities.Record{Key: id, Value: v.Value, WrappedKey: v.WrappedKey}
+
+	return gcrypto.DecryptRecord(&record, key)
+}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"fmt"
+
 	"GophKeeper.ru/internal/client/cli"
 )
 
@@ -10,13 +12,32 @@ func main() {
 		panic(err)
 	}
 
-	gophKeeper, err := NewGophKeeper(config.Username, config.Password, config.AddrServer, config.SecretKey, config.CryptoKey)
+	gophKeeper, err := NewGophKeeper(config)
 	if err != nil {
 		panic(err)
 	}
 
-	if err = gophKeeper.auth(); err != nil {
-		panic("Ошибка авторизации:" + err.Error())
+	if config.Command == "register" {
+		if err = gophKeeper.register(); err != nil {
+			panic("Ошибка регистрации:" + err.Error())
+		}
+		fmt.Println("Пользователь", config.Username, "зарегистрирован")
+	}
+
+	// С клиентским сертификатом сервер узнаёт пользователя при TLS-рукопожатии
+	if !config.CertAuth() {
+		if err = gophKeeper.auth(); err != nil {
+			panic("Ошибка авторизации:" + err.Error())
+		}
+	}
+
+	if config.Command == "recover" {
+		err = gophKeeper.recover(config.SecretKey)
+	} else {
+		err = gophKeeper.unlock(config.SecretKey)
+	}
+	if err != nil {
+		panic("Ошибка открытия хранилища:" + err.Error())
 	}
 
 	go cli.ReadCmd(gophKeeper)
@@ -0,0 +1,349 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"GophKeeper.ru/internal/client/gcrypto"
+	"GophKeeper.ru/internal/entities"
+	"golang.org/x/exp/slog"
+)
+
+// manifestName имя служебной записи манифеста, из CLI его не ввести
+const manifestName = "\x00manifest"
+
+// manifestState манифест записей, который ведёт клиент. entries — записи,
+// существование которых подтверждено действиями клиента, seq — последняя
+// виденная версия манифеста: её уменьшение означает откат на сервере.
+// seq и mac переживают перезапуск клиента в локальном файле store.
+type manifestState struct {
+	secret   *entities.Secret
+	entries  map[string]gcrypto.ManifestEntry
+	versions map[string]string // версии записей из последней синхронизации
+	seq      uint64
+	mac      string // подпись манифеста версии seq
+	known    bool   // манифест уже встречался, в том числе в прошлых запусках
+	ready    bool   // манифест сверен хотя бы раз
+	pending  bool   // манифест нужно записать на сервер
+	resync   bool   // после смены ключа версии принимаются заново
+	store    manifestStore
+}
+
+// manifestStore файл с последней сверенной версией манифеста каждого
+// хранилища. Без него откат сервера к старому манифесту между запусками
+// клиента не обнаружить.
+type manifestStore struct {
+	path    string
+	account string // сервер и пользователь, ключ записи в файле
+}
+
+// savedManifest версия и подпись манифеста, сохранённые локально
+type savedManifest struct {
+	Seq uint64 `json:"seq"`
+	MAC string `json:"mac"`
+}
+
+// load читает сохранённую версию манифеста, false — хранилище ещё не встречалось
+func (s manifestStore) load() (savedManifest, bool, error) {
+	all, err := s.readAll()
+	if err != nil {
+		return savedManifest{}, false, err
+	}
+
+	saved, ok := all[s.account]
+	return saved, ok, nil
+}
+
+// save запоминает версию манифеста. Файл заменяется целиком через
+// временный, чтобы сбой не оставил его обрезанным.
+func (s manifestStore) save(saved savedManifest) error {
+	all, err := s.readAll()
+	if err != nil {
+		return err
+	}
+	all[s.account] = saved
+
+	data, err := json.Marshal(all)
+	if err != nil {
+		return err
+	}
+
+	tmp := s.path + ".tmp"
+	if err = os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, s.path)
+}
+
+func (s manifestStore) readAll() (map[string]savedManifest, error) {
+	all := map[string]savedManifest{}
+
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return all, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err = json.Unmarshal(data, &all); err != nil {
+		return nil, fmt.Errorf("файл состояния манифеста %s повреждён: %w", s.path, err)
+	}
+
+	return all, nil
+}
+
+// loadManifest восстанавливает последнюю сверенную версию манифеста
+func (g *GophKeeper) loadManifest() {
+	saved, ok, err := g.manifest.store.load()
+	if err != nil {
+		slog.Warn(err.Error(), "method:", "func (g *GophKeeper) loadManifest()")
+		return
+	}
+	if !ok {
+		return
+	}
+
+	g.manifest.seq, g.manifest.mac, g.manifest.known = saved.Seq, saved.MAC, true
+}
+
+// rememberManifest сохраняет сверенную или записанную версию манифеста.
+// Вызывается под g.mu.
+func (g *GophKeeper) rememberManifest(m *gcrypto.Manifest) {
+	st := &g.manifest
+	st.seq, st.mac, st.known = m.Seq, m.MAC, true
+
+	if err := st.store.save(savedManifest{Seq: m.Seq, MAC: m.MAC}); err != nil {
+		slog.Warn("версия манифеста не сохранена локально: "+err.Error(), "method:", "func (g *GophKeeper) rememberManifest(m *gcrypto.Manifest)")
+	}
+}
+
+// trustedEntries записи манифеста для проверки записей старых форматов до
+// сверки: из манифеста обновления, если подпись верна и он не откатан и не
+// подменён, иначе из последнего сверенного. Вызывается под g.mu.
+func (g *GophKeeper) trustedEntries(secret *entities.Secret) map[string]gcrypto.ManifestEntry {
+	st := &g.manifest
+	if secret == nil {
+		return st.entries
+	}
+
+	var m gcrypto.Manifest
+	if err := json.Unmarshal([]byte(secret.Value), &m); err != nil || gcrypto.VerifyManifest(&m, g.key) != nil {
+		return st.entries
+	}
+	if m.Seq < st.seq || (m.Seq == st.seq && st.mac != "" && m.MAC != st.mac) {
+		return st.entries
+	}
+
+	return m.Records
+}
+
+// verifyManifest сверяет полученные записи с манифестом и громко
+// предупреждает о пропавших, откатанных и неизвестных записях.
+// Вызывается под g.mu.
+func (g *GophKeeper) verifyManifest(secret *entities.Secret, versions map[string]string) {
+	st := &g.manifest
+	st.versions = versions
+
+	// Первая синхронизация без манифеста или сразу после смены ключа:
+	// текущее состояние принимается за исходное
+	if st.resync || (secret == nil && !st.ready && !st.known) {
+		if secret == nil && !st.resync && len(g.data) > 0 {
+			warnNoManifest(len(g.data))
+		}
+		st.secret = secret
+		st.entries = currentEntries(g.data, versions)
+		st.ready, st.resync, st.pending = true, false, true
+		return
+	}
+
+	var warnings []string
+	if secret == nil {
+		warnings = append(warnings, "манифест записей удалён с сервера")
+		st.secret = nil
+		st.pending = true
+	} else {
+		var m gcrypto.Manifest
+		if err := json.Unmarshal([]byte(secret.Value), &m); err != nil || gcrypto.VerifyManifest(&m, g.key) != nil {
+			warnings = append(warnings, "манифест записей повреждён")
+		} else if m.Seq < st.seq {
+			warnings = append(warnings, fmt.Sprintf("манифест записей откатан: версия %d, уже была %d", m.Seq, st.seq))
+		} else if m.Seq == st.seq && st.mac != "" && m.MAC != st.mac {
+			warnings = append(warnings, fmt.Sprintf("манифест записей подменён: версия %d уже была с другим содержимым", m.Seq))
+		} else {
+			st.entries = m.Records
+			if m.Seq != st.seq || m.MAC != st.mac {
+				g.rememberManifest(&m)
+			}
+		}
+		st.secret = secret
+	}
+
+	// Без сверенного манифеста сравнивать записи не с чем
+	trusted := st.entries != nil
+	if !trusted {
+		st.entries = map[string]gcrypto.ManifestEntry{}
+	}
+	st.ready = true
+
+	for id, entry := range st.entries {
+		version, ok := versions[id]
+		switch {
+		case !ok:
+			warnings = append(warnings, fmt.Sprintf("запись %q пропала с сервера или не расшифровывается", entry.Name))
+		case version != entry.Version:
+			warnings = append(warnings, fmt.Sprintf("запись %q изменена в обход клиента или откатана", entry.Name))
+		}
+	}
+	for _, s := range g.data {
+		if _, ok := st.entries[s.ID]; trusted && !ok {
+			warnings = append(warnings, fmt.Sprintf("запись %q отсутствует в манифесте: восстановлена после удаления или добавлена в обход клиента", s.Name))
+		}
+	}
+
+	if len(warnings) == 0 {
+		return
+	}
+
+	fmt.Println("\n!!!!!!!!! ВНИМАНИЕ: НАРУШЕНА ЦЕЛОСТНОСТЬ ХРАНИЛИЩА !!!!!!!!!")
+	for _, w := range warnings {
+		fmt.Println("  -", w)
+		slog.Warn(w, "method:", "func (g *GophKeeper) verifyManifest(secret *entities.Secret, versions map[string]string)")
+	}
+	fmt.Println("Сервер вернул не то, что сохранял клиент. Если изменения ваши,")
+	fmt.Println("примите текущее состояние командой manifest-reset")
+	fmt.Println("!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!")
+}
+
+// warnNoManifest предупреждает, что записи есть, а манифеста нет: клиент
+// видит хранилище впервые и не может проверить полноту и свежесть записей
+func warnNoManifest(records int) {
+	w := fmt.Sprintf("в хранилище %d записей, но нет манифеста записей", records)
+
+	fmt.Println("\n!!!!!!!!! ВНИМАНИЕ: ЦЕЛОСТНОСТЬ ХРАНИЛИЩА НЕ ПРОВЕРЕНА !!!!!!!!!")
+	fmt.Println("  -", w)
+	fmt.Println("Клиент не может убедиться, что сервер вернул все записи в последних")
+	fmt.Println("версиях. Манифест будет создан по текущему состоянию.")
+	fmt.Println("!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!")
+	slog.Warn(w, "method:", "func warnNoManifest(records int)")
+}
+
+// writeManifest подписывает и сохраняет манифест. Вызывается под g.mu.
+func (g *GophKeeper) writeManifest() error {
+	st := &g.manifest
+	if !st.ready {
+		// Исходное состояние ещё не получено, манифест запишется после синхронизации
+		return nil
+	}
+	st.pending = true
+
+	if st.secret == nil {
+		// Манифест мог создать другой клиент, имя у него одно на хранилище
+		existing, err := g.lookup(manifestName)
+		if err != nil {
+			return err
+		}
+		if existing == nil {
+			id, err := gcrypto.NewRecordID()
+			if err != nil {
+				return err
+			}
+			existing = &entities.Secret{ID: id, Name: manifestName, Type: entities.TypeManifest}
+		}
+		st.secret = existing
+	}
+
+	m := gcrypto.Manifest{Seq: st.seq + 1, Records: st.entries}
+	if err := gcrypto.SignManifest(&m, g.key); err != nil {
+		return err
+	}
+
+	value, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	st.secret.Value = string(value)
+
+	if _, err = g.putSecret(st.secret); err != nil {
+		slog.Error(err.Error(), "method:", "func (g *GophKeeper) writeManifest() error")
+		return fmt.Errorf("манифест записей не обновлён: %w", err)
+	}
+
+	g.rememberManifest(&m)
+	st.pending = false
+	return nil
+}
+
+// flushManifest записывает манифест, отложенный из-за ошибки или первой синхронизации
+func (g *GophKeeper) flushManifest() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if !g.manifest.pending {
+		return
+	}
+	g.writeManifest()
+}
+
+// ResetManifest - принимает текущее состояние записей на сервере как верное
+func (g *GophKeeper) ResetManifest() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if !g.manifest.ready {
+		return fmt.Errorf("записи ещё не синхронизированы")
+	}
+
+	g.manifest.entries = currentEntries(g.data, g.manifest.versions)
+	if err := g.writeManifest(); err != nil {
+		return err
+	}
+
+	fmt.Printf("Манифест записей обновлён, записей: %d\n", len(g.manifest.entries))
+	return nil
+}
+
+// stageManifest манифест под новым ключом хранилища для смены ключа.
+// Версии записей перешифрованных в прошлых запусках устареют, поэтому
+// после смены ключа они принимаются заново (resync).
+func (g *GophKeeper) stageManifest(key string, versions map[string]string) (*entities.Record, error) {
+	st := &g.manifest
+	if st.secret == nil {
+		return nil, nil
+	}
+
+	entries := make(map[string]gcrypto.ManifestEntry, len(st.entries))
+	for id, entry := range st.entries {
+		if version, ok := versions[id]; ok {
+			entry.Version = version
+		}
+		entries[id] = entry
+	}
+
+	m := gcrypto.Manifest{Seq: st.seq + 1, Records: entries}
+	if err := gcrypto.SignManifest(&m, key); err != nil {
+		return nil, err
+	}
+
+	value, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+
+	secret := *st.secret
+	secret.Value = string(value)
+	return gcrypto.EnecryptRecord(&secret, key)
+}
+
+// currentEntries манифест по текущим записям
+func currentEntries(data []entities.Secret, versions map[string]string) map[string]gcrypto.ManifestEntry {
+	entries := make(map[string]gcrypto.ManifestEntry, len(data))
+	for _, s := range data {
+		entries[s.ID] = gcrypto.ManifestEntry{Name: s.Name, Version: versions[s.ID]}
+	}
+
+	return entries
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"sort"
+	"strings"
+
+	"GophKeeper.ru/internal/entities"
+)
+
+// SetMeta - добавление\обновление поля метаданных записи
+func (g *GophKeeper) SetMeta(record, key, value string) error {
+	if key == "" {
+		return fmt.Errorf("не указано имя поля")
+	}
+
+	return g.updateMeta(record, func(meta map[string]string) error {
+		meta[key] = value
+		return nil
+	})
+}
+
+// RemoveMeta - удаление поля метаданных записи
+func (g *GophKeeper) RemoveMeta(record, key string) error {
+	return g.updateMeta(record, func(meta map[string]string) error {
+		if _, ok := meta[key]; !ok {
+			return fmt.Errorf("у записи %s нет поля %s", record, key)
+		}
+		delete(meta, key)
+		return nil
+	})
+}
+
+// updateMeta изменяет копию метаданных записи и сохраняет запись
+func (g *GophKeeper) updateMeta(name string, change func(meta map[string]string) error) error {
+	secret, ok := g.find(name)
+	if !ok {
+		found, err := g.lookup(name)
+		if err != nil {
+			return err
+		}
+		if found == nil {
+			return fmt.Errorf("запись %s не найдена", name)
+		}
+		secret = *found
+	}
+
+	// Карта общая с g.data, изменяем копию
+	meta := maps.Clone(secret.Metadata)
+	if meta == nil {
+		meta = map[string]string{}
+	}
+	if err := change(meta); err != nil {
+		return err
+	}
+	secret.Metadata = meta
+
+	return g.saveSecret(&secret)
+}
+
+// formatMeta метаданные записи построчно, по алфавиту
+func formatMeta(secret *entities.Secret) string {
+	if len(secret.Metadata) == 0 {
+		return ""
+	}
+
+	keys := make([]string, 0, len(secret.Metadata))
+	for k := range secret.Metadata {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, k := range keys {
+		fmt.Fprintf(&b, "\n    %s: %s", k, secret.Metadata[k])
+	}
+
+	return b.String()
+}
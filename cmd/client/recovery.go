@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"GophKeeper.ru/internal/client/gcrypto"
+	"golang.org/x/exp/slog"
+)
+
+// NewRecoveryKey - создаёт офлайн-ключ восстановления и печатает его.
+// Прежний ключ восстановления перестаёт действовать.
+func (g *GophKeeper) NewRecoveryKey() error {
+	key, printable, err := gcrypto.NewRecoveryKey()
+	if err != nil {
+		return err
+	}
+
+	wrapped, err := gcrypto.WrapRecovery(g.key, key)
+	if err != nil {
+		return err
+	}
+
+	err = g.postJSON("/api/vault/recovery", map[string]string{
+		"key_id":       gcrypto.KeyID(g.key),
+		"recovery_key": wrapped,
+	}, nil)
+	if err != nil {
+		slog.Error(err.Error(), "method:", "func (g *GophKeeper) NewRecoveryKey() error")
+		return err
+	}
+
+	fmt.Println("\n========= КЛЮЧ ВОССТАНОВЛЕНИЯ =========")
+	fmt.Println(printable)
+	fmt.Println("Запишите его и храните офлайн: без него и мастер-пароля данные не восстановить.")
+	fmt.Println("=======================================")
+	return nil
+}
+
+// SplitKey - делит ключ хранилища на n долей, любые k из которых
+// восстанавливают доступ. Доли раздаются доверенным коллегам.
+func (g *GophKeeper) SplitKey(k, n int) error {
+	shares, err := gcrypto.SplitKey(g.key, k, n)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("\n========= ДОЛИ КЛЮЧА (%d из %d) =========\n", k, n)
+	for _, share := range shares {
+		fmt.Println(share)
+	}
+	fmt.Println("Каждая доля отдельно ничего не раскрывает. После rotate-key доли недействительны.")
+	fmt.Println("========================================")
+	return nil
+}
+
+// recover - восстановление доступа по ключу восстановления или долям ключа
+// хранилища. Ключ хранилища заново оборачивается новым мастер-паролем.
+func (g *GophKeeper) recover(password string) error {
+	v, err := g.fetchVault()
+	if err != nil {
+		return err
+	}
+	if v == nil {
+		return errors.New("хранилище ещё не создано, восстанавливать нечего")
+	}
+
+	fmt.Println("Введите ключ восстановления или доли ключа, по одной в строке:")
+	scanner := bufio.NewScanner(os.Stdin)
+
+	var (
+		key    string
+		shares [][]byte
+		need   int
+	)
+	for key == "" && scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		if !gcrypto.IsShare(line) {
+			if v.RecoveryKey == "" {
+				return errors.New("ключ восстановления не создан, используйте доли ключа")
+			}
+
+			recoveryKey, err := gcrypto.ParseRecoveryKey(line)
+			if err != nil {
+				return err
+			}
+			if key, err = gcrypto.UnwrapRecovery(v.RecoveryKey, recoveryKey); err != nil {
+				return errors.New("неверный ключ восстановления")
+			}
+			break
+		}
+
+		share, k, err := gcrypto.ParseShare(line)
+		if err != nil {
+			return err
+		}
+		need = k
+		shares = append(shares, share)
+
+		if len(shares) < need {
+			fmt.Printf("Принято долей: %d из %d\n", len(shares), need)
+			continue
+		}
+
+		if key, err = gcrypto.CombineKey(shares); err != nil {
+			return err
+		}
+	}
+
+	if key == "" {
+		return errors.New("ввод прерван")
+	}
+
+	// Отпечаток показывает, что собран именно ключ этого хранилища
+	if gcrypto.KeyID(key) != v.KeyID {
+		return errors.New("собранный ключ не подходит к хранилищу")
+	}
+
+	g.key = key
+	if err = g.saveVault(password); err != nil {
+		slog.Error(err.Error(), "method:", "func (g *GophKeeper) recover(password string) error")
+		return err
+	}
+
+	if err = g.ensureKeyPair(v); err != nil {
+		slog.Warn("не удалось открыть ключ для обмена записями", "error", err)
+	}
+
+	fmt.Println("Доступ восстановлен, хранилище защищено новым мастер-паролем")
+	return nil
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"GophKeeper.ru/internal/client/gcrypto"
+	"GophKeeper.ru/internal/entities"
+	"golang.org/x/exp/slog"
+)
+
+// rotationBatch число записей в одной пачке при смене ключа
+const rotationBatch = 100
+
+// RotateKey - смена ключа хранилища и мастер-пароля. Записи перешифровываются
+// пачками и заменяются на сервере одной транзакцией. Прерванную смену можно
+// продолжить, повторив команду с тем же паролем.
+func (g *GophKeeper) RotateKey(password string) error {
+	if len(password) < 8 {
+		return errors.New("мастер-пароль должен содержать не менее 8 символов")
+	}
+
+	// Старые форматы записей пачкой не перешифровать, сначала переводим их
+	if err := handlerSync(g); err != nil {
+		return err
+	}
+	if g.hasLegacy() {
+		if err := g.migrateLegacy(); err != nil {
+			return err
+		}
+		if err := handlerSync(g); err != nil {
+			return err
+		}
+	}
+
+	// Синхронизация не должна видеть записи под новым ключом раньше, чем клиент его узнает
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	rot, err := g.fetchRotation()
+	if err != nil {
+		return err
+	}
+
+	var key string
+	if rot != nil {
+		kek, err := gcrypto.DeriveKey(password, rot.Vault.KDF)
+		if err != nil {
+			return err
+		}
+		if key, err = gcrypto.UnwrapKey(rot.Vault.WrappedKey, kek); err != nil {
+			return errors.New("начата смена ключа с другим паролем, отмените её: rotate-abort")
+		}
+		fmt.Printf("Продолжаем смену ключа, уже перешифровано записей: %d\n", len(rot.Done))
+	} else {
+		if key, err = gcrypto.NewVaultKey(); err != nil {
+			return err
+		}
+
+		v, err := wrapVault(key, password)
+		if err != nil {
+			return err
+		}
+
+		// Закрытый ключ для обмена записями переходит под новый ключ хранилища
+		if g.private != "" {
+			if v.PrivateKey, err = gcrypto.WrapPrivateKey(g.private, key); err != nil {
+				return err
+			}
+		}
+
+		rot = &entities.Rotation{}
+		if err = g.postJSON("/api/rotation", v, rot); err != nil {
+			slog.Error(err.Error(), "method:", "func (g *GophKeeper) RotateKey(password string) error")
+			return err
+		}
+	}
+
+	done := make(map[string]bool, len(rot.Done))
+	for _, id := range rot.Done {
+		done[id] = true
+	}
+
+	// Записи в корзине тоже переходят под новый ключ, иначе их не вернуть
+	var trash []entities.TrashItem
+	if err = g.getJSON("/api/trash", &trash); err != nil {
+		slog.Error(err.Error(), "method:", "func (g *GophKeeper) RotateKey(password string) error")
+		return err
+	}
+
+	data := g.data
+	total := len(data) + len(trash)
+	versions := make(map[string]string, len(data))
+	batch := make([]entities.Record, 0, rotationBatch)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := g.postJSON("/api/rotation/records", batch, nil); err != nil {
+			slog.Error(err.Error(), "method:", "func (g *GophKeeper) RotateKey(password string) error")
+			return err
+		}
+		batch = batch[:0]
+		fmt.Printf("Перешифровано записей: %d из %d\n", len(done), total)
+		return nil
+	}
+
+	for _, secret := range data {
+		if done[secret.ID] {
+			continue
+		}
+
+		record, err := gcrypto.EnecryptRecord(&secret, key)
+		if err != nil {
+			return err
+		}
+		batch = append(batch, *record)
+		done[secret.ID] = true
+		versions[secret.ID] = gcrypto.RecordVersion(record)
+
+		if len(batch) == rotationBatch {
+			if err = flush(); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, item := range trash {
+		if done[item.Key] {
+			continue
+		}
+
+		secret, err := g.openRecord(&item.Record, nil)
+		if err != nil {
+			return fmt.Errorf("запись в корзине не расшифрована, смену ключа не завершить: %w", err)
+		}
+
+		record, err := gcrypto.EnecryptRecord(secret, key)
+		if err != nil {
+			return err
+		}
+		batch = append(batch, *record)
+		done[item.Key] = true
+
+		if len(batch) == rotationBatch {
+			if err = flush(); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err = g.rotateHistory(key); err != nil {
+		return err
+	}
+
+	// Манифест тоже запись хранилища и перешифровывается последним
+	manifest, err := g.stageManifest(key, versions)
+	if err != nil {
+		return err
+	}
+	if manifest != nil && !done[manifest.Key] {
+		batch = append(batch, *manifest)
+		done[manifest.Key] = true
+	}
+
+	if err = flush(); err != nil {
+		return err
+	}
+
+	if err = g.postJSON("/api/rotation/commit", nil, nil); err != nil {
+		slog.Error(err.Error(), "method:", "func (g *GophKeeper) RotateKey(password string) error")
+		return err
+	}
+
+	// Записи заменены на сервере, перечитываем их с новым ключом
+	// Записи старых форматов перешифрованы, прежний -k больше не нужен
+	g.key = key
+	g.legacyKey = ""
+	g.countUpdate = -1
+	g.manifest.resync = true
+
+	// Ключ восстановления и доли относились к старому ключу
+	fmt.Println("Прежние ключ восстановления и доли ключа больше не действуют")
+	return g.NewRecoveryKey()
+}
+
+// rotateHistory перешифровывает ключи данных версий в истории новым ключом
+// хранилища. Без этого старый ключ открывал бы ключ данных из истории, а
+// им — текущую запись. Ключ, который не открывается текущим ключом
+// хранилища, отбрасывается: версия станет нечитаемой. Вызывается под g.mu.
+func (g *GophKeeper) rotateHistory(key string) error {
+	var history []entities.HistoryKey
+	if err := g.getJSON("/api/rotation/history", &history); err != nil {
+		slog.Error(err.Error(), "method:", "func (g *GophKeeper) rotateHistory(key string) error")
+		return err
+	}
+
+	batch := make([]entities.HistoryKey, 0, rotationBatch)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := g.postJSON("/api/rotation/history", batch, nil); err != nil {
+			slog.Error(err.Error(), "method:", "func (g *GophKeeper) rotateHistory(key string) error")
+			return err
+		}
+		batch = batch[:0]
+		return nil
+	}
+
+	dropped := 0
+	for _, h := range history {
+		dek, err := gcrypto.UnwrapDataKey(h.Record, h.WrappedKey, g.key)
+		if err != nil {
+			h.WrappedKey = ""
+			dropped++
+		} else if h.WrappedKey, err = gcrypto.WrapDataKey(h.Record, dek, key); err != nil {
+			return err
+		}
+		batch = append(batch, h)
+
+		if len(batch) == rotationBatch {
+			if err = flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return err
+	}
+
+	if len(history) > 0 {
+		fmt.Printf("Перешифровано ключей версий в истории: %d\n", len(history)-dropped)
+	}
+	if dropped > 0 {
+		fmt.Printf("Версий в истории под прежними ключами хранилища больше не прочитать: %d\n", dropped)
+	}
+	return nil
+}
+
+// AbortRotation - отмена незавершённой смены ключа
+func (g *GophKeeper) AbortRotation() error {
+	req, err := http.NewRequest(http.MethodDelete, "https://"+g.addr+"/api/rotation", nil)
+	if err != nil {
+		slog.Error(err.Error(), "method:", "func (g *GophKeeper) AbortRotation() error")
+		return err
+	}
+
+	resp, err := g.Client.Do(req)
+	if err != nil {
+		slog.Error(err.Error(), "method:", "func (g *GophKeeper) AbortRotation() error")
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return entities.ErrRotationNotFound
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%v", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// fetchRotation незавершённая смена ключа, nil — её нет
+func (g *GophKeeper) fetchRotation() (*entities.Rotation, error) {
+	req, err := http.NewRequest(http.MethodGet, "https://"+g.addr+"/api/rotation", nil)
+	if err != nil {
+		slog.Error(err.Error(), "method:", "func (g *GophKeeper) fetchRotation() (*entities.Rotation, error)")
+		return nil, err
+	}
+
+	resp, err := g.Client.Do(req)
+	if err != nil {
+		slog.Error(err.Error(), "method:", "func (g *GophKeeper) fetchRotation() (*entities.Rotation, error)")
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%v", resp.StatusCode)
+	}
+
+	var rot entities.Rotation
+	if err = json.NewDecoder(resp.Body).Decode(&rot); err != nil {
+		return nil, err
+	}
+
+	return &rot, nil
+}
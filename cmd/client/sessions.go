@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"GophKeeper.ru/internal/entities"
+	"golang.org/x/exp/slog"
+)
+
+// Sessions - вывод активных сессий пользователя
+func (g *GophKeeper) Sessions() error {
+	req, err := http.NewRequest(http.MethodGet, "https://"+g.addr+"/api/sessions", nil)
+	if err != nil {
+		slog.Error(err.Error(), "method:", "func (g *GophKeeper) Sessions() error")
+		return err
+	}
+
+	resp, err := g.Client.Do(req)
+	if err != nil {
+		slog.Error(err.Error(), "method:", "func (g *GophKeeper) Sessions() error")
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		err = fmt.Errorf("%v", resp.StatusCode)
+		slog.Error(err.Error(), "method:", "func (g *GophKeeper) Sessions() error")
+		return err
+	}
+
+	var list []entities.Session
+	if err = json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		slog.Error(err.Error(), "method:", "func (g *GophKeeper) Sessions() error")
+		return err
+	}
+
+	fmt.Println("\n========= SESSIONS =========")
+	for _, s := range list {
+		mark := " "
+		if s.Current {
+			mark = "*"
+		}
+		fmt.Printf("%s %s  %s  %s  последняя активность %s\n",
+			mark, s.ID, s.IP, s.UserAgent, s.LastSeenAt.Format("2006-01-02 15:04"))
+	}
+	fmt.Println("============================")
+	return nil
+}
+
+// Logout - завершение сессии. Без id завершает текущую сессию
+func (g *GophKeeper) Logout(id string) error {
+	method, path := http.MethodPost, "/api/logout"
+	if id != "" {
+		method, path = http.MethodDelete, "/api/sessions/"+url.PathEscape(id)
+	}
+
+	req, err := http.NewRequest(method, "https://"+g.addr+path, nil)
+	if err != nil {
+		slog.Error(err.Error(), "method:", "func (g *GophKeeper) Logout(id string) error")
+		return err
+	}
+
+	resp, err := g.Client.Do(req)
+	if err != nil {
+		slog.Error(err.Error(), "method:", "func (g *GophKeeper) Logout(id string) error")
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return entities.ErrSessionNotFound
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		err = fmt.Errorf("%v", resp.StatusCode)
+		slog.Error(err.Error(), "method:", "func (g *GophKeeper) Logout(id string) error")
+		return err
+	}
+
+	return nil
+}
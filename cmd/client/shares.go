@@ -0,0 +1,311 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"GophKeeper.ru/internal/client/gcrypto"
+	"GophKeeper.ru/internal/entities"
+	"golang.org/x/exp/slog"
+)
+
+// ensureKeyPair открывает закрытый ключ X25519 из хранилища,
+// а если пары ещё нет — создаёт её и публикует открытый ключ
+func (g *GophKeeper) ensureKeyPair(v *entities.Vault) error {
+	if v.PrivateKey != "" {
+		private, err := gcrypto.UnwrapPrivateKey(v.PrivateKey, g.key)
+		if err != nil {
+			return err
+		}
+		g.private = private
+		return nil
+	}
+
+	public, private, err := gcrypto.NewKeyPair()
+	if err != nil {
+		return err
+	}
+
+	wrapped, err := gcrypto.WrapPrivateKey(private, g.key)
+	if err != nil {
+		return err
+	}
+
+	err = g.postJSON("/api/vault/keypair", map[string]string{
+		"key_id":      gcrypto.KeyID(g.key),
+		"public_key":  public,
+		"private_key": wrapped,
+	}, nil)
+	if err != nil {
+		slog.Error(err.Error(), "method:", "func (g *GophKeeper) ensureKeyPair(v *entities.Vault) error")
+		return err
+	}
+
+	g.private = private
+	return nil
+}
+
+// Share - выдаёт пользователю login доступ к записи: ключ данных записи
+// шифруется его открытым ключом
+func (g *GophKeeper) Share(name, login string) error {
+	secret, ok := g.find(name)
+	if !ok {
+		return fmt.Errorf("запись %s не найдена", name)
+	}
+	if secret.DEK == "" {
+		return errors.New("запись ещё не перешифрована в новый формат, повторите позже")
+	}
+
+	id, public, err := g.shareKey(&secret, login)
+	if err != nil {
+		slog.Error(err.Error(), "method:", "func (g *GophKeeper) Share(name, login string) error")
+		return err
+	}
+
+	// Сервер может подменить открытый ключ, отпечаток сверяется с получателем
+	fmt.Printf("Доступ %d к %s выдан пользователю %s\n", id, name, login)
+	fmt.Println("Отпечаток ключа получателя:", gcrypto.Fingerprint(public))
+	fmt.Println("Сверьте его с получателем (команда my-key) по другому каналу")
+	return nil
+}
+
+// shareKey шифрует ключ данных записи открытым ключом login и выдаёт
+// или обновляет доступ. Возвращает ID доступа и открытый ключ получателя.
+func (g *GophKeeper) shareKey(secret *entities.Secret, login string) (int, string, error) {
+	var key struct {
+		PublicKey string `json:"public_key"`
+	}
+	if err := g.getJSON("/api/keys/"+url.PathEscape(login), &key); err != nil {
+		return 0, "", err
+	}
+
+	wrapped, err := gcrypto.ShareDataKey(secret.ID, secret.DEK, key.PublicKey)
+	if err != nil {
+		return 0, "", err
+	}
+
+	var out struct {
+		ID int `json:"id"`
+	}
+	err = g.postJSON("/api/shares", entities.Share{
+		Record:     secret.ID,
+		Recipient:  login,
+		WrappedKey: wrapped,
+	}, &out)
+	if err != nil {
+		return 0, "", err
+	}
+
+	return out.ID, key.PublicKey, nil
+}
+
+// Unshare - отзывает выданный доступ и перешифровывает запись новым ключом
+// данных: старый ключ остался у получателя. Остальным получателям записи
+// новый ключ выдаётся заново. Получатель, уже видевший запись, мог её
+// сохранить: секрет стоит сменить.
+func (g *GophKeeper) Unshare(id string) error {
+	n, err := strconv.Atoi(id)
+	if err != nil {
+		return fmt.Errorf("неверный ID доступа %s", id)
+	}
+
+	var list []entities.Share
+	if err = g.getJSON("/api/shares", &list); err != nil {
+		slog.Error(err.Error(), "method:", "func (g *GophKeeper) Unshare(id string) error")
+		return err
+	}
+
+	var (
+		revoked *entities.Share
+		others  []string
+	)
+	for i, s := range list {
+		if s.ID == n {
+			revoked = &list[i]
+		}
+	}
+	if revoked == nil {
+		return entities.ErrShareNotFound
+	}
+	for _, s := range list {
+		if s.Record == revoked.Record && s.ID != n {
+			others = append(others, s.Recipient)
+		}
+	}
+
+	req, err := http.NewRequest(http.MethodDelete, "https://"+g.addr+"/api/shares/"+id, nil)
+	if err != nil {
+		slog.Error(err.Error(), "method:", "func (g *GophKeeper) Unshare(id string) error")
+		return err
+	}
+
+	resp, err := g.Client.Do(req)
+	if err != nil {
+		slog.Error(err.Error(), "method:", "func (g *GophKeeper) Unshare(id string) error")
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return entities.ErrShareNotFound
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		err = apiError(resp)
+		slog.Error(err.Error(), "method:", "func (g *GophKeeper) Unshare(id string) error")
+		return err
+	}
+
+	if err = g.rekey(revoked.Record, others); err != nil {
+		slog.Error(err.Error(), "method:", "func (g *GophKeeper) Unshare(id string) error")
+		return fmt.Errorf("доступ отозван, но запись не перешифрована: %w", err)
+	}
+
+	return nil
+}
+
+// rekey перешифровывает запись новым ключом данных и выдаёт его recipients.
+// Ключ фрагментов большого файла хранится внутри записи и не меняется.
+func (g *GophKeeper) rekey(recordID string, recipients []string) error {
+	secret, ok := g.findID(recordID)
+	if !ok {
+		return fmt.Errorf("запись %s не найдена", recordID)
+	}
+
+	secret.DEK = ""
+	if err := g.saveSecret(&secret); err != nil {
+		return err
+	}
+
+	// До следующей синхронизации новые доступы должны получать новый ключ
+	g.mu.Lock()
+	for i := range g.data {
+		if g.data[i].ID == secret.ID {
+			g.data[i].DEK = secret.DEK
+		}
+	}
+	g.mu.Unlock()
+
+	for _, login := range recipients {
+		if _, _, err := g.shareKey(&secret, login); err != nil {
+			return fmt.Errorf("ключ для %s не выдан: %w", login, err)
+		}
+	}
+
+	return nil
+}
+
+// MyShares - вывод доступов, выданных другим пользователям
+func (g *GophKeeper) MyShares() error {
+	var list []entities.Share
+	if err := g.getJSON("/api/shares", &list); err != nil {
+		slog.Error(err.Error(), "method:", "func (g *GophKeeper) MyShares() error")
+		return err
+	}
+
+	g.mu.RLock()
+	names := make(map[string]string, len(g.data))
+	for _, secret := range g.data {
+		names[secret.ID] = secret.Name
+	}
+	g.mu.RUnlock()
+
+	fmt.Println("\n========= MY SHARES =========")
+	for _, s := range list {
+		name, ok := names[s.Record]
+		if !ok {
+			name = s.Record
+		}
+		fmt.Printf("%d  %s -> %s  с %s\n", s.ID, name, s.Recipient, s.CreatedAt.Format("2006-01-02 15:04"))
+	}
+	fmt.Println("=============================")
+	return nil
+}
+
+// Shared - вывод записей других пользователей, доступных текущему
+func (g *GophKeeper) Shared() error {
+	if g.private == "" {
+		return errors.New("ключ для обмена записями не открыт")
+	}
+
+	var list []entities.Share
+	if err := g.getJSON("/api/shared", &list); err != nil {
+		slog.Error(err.Error(), "method:", "func (g *GophKeeper) Shared() error")
+		return err
+	}
+
+	fmt.Println("\n========= SHARED WITH ME =========")
+	for _, s := range list {
+		dek, err := gcrypto.OpenDataKey(s.Record, s.WrappedKey, g.private)
+		if err != nil {
+			slog.Error("доступ "+strconv.Itoa(s.ID)+" не расшифрован: "+err.Error(), "method:", "func (g *GophKeeper) Shared() error")
+			continue
+		}
+
+		secret, err := gcrypto.DecryptWithDataKey(s.Record, s.Value, dek)
+		if err != nil {
+			slog.Error("доступ "+strconv.Itoa(s.ID)+" не расшифрован: "+err.Error(), "method:", "func (g *GophKeeper) Shared() error")
+			continue
+		}
+
+		fmt.Printf("%s/%s\n", s.Owner, formatSecret(secret, true))
+	}
+	fmt.Println("==================================")
+	return nil
+}
+
+// DownloadShared - выгрузка файла из записи другого пользователя. name —
+// запись в том виде, в каком её показывает shared: ВЛАДЕЛЕЦ/ИМЯ
+func (g *GophKeeper) DownloadShared(name, path string) error {
+	if g.private == "" {
+		return errors.New("ключ для обмена записями не открыт")
+	}
+
+	owner, record, ok := strings.Cut(name, "/")
+	if !ok {
+		return fmt.Errorf("укажите запись как ВЛАДЕЛЕЦ/ИМЯ: %s", name)
+	}
+
+	var list []entities.Share
+	if err := g.getJSON("/api/shared", &list); err != nil {
+		slog.Error(err.Error(), "method:", "func (g *GophKeeper) DownloadShared(name, path string) error")
+		return err
+	}
+
+	for _, s := range list {
+		if s.Owner != owner {
+			continue
+		}
+
+		dek, err := gcrypto.OpenDataKey(s.Record, s.WrappedKey, g.private)
+		if err != nil {
+			continue
+		}
+		secret, err := gcrypto.DecryptWithDataKey(s.Record, s.Value, dek)
+		if err != nil || secret.Name != record {
+			continue
+		}
+
+		return g.downloadBlob(secret, path)
+	}
+
+	return fmt.Errorf("запись %s не найдена", name)
+}
+
+// MyKey - вывод отпечатка своего открытого ключа для сверки с владельцем записи
+func (g *GophKeeper) MyKey() error {
+	v, err := g.fetchVault()
+	if err != nil {
+		return err
+	}
+	if v == nil || v.PublicKey == "" {
+		return errors.New("ключ для обмена записями ещё не создан")
+	}
+
+	fmt.Println("Отпечаток вашего ключа:", gcrypto.Fingerprint(v.PublicKey))
+	return nil
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"GophKeeper.ru/internal/client/gcrypto"
+	"GophKeeper.ru/internal/entities"
+	"golang.org/x/exp/slog"
+)
+
+// trashSecret запись из корзины вместе с временем удаления
+type trashSecret struct {
+	entities.Secret
+	item entities.TrashItem
+}
+
+// Trash - вывод записей в корзине, последние удалённые первыми
+func (g *GophKeeper) Trash() error {
+	items, err := g.fetchTrash()
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("\n========= TRASH =========")
+	for _, t := range items {
+		fmt.Printf("%s %s\n", t.item.DeletedAt.Local().Format("2006-01-02 15:04:05"), formatSecret(&t.Secret, false))
+	}
+	fmt.Println("=========================")
+	return nil
+}
+
+// Undelete - возврат записи из корзины
+func (g *GophKeeper) Undelete(name string) error {
+	t, err := g.findTrash(name)
+	if err != nil {
+		return err
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	var record entities.Record
+	if err = g.postJSON("/api/trash/"+t.ID+"/restore", nil, &record); err != nil {
+		slog.Error(err.Error(), "method:", "func (g *GophKeeper) Undelete(name string) error")
+		return err
+	}
+
+	// Возврат записи — действие клиента, манифест должен его учесть
+	if g.manifest.ready {
+		g.manifest.entries[t.ID] = gcrypto.ManifestEntry{Name: t.Name, Version: gcrypto.RecordVersion(&record)}
+	}
+	return g.writeManifest()
+}
+
+// Purge - окончательное удаление записи из корзины вместе с историей и файлом
+func (g *GophKeeper) Purge(name string) error {
+	t, err := g.findTrash(name)
+	if err != nil {
+		return err
+	}
+
+	return g.purgeRecord(t.ID)
+}
+
+// purgeRecord окончательно удаляет запись из корзины по ID
+func (g *GophKeeper) purgeRecord(id string) error {
+	if err := g.doJSON(http.MethodDelete, "/api/trash/"+id, nil, nil); err != nil {
+		slog.Error(err.Error(), "method:", "func (g *GophKeeper) purgeRecord(id string) error")
+		return err
+	}
+
+	return nil
+}
+
+// findTrash последняя удалённая запись с именем name
+func (g *GophKeeper) findTrash(name string) (*trashSecret, error) {
+	items, err := g.fetchTrash()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, t := range items {
+		if t.Name == name {
+			return &t, nil
+		}
+	}
+
+	return nil, fmt.Errorf("в корзине нет записи %s", name)
+}
+
+// fetchTrash записи корзины, расшифрованные текущим ключом хранилища.
+// Манифест и записи, которые не удалось расшифровать, пропускаются.
+func (g *GophKeeper) fetchTrash() ([]trashSecret, error) {
+	var items []entities.TrashItem
+	if err := g.getJSON("/api/trash", &items); err != nil {
+		slog.Error(err.Error(), "method:", "func (g *GophKeeper) fetchTrash() ([]trashSecret, error)")
+		return nil, err
+	}
+
+	out := make([]trashSecret, 0, len(items))
+	for _, item := range items {
+		secret, err := g.openRecord(&item.Record, nil)
+		if err != nil {
+			slog.Error("запись корзины не расшифрована: "+err.Error(), "method:", "func (g *GophKeeper) fetchTrash() ([]trashSecret, error)")
+			continue
+		}
+		if secret.Type == entities.TypeManifest {
+			continue
+		}
+
+		out = append(out, trashSecret{Secret: *secret, item: item})
+	}
+
+	return out, nil
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"golang.org/x/exp/slog"
+)
+
+// authSecondFactor - второй шаг входа: запрашивает у пользователя код 2FA
+func (g *GophKeeper) authSecondFactor(mfaToken string) error {
+	fmt.Print("Введите код из приложения-аутентификатора или код восстановления: ")
+	code, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return err
+	}
+
+	jsonValue, _ := json.Marshal(map[string]string{
+		"mfa_token": mfaToken,
+		"code":      strings.TrimSpace(code),
+	})
+	req, err := http.NewRequest(http.MethodPost, "https://"+g.addr+"/api/auth/2fa", bytes.NewBuffer(jsonValue))
+	if err != nil {
+		slog.Error(err.Error(), "method:", "func (g *GophKeeper) authSecondFactor(mfaToken string) error")
+		return err
+	}
+
+	resp, err := g.Client.Do(req)
+	if err != nil {
+		slog.Error(err.Error(), "method:", "func (g *GophKeeper) authSecondFactor(mfaToken string) error")
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		err = fmt.Errorf("%v", resp.StatusCode)
+		slog.Error(err.Error(), "method:", "func (g *GophKeeper) authSecondFactor(mfaToken string) error")
+		return err
+	}
+
+	return nil
+}
+
+// EnrollTwoFactor - выпуск секрета TOTP для приложения-аутентификатора
+func (g *GophKeeper) EnrollTwoFactor() error {
+	var out struct {
+		Secret string `json:"secret"`
+		URI    string `json:"uri"`
+	}
+	if err := g.postJSON("/api/2fa/enroll", nil, &out); err != nil {
+		return err
+	}
+
+	fmt.Println("Секрет:", out.Secret)
+	fmt.Println("Ссылка для приложения:", out.URI)
+	fmt.Println("Подтвердите подключение командой: 2fa confirm CODE")
+	return nil
+}
+
+// ConfirmTwoFactor - включение 2FA первым кодом из приложения
+func (g *GophKeeper) ConfirmTwoFactor(code string) error {
+	var out struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}
+	if err := g.postJSON("/api/2fa/confirm", map[string]string{"code": code}, &out); err != nil {
+		return err
+	}
+
+	fmt.Println("2FA включена. Сохраните коды восстановления, каждый действует один раз:")
+	for _, c := range out.RecoveryCodes {
+		fmt.Println("  ", c)
+	}
+	return nil
+}
+
+// postJSON - POST-запрос к API с разбором JSON-ответа
+func (g *GophKeeper) postJSON(path string, in, out any) error {
+	return g.doJSON(http.MethodPost, path, in, out)
+}
+
+// getJSON - GET-запрос к API с разбором JSON-ответа
+func (g *GophKeeper) getJSON(path string, out any) error {
+	return g.doJSON(http.MethodGet, path, nil, out)
+}
+
+// doJSON - запрос к API с телом и ответом в JSON
+func (g *GophKeeper) doJSON(method, path string, in, out any) error {
+	var body bytes.Buffer
+	if in != nil {
+		if err := json.NewEncoder(&body).Encode(in); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequest(method, "https://"+g.addr+path, &body)
+	if err != nil {
+		slog.Error(err.Error(), "method:", "func (g *GophKeeper) doJSON(method, path string, in, out any) error")
+		return err
+	}
+
+	resp, err := g.Client.Do(req)
+	if err != nil {
+		slog.Error(err.Error(), "method:", "func (g *GophKeeper) doJSON(method, path string, in, out any) error")
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return apiError(resp)
+	}
+
+	if out == nil {
+		return nil
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// apiError - ошибка из ответа API: код и текст из поля error, если он есть
+func apiError(resp *http.Response) error {
+	var apiErr struct {
+		Error string `json:"error"`
+	}
+	json.NewDecoder(resp.Body).Decode(&apiErr)
+	if apiErr.Error != "" {
+		return fmt.Errorf("%v: %s", resp.StatusCode, apiErr.Error)
+	}
+	return fmt.Errorf("%v", resp.StatusCode)
+}
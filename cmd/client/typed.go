@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"GophKeeper.ru/internal/entities"
+	"golang.org/x/exp/slog"
+)
+
+// maxInlineFile наибольший размер файла, сохраняемого обычной записью
+const maxInlineFile = 1 << 20
+
+// NewLogin - добавление\обновление пары логин/пароль
+func (g *GophKeeper) NewLogin(name, login, password string) error {
+	data := entities.LoginData{Login: login, Password: password}
+	if err := data.Validate(); err != nil {
+		return err
+	}
+
+	return g.updateTyped(name, entities.TypeLogin, &data)
+}
+
+// NewCard - добавление\обновление данных банковской карты
+func (g *GophKeeper) NewCard(name, number, expiry, cvv, holder string) error {
+	data := entities.CardData{Number: number, Holder: holder, Expiry: expiry, CVV: cvv}
+	if err := data.Validate(); err != nil {
+		return err
+	}
+
+	return g.updateTyped(name, entities.TypeCard, &data)
+}
+
+// NewText - добавление\обновление произвольного текста
+func (g *GophKeeper) NewText(name, text string) error {
+	return g.updateSecret(name, entities.TypeText, text)
+}
+
+// NewFile - добавление\обновление двоичных данных из файла
+func (g *GophKeeper) NewFile(name, path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if info.Size() > maxInlineFile {
+		return fmt.Errorf("файл больше %d байт, используйте upload", maxInlineFile)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		slog.Error(err.Error(), "method:", "func (g *GophKeeper) NewFile(name, path string) error")
+		return err
+	}
+
+	return g.updateTyped(name, entities.TypeBinary, &entities.BinaryData{
+		FileName: filepath.Base(path),
+		Data:     content,
+	})
+}
+
+// updateTyped сохраняет типизированные данные как JSON в значении записи
+func (g *GophKeeper) updateTyped(name, typ string, data any) error {
+	value, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	return g.updateSecret(name, typ, string(value))
+}
+
+// saveFile записывает двоичные данные записи в файл
+func saveFile(secret *entities.Secret, path string) error {
+	var data entities.BinaryData
+	if err := json.Unmarshal([]byte(secret.Value), &data); err != nil {
+		return fmt.Errorf("запись %s повреждена", secret.Name)
+	}
+
+	if err := os.WriteFile(path, data.Data, 0600); err != nil {
+		return err
+	}
+
+	fmt.Printf("Файл %s сохранён в %s\n", data.FileName, path)
+	return nil
+}
+
+// formatSecret запись для вывода вместе с метаданными. Без full пароль,
+// номер карты и CVV скрываются.
+func formatSecret(secret *entities.Secret, full bool) string {
+	return formatValue(secret, full) + formatMeta(secret)
+}
+
+// formatValue строка значения записи с учётом её типа
+func formatValue(secret *entities.Secret, full bool) string {
+	switch secret.Type {
+	case entities.TypeLogin:
+		var data entities.LoginData
+		if json.Unmarshal([]byte(secret.Value), &data) != nil {
+			break
+		}
+		password := "********"
+		if full {
+			password = data.Password
+		}
+		return fmt.Sprintf("%s [login] %s / %s", secret.Name, data.Login, password)
+
+	case entities.TypeCard:
+		var data entities.CardData
+		if json.Unmarshal([]byte(secret.Value), &data) != nil || len(data.Number) < 4 {
+			break
+		}
+		if full {
+			return fmt.Sprintf("%s [card] %s %s %s CVV %s", secret.Name, data.Number, data.Expiry, data.Holder, data.CVV)
+		}
+		return fmt.Sprintf("%s [card] %s%s %s %s", secret.Name,
+			strings.Repeat("*", len(data.Number)-4), data.Number[len(data.Number)-4:], data.Expiry, data.Holder)
+
+	case entities.TypeText:
+		return fmt.Sprintf("%s [text] %s", secret.Name, secret.Value)
+
+	case entities.TypeBinary:
+		var data entities.BinaryData
+		if json.Unmarshal([]byte(secret.Value), &data) != nil {
+			break
+		}
+		return fmt.Sprintf("%s [file] %s, %d байт", secret.Name, data.FileName, len(data.Data))
+
+	case entities.TypeBlob:
+		if ref := blobOf(secret); ref != nil {
+			return fmt.Sprintf("%s [file] %s, %d байт", secret.Name, ref.FileName, ref.Size)
+		}
+	}
+
+	return fmt.Sprintf("%s:%s", secret.Name, secret.Value)
+}
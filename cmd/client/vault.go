@@ -0,0 +1,192 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"GophKeeper.ru/internal/client/gcrypto"
+	"GophKeeper.ru/internal/entities"
+	"golang.org/x/exp/slog"
+)
+
+// unlock получает ключ хранилища по мастер-паролю. При первом входе
+// хранилище создаётся, устаревшие параметры KDF усиливаются.
+func (g *GophKeeper) unlock(password string) error {
+	v, err := g.fetchVault()
+	if err != nil {
+		return err
+	}
+
+	if v == nil {
+		return g.createVault(password)
+	}
+
+	kek, err := gcrypto.DeriveKey(password, v.KDF)
+	if err != nil {
+		slog.Error(err.Error(), "method:", "func (g *GophKeeper) unlock(password string) error")
+		return err
+	}
+
+	key, err := gcrypto.UnwrapKey(v.WrappedKey, kek)
+	if err != nil {
+		return errors.New("неверный мастер-пароль")
+	}
+	g.key = key
+
+	switch {
+	case key == password:
+		// Хранилища прежних версий клиента брали ключом сам прежний -k
+		g.legacyKey = password
+		fmt.Println("Ключ хранилища совпадает с мастер-паролем и не защищён Argon2id.",
+			"Смените его: rotate-key NEW_MASTER_PASSWORD")
+	case v.LegacyTag != "" && gcrypto.VerifyLegacyTag(v.LegacyTag, key):
+		g.legacyKey = password
+	}
+
+	if err = g.ensureKeyPair(v); err != nil {
+		slog.Warn("не удалось открыть ключ для обмена записями", "error", err)
+	}
+
+	if rot, err := g.fetchRotation(); err == nil && rot != nil {
+		fmt.Println("Смена ключа хранилища не завершена: запись данных заблокирована.",
+			"Повторите rotate-key с тем же паролем или отмените: rotate-abort")
+	}
+
+	if v.KDF.Version < gcrypto.CurrentKDFVersion {
+		if err = g.saveVault(password); err != nil {
+			slog.Warn("не удалось обновить параметры KDF", "error", err)
+		}
+	}
+
+	return nil
+}
+
+// createVault создаёт хранилище со случайным ключом. Если на сервере уже
+// есть записи, зашифрованные прежним -k, он остаётся отдельным ключом для
+// их расшифровки, пока записи не перенесены в новый формат.
+func (g *GophKeeper) createVault(password string) error {
+	legacy, err := g.hasRecords()
+	if err != nil {
+		return err
+	}
+
+	if g.key, err = gcrypto.NewVaultKey(); err != nil {
+		return err
+	}
+	if legacy {
+		g.legacyKey = password
+	}
+
+	if err = g.saveVault(password); err != nil {
+		return err
+	}
+
+	if err = g.ensureKeyPair(&entities.Vault{}); err != nil {
+		return err
+	}
+
+	return g.NewRecoveryKey()
+}
+
+// saveVault оборачивает текущий ключ хранилища мастер-паролем
+// с актуальными параметрами KDF и сохраняет на сервере
+func (g *GophKeeper) saveVault(password string) error {
+	v, err := wrapVault(g.key, password)
+	if err != nil {
+		return err
+	}
+	if g.legacyKey != "" {
+		v.LegacyTag = gcrypto.LegacyTag(g.key)
+	}
+
+	return g.postJSON("/api/vault", v, nil)
+}
+
+// wrapVault оборачивает ключ хранилища мастер-паролем с новой солью
+func wrapVault(key, password string) (*entities.Vault, error) {
+	params, err := gcrypto.NewKDFParams()
+	if err != nil {
+		return nil, err
+	}
+
+	kek, err := gcrypto.DeriveKey(password, params)
+	if err != nil {
+		return nil, err
+	}
+
+	wrapped, err := gcrypto.WrapKey(key, kek)
+	if err != nil {
+		return nil, err
+	}
+
+	return &entities.Vault{
+		KDF:        params,
+		WrappedKey: wrapped,
+		KeyID:      gcrypto.KeyID(key),
+	}, nil
+}
+
+// fetchVault хранилище с сервера, nil — ещё не создано
+func (g *GophKeeper) fetchVault() (*entities.Vault, error) {
+	req, err := http.NewRequest(http.MethodGet, "https://"+g.addr+"/api/vault", nil)
+	if err != nil {
+		slog.Error(err.Error(), "method:", "func (g *GophKeeper) fetchVault() (*entities.Vault, error)")
+		return nil, err
+	}
+
+	resp, err := g.Client.Do(req)
+	if err != nil {
+		slog.Error(err.Error(), "method:", "func (g *GophKeeper) fetchVault() (*entities.Vault, error)")
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		err = fmt.Errorf("%v", resp.StatusCode)
+		slog.Error(err.Error(), "method:", "func (g *GophKeeper) fetchVault() (*entities.Vault, error)")
+		return nil, err
+	}
+
+	var v entities.Vault
+	if err = json.NewDecoder(resp.Body).Decode(&v); err != nil {
+		return nil, err
+	}
+
+	return &v, nil
+}
+
+// hasRecords есть ли у пользователя записи на сервере
+func (g *GophKeeper) hasRecords() (bool, error) {
+	req, err := http.NewRequest(http.MethodGet, "https://"+g.addr+"/api/data", nil)
+	if err != nil {
+		return false, err
+	}
+
+	resp, err := g.Client.Do(req)
+	if err != nil {
+		slog.Error(err.Error(), "method:", "func (g *GophKeeper) hasRecords() (bool, error)")
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNoContent {
+		return false, nil
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("%v", resp.StatusCode)
+	}
+
+	var update entities.Update
+	if err = json.NewDecoder(resp.Body).Decode(&update); err != nil {
+		return false, err
+	}
+
+	return len(update.Data) > 0, nil
+}
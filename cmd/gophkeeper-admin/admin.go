@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"os"
+	"strings"
+
+	"GophKeeper.ru/internal/entities"
+	"GophKeeper.ru/internal/utils"
+)
+
+// AdminClient клиент административного API
+type AdminClient struct {
+	client *http.Client
+	addr   string
+}
+
+// NewAdminClient - конструктор. clientCert и clientKey — сертификат клиента
+// для mTLS, пустые — без него
+func NewAdminClient(addr, certFile, clientCert, clientKey string) (*AdminClient, error) {
+	cert, err := os.ReadFile(certFile)
+	if err != nil {
+		return nil, err
+	}
+
+	certPool := x509.NewCertPool()
+	if ok := certPool.AppendCertsFromPEM(cert); !ok {
+		return nil, fmt.Errorf("unable to parse cert from %s", certFile)
+	}
+
+	tlsConfig := &tls.Config{RootCAs: certPool}
+
+	// Клиентский сертификат для mTLS
+	if clientCert != "" {
+		pair, err := tls.LoadX509KeyPair(clientCert, clientKey)
+		if err != nil {
+			return nil, fmt.Errorf("не удалось загрузить сертификат клиента: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{pair}
+	}
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AdminClient{
+		addr: addr,
+		client: &http.Client{
+			Transport: &http.Transport{
+				TLSClientConfig: tlsConfig,
+			},
+			Jar: jar,
+		},
+	}, nil
+}
+
+// Login вход администратора, при включённой 2FA запрашивает код
+func (a *AdminClient) Login(login, password string) error {
+	var challenge struct {
+		MFAToken string `json:"mfa_token"`
+	}
+	status, err := a.do(http.MethodPost, "/api/auth", entities.User{
+		Login:    login,
+		Password: utils.Sha256hash(password),
+	}, &challenge)
+	if err != nil {
+		return err
+	}
+
+	if status != http.StatusAccepted {
+		return nil
+	}
+
+	fmt.Print("Код 2FA: ")
+	code, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return err
+	}
+
+	_, err = a.do(http.MethodPost, "/api/auth/2fa", map[string]string{
+		"mfa_token": challenge.MFAToken,
+		"code":      strings.TrimSpace(code),
+	}, nil)
+	return err
+}
+
+// Users список пользователей
+func (a *AdminClient) Users(path string) error {
+	var users []entities.User
+	if _, err := a.do(http.MethodGet, path, nil, &users); err != nil {
+		return err
+	}
+
+	fmt.Printf("%-6s %-32s %-6s %-9s %s\n", "ID", "LOGIN", "ADMIN", "DISABLED", "LOCKED UNTIL")
+	for _, u := range users {
+		locked := "-"
+		if u.LockedUntil != nil {
+			locked = u.LockedUntil.Format("2006-01-02 15:04:05")
+		}
+		fmt.Printf("%-6d %-32s %-6t %-9t %s\n", u.ID, u.Login, u.IsAdmin, u.IsDisable, locked)
+	}
+	return nil
+}
+
+// Create создание пользователя
+func (a *AdminClient) Create(login, password string, admin bool) error {
+	var out struct {
+		ID int `json:"id"`
+	}
+	_, err := a.do(http.MethodPost, "/api/admin/users", entities.User{
+		Login:    login,
+		Password: utils.Sha256hash(password),
+		IsAdmin:  admin,
+	}, &out)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Создан пользователь", login, "ID", out.ID)
+	return nil
+}
+
+// Action действие над пользователем по ID
+func (a *AdminClient) Action(method, id, action string, body any) error {
+	path := "/api/admin/users/" + id
+	if action != "" {
+		path += "/" + action
+	}
+
+	_, err := a.do(method, path, body, nil)
+	return err
+}
+
+// do выполняет запрос и разбирает JSON-ответ, ошибки API возвращает текстом
+func (a *AdminClient) do(method, path string, in, out any) (int, error) {
+	var body bytes.Buffer
+	if in != nil {
+		if err := json.NewEncoder(&body).Encode(in); err != nil {
+			return 0, err
+		}
+	}
+
+	req, err := http.NewRequest(method, "https://"+a.addr+path, &body)
+	if err != nil {
+		return 0, err
+	}
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		json.NewDecoder(resp.Body).Decode(&apiErr)
+		if apiErr.Error != "" {
+			return resp.StatusCode, fmt.Errorf("%s: %s", resp.Status, apiErr.Error)
+		}
+		return resp.StatusCode, fmt.Errorf("%s", resp.Status)
+	}
+
+	if out != nil {
+		if err = json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return resp.StatusCode, err
+		}
+	}
+
+	return resp.StatusCode, nil
+}
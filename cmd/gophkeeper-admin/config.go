@@ -0,0 +1,74 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"os"
+)
+
+type Config struct {
+	AddrServer string
+	CertFile   string
+	ClientCert string
+	ClientKey  string
+	Username   string
+	Password   string
+	Args       []string
+}
+
+// GetConfig конфиг утилиты администрирования: флаги и переменные окружения
+func GetConfig() (*Config, error) {
+	cfg := &Config{}
+
+	flag.StringVar(&cfg.AddrServer, "a", "localhost:8080", "server address")
+	flag.StringVar(&cfg.CertFile, "cert", "", "path to trusted CA certificate")
+	flag.StringVar(&cfg.Username, "user", "", "admin login")
+	flag.StringVar(&cfg.Password, "pass", "", "admin password")
+	flag.StringVar(&cfg.ClientCert, "client-cert", "", "path to client certificate for mTLS")
+	flag.StringVar(&cfg.ClientKey, "client-key", "", "path to client private key for mTLS")
+	flag.Usage = usage
+	flag.Parse()
+	cfg.Args = flag.Args()
+
+	if envPassword := os.Getenv("GOPHKEEPER_ADMIN_PASSWORD"); cfg.Password == "" && envPassword != "" {
+		cfg.Password = envPassword
+	}
+
+	if _, _, err := net.SplitHostPort(cfg.AddrServer); err != nil {
+		return nil, fmt.Errorf("неверный адрес сервера: %w", err)
+	}
+
+	if cfg.Username == "" || cfg.Password == "" || cfg.CertFile == "" {
+		return nil, fmt.Errorf("не заданы -user, -pass или -cert")
+	}
+
+	if (cfg.ClientCert == "") != (cfg.ClientKey == "") {
+		return nil, fmt.Errorf("сертификат клиента и ключ задаются вместе")
+	}
+
+	if len(cfg.Args) == 0 {
+		return nil, fmt.Errorf("не указана команда")
+	}
+
+	return cfg, nil
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, `Использование: gophkeeper-admin -cert CA -user LOGIN -pass PASSWORD КОМАНДА
+
+Если сервер требует сертификат клиента (mtls), добавьте -client-cert и -client-key.
+
+Команды:
+  users                      список пользователей
+  lockouts                   заблокированные после перебора паролей
+  create LOGIN PASSWORD      создать пользователя (добавьте admin для роли администратора)
+  disable ID                 отключить пользователя и завершить его сессии
+  enable ID                  включить пользователя и снять блокировку входа
+  passwd ID PASSWORD         задать новый пароль
+  cert ID TYPE:VALUE         привязать сертификат клиента: uri:, email:, dns:, cn: или subject: ("-" отвязывает)
+  delete ID                  удалить пользователя вместе с его данными
+
+Флаги:`)
+	flag.PrintDefaults()
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+
+	"GophKeeper.ru/internal/utils"
+)
+
+func main() {
+	config, err := GetConfig()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		usage()
+		os.Exit(2)
+	}
+
+	admin, err := NewAdminClient(config.AddrServer, config.CertFile, config.ClientCert, config.ClientKey)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	if err = admin.Login(config.Username, config.Password); err != nil {
+		fmt.Fprintln(os.Stderr, "Ошибка авторизации:", err)
+		os.Exit(1)
+	}
+
+	if err = run(admin, config.Args); err != nil {
+		fmt.Fprintln(os.Stderr, "Ошибка:", err)
+		os.Exit(1)
+	}
+}
+
+// run выполняет команду администратора
+func run(admin *AdminClient, args []string) error {
+	cmd, args := args[0], args[1:]
+
+	switch {
+	case cmd == "users" && len(args) == 0:
+		return admin.Users("/api/admin/users")
+
+	case cmd == "lockouts" && len(args) == 0:
+		return admin.Users("/api/admin/lockouts")
+
+	case cmd == "create" && (len(args) == 2 || len(args) == 3 && args[2] == "admin"):
+		return admin.Create(args[0], args[1], len(args) == 3)
+
+	case cmd == "disable" && len(args) == 1:
+		return done(admin.Action(http.MethodPost, args[0], "disable", nil))
+
+	case cmd == "enable" && len(args) == 1:
+		return done(admin.Action(http.MethodPost, args[0], "enable", nil))
+
+	case cmd == "passwd" && len(args) == 2:
+		return done(admin.Action(http.MethodPost, args[0], "password",
+			map[string]string{"password": utils.Sha256hash(args[1])}))
+
+	case cmd == "cert" && len(args) == 2:
+		identity := args[1]
+		if identity == "-" {
+			identity = ""
+		}
+		return done(admin.Action(http.MethodPost, args[0], "cert", map[string]string{"identity": identity}))
+
+	case cmd == "delete" && len(args) == 1:
+		return done(admin.Action(http.MethodDelete, args[0], "", nil))
+	}
+
+	usage()
+	return fmt.Errorf("неизвестная команда или неверные аргументы: %s", cmd)
+}
+
+func done(err error) error {
+	if err == nil {
+		fmt.Println("Готово")
+	}
+	return err
+}
@@ -6,17 +6,43 @@ import (
 	"fmt"
 	"net"
 	"os"
+	"strconv"
+	"time"
 
 	"log/slog"
 )
 
 type Config struct {
-	AddrServer    string `json:"addr_server,omitempty"`
-	AddrDatabase  string `json:"database_dsn,omitempty"`
-	LogLevel      string `json:"log_level,omitempty"`
-	SecretKey     string `json:"key,omitempty"`
-	CryptoKey     string `json:"crypto_key,omitempty"`
-	TrustedSubnet string `json:"trusted_subnet,omitempty"`
+	AddrServer    string        `json:"addr_server,omitempty"`
+	AddrDatabase  string        `json:"database_dsn,omitempty"`
+	LogLevel      string        `json:"log_level,omitempty"`
+	SecretKey     string        `json:"key,omitempty"`
+	CryptoKey     string        `json:"crypto_key,omitempty"`
+	TrustedSubnet string        `json:"trusted_subnet,omitempty"`
+	Argon2Time    uint          `json:"argon2_time,omitempty"`
+	Argon2Memory  uint          `json:"argon2_memory,omitempty"`
+	Argon2Threads uint          `json:"argon2_threads,omitempty"`
+	JWTIssuer     string        `json:"jwt_issuer,omitempty"`
+	JWTAudience   string        `json:"jwt_audience,omitempty"`
+	AccessTTL     time.Duration `json:"access_ttl,omitempty"`
+	RefreshTTL    time.Duration `json:"refresh_ttl,omitempty"`
+	LoginAttempts int           `json:"login_attempts,omitempty"`
+	LockoutBase   time.Duration `json:"lockout_base,omitempty"`
+	LockoutMax    time.Duration `json:"lockout_max,omitempty"`
+	Reencrypt     time.Duration `json:"reencrypt_interval,omitempty"`
+	SubnetForAll  bool          `json:"trusted_subnet_all,omitempty"`
+	TrustProxies  string        `json:"trusted_proxies,omitempty"`
+	AdminLogin    string        `json:"admin_login,omitempty"`
+	ClientCA      string        `json:"client_ca,omitempty"`
+	MTLSMode      string        `json:"mtls_mode,omitempty"`
+	BlobDir       string        `json:"blob_dir,omitempty"`
+	MaxBlobSize   int64         `json:"max_blob_size,omitempty"`
+	MaxUserBlobs  int64         `json:"max_user_blob_bytes,omitempty"`
+	MaxUploads    int           `json:"max_open_uploads,omitempty"`
+	UploadTTL     time.Duration `json:"blob_upload_ttl,omitempty"`
+	HistoryKeep   int           `json:"history_versions,omitempty"`
+	HistoryAge    time.Duration `json:"history_age,omitempty"`
+	TrashAge      time.Duration `json:"trash_retention,omitempty"`
 }
 
 // GetConfig() получить конфиг сервера
@@ -39,11 +65,101 @@ func GetConfig() (*Config, error) {
 		cfg.LogLevel = "info"
 	}
 
+	if cfg.AccessTTL == 0 {
+		cfg.AccessTTL = 15 * time.Minute
+	}
+
+	if cfg.RefreshTTL == 0 {
+		cfg.RefreshTTL = 30 * 24 * time.Hour
+	}
+
+	if cfg.Reencrypt == 0 {
+		cfg.Reencrypt = time.Minute
+	}
+
+	if cfg.BlobDir == "" {
+		cfg.BlobDir = "./blobs"
+	}
+
+	if cfg.MaxBlobSize == 0 {
+		cfg.MaxBlobSize = 100 << 20
+	}
+
+	if cfg.MaxUserBlobs == 0 {
+		cfg.MaxUserBlobs = 1 << 30
+	}
+
+	if cfg.MaxUploads == 0 {
+		cfg.MaxUploads = 10
+	}
+
+	if cfg.UploadTTL == 0 {
+		cfg.UploadTTL = 24 * time.Hour
+	}
+
+	if cfg.HistoryKeep == 0 {
+		cfg.HistoryKeep = 10
+	}
+
+	if cfg.TrashAge == 0 {
+		cfg.TrashAge = 30 * 24 * time.Hour
+	}
+
+	if cfg.JWTIssuer == "" {
+		cfg.JWTIssuer = "GophKeeper"
+	}
+
+	if cfg.JWTAudience == "" {
+		cfg.JWTAudience = "GophKeeper"
+	}
+
 	_, _, err := net.SplitHostPort(cfg.AddrServer)
 	if err != nil {
 		return nil, fmt.Errorf("неверный адрес сервера: %w", err)
 	}
 
+	if cfg.MTLSMode == "" {
+		cfg.MTLSMode = "off"
+	}
+
+	switch cfg.MTLSMode {
+	case "off":
+	case "optional", "require":
+		if cfg.ClientCA == "" {
+			return nil, fmt.Errorf("для mTLS задайте -client-ca")
+		}
+	default:
+		return nil, fmt.Errorf("неизвестный режим mTLS %q: off, optional или require", cfg.MTLSMode)
+	}
+
+	if cfg.SubnetForAll && cfg.TrustedSubnet == "" {
+		return nil, fmt.Errorf("для проверки подсети на всём API задайте -t")
+	}
+
+	if cfg.HistoryKeep < 0 || cfg.HistoryAge < 0 {
+		return nil, fmt.Errorf("срок и число версий истории не могут быть отрицательными")
+	}
+
+	if cfg.TrashAge < 0 {
+		return nil, fmt.Errorf("срок хранения корзины не может быть отрицательным")
+	}
+
+	if cfg.Reencrypt < 0 {
+		return nil, fmt.Errorf("интервал перешифровки не может быть отрицательным")
+	}
+
+	if cfg.MaxBlobSize < 0 {
+		return nil, fmt.Errorf("предельный размер двоичных данных не может быть отрицательным")
+	}
+
+	if cfg.MaxUserBlobs < 0 || cfg.MaxUploads < 0 || cfg.UploadTTL < 0 {
+		return nil, fmt.Errorf("квота и срок незавершённых загрузок не могут быть отрицательными")
+	}
+
+	if cfg.Argon2Threads > 255 {
+		return nil, fmt.Errorf("argon2_threads должно быть не больше 255")
+	}
+
 	return cfg, nil
 }
 
@@ -52,9 +168,33 @@ func parseFlags(cfg *Config) {
 	flag.StringVar(&cfg.AddrServer, "a", "", "server and port to run server")
 	flag.StringVar(&cfg.AddrDatabase, "d", "", "address to postgres base")
 	flag.StringVar(&cfg.LogLevel, "l", "", "log level")
-	flag.StringVar(&cfg.SecretKey, "k", "", "secret key for encryption")
-	flag.StringVar(&cfg.CryptoKey, "crypto-key", "", "privat key")
-	flag.StringVar(&cfg.TrustedSubnet, "t", "", "sunbnet clients")
+	flag.StringVar(&cfg.SecretKey, "k", "", "JWT signing secret or list kid1:secret1,kid2:secret2 (first signs)")
+	flag.StringVar(&cfg.CryptoKey, "crypto-key", "", "path to keyring for data at rest: kid:hex per line, first is active")
+	flag.StringVar(&cfg.TrustedSubnet, "t", "", "trusted client subnets, comma separated CIDR")
+	flag.BoolVar(&cfg.SubnetForAll, "t-all", false, "apply trusted subnet check to the whole API")
+	flag.StringVar(&cfg.TrustProxies, "trusted-proxies", "", "proxies allowed to set X-Real-IP/X-Forwarded-For")
+	flag.StringVar(&cfg.ClientCA, "client-ca", "", "CA bundle for client certificates")
+	flag.StringVar(&cfg.MTLSMode, "mtls", "", "client certificates: off, optional or require")
+	flag.StringVar(&cfg.AdminLogin, "admin", "", "grant admin role to this existing login on start")
+	flag.StringVar(&cfg.JWTIssuer, "jwt-issuer", "", "JWT iss claim")
+	flag.StringVar(&cfg.JWTAudience, "jwt-audience", "", "JWT aud claim")
+	flag.DurationVar(&cfg.AccessTTL, "access-ttl", 0, "access token lifetime")
+	flag.DurationVar(&cfg.RefreshTTL, "refresh-ttl", 0, "refresh token lifetime")
+	flag.IntVar(&cfg.LoginAttempts, "login-attempts", 0, "failed logins before lockout")
+	flag.DurationVar(&cfg.LockoutBase, "lockout-base", 0, "first lockout, doubles on each next failure")
+	flag.DurationVar(&cfg.LockoutMax, "lockout-max", 0, "maximum lockout")
+	flag.DurationVar(&cfg.Reencrypt, "reencrypt-interval", 0, "how often to re-encrypt data with the active server key")
+	flag.StringVar(&cfg.BlobDir, "blob-dir", "", "directory for encrypted chunks of large binary records")
+	flag.Int64Var(&cfg.MaxBlobSize, "max-blob-size", 0, "maximum size of one binary record in bytes")
+	flag.Int64Var(&cfg.MaxUserBlobs, "max-user-blob-bytes", 0, "maximum total size of binary records of one user in bytes")
+	flag.IntVar(&cfg.MaxUploads, "max-open-uploads", 0, "maximum unfinished uploads of one user")
+	flag.DurationVar(&cfg.UploadTTL, "blob-upload-ttl", 0, "drop unfinished uploads older than this")
+	flag.IntVar(&cfg.HistoryKeep, "history-versions", 0, "previous versions kept for each record")
+	flag.DurationVar(&cfg.HistoryAge, "history-age", 0, "drop previous versions older than this, 0 keeps them")
+	flag.DurationVar(&cfg.TrashAge, "trash-retention", 0, "purge deleted records after this time in trash")
+	flag.UintVar(&cfg.Argon2Time, "argon2-time", 0, "argon2id iterations")
+	flag.UintVar(&cfg.Argon2Memory, "argon2-memory", 0, "argon2id memory in KiB")
+	flag.UintVar(&cfg.Argon2Threads, "argon2-threads", 0, "argon2id parallelism")
 	flag.Parse()
 }
 
@@ -83,6 +223,102 @@ func parseEnv(cfg *Config) {
 	if envTrustedSubnet := os.Getenv("TRUSTED_SUBNET"); envTrustedSubnet != "" {
 		cfg.TrustedSubnet = envTrustedSubnet
 	}
+
+	if v, err := strconv.ParseBool(os.Getenv("TRUSTED_SUBNET_ALL")); err == nil {
+		cfg.SubnetForAll = v
+	}
+
+	if envTrustProxies := os.Getenv("TRUSTED_PROXIES"); envTrustProxies != "" {
+		cfg.TrustProxies = envTrustProxies
+	}
+
+	if envClientCA := os.Getenv("CLIENT_CA"); envClientCA != "" {
+		cfg.ClientCA = envClientCA
+	}
+
+	if envMTLSMode := os.Getenv("MTLS_MODE"); envMTLSMode != "" {
+		cfg.MTLSMode = envMTLSMode
+	}
+
+	if envAdminLogin := os.Getenv("ADMIN_LOGIN"); envAdminLogin != "" {
+		cfg.AdminLogin = envAdminLogin
+	}
+
+	if envJWTIssuer := os.Getenv("JWT_ISSUER"); envJWTIssuer != "" {
+		cfg.JWTIssuer = envJWTIssuer
+	}
+
+	if envJWTAudience := os.Getenv("JWT_AUDIENCE"); envJWTAudience != "" {
+		cfg.JWTAudience = envJWTAudience
+	}
+
+	if v, err := time.ParseDuration(os.Getenv("ACCESS_TTL")); err == nil {
+		cfg.AccessTTL = v
+	}
+
+	if v, err := time.ParseDuration(os.Getenv("REFRESH_TTL")); err == nil {
+		cfg.RefreshTTL = v
+	}
+
+	if v, err := strconv.Atoi(os.Getenv("LOGIN_ATTEMPTS")); err == nil {
+		cfg.LoginAttempts = v
+	}
+
+	if v, err := time.ParseDuration(os.Getenv("LOCKOUT_BASE")); err == nil {
+		cfg.LockoutBase = v
+	}
+
+	if v, err := time.ParseDuration(os.Getenv("LOCKOUT_MAX")); err == nil {
+		cfg.LockoutMax = v
+	}
+
+	if v, err := time.ParseDuration(os.Getenv("REENCRYPT_INTERVAL")); err == nil {
+		cfg.Reencrypt = v
+	}
+
+	if envBlobDir := os.Getenv("BLOB_DIR"); envBlobDir != "" {
+		cfg.BlobDir = envBlobDir
+	}
+
+	if v, err := strconv.ParseInt(os.Getenv("MAX_BLOB_SIZE"), 10, 64); err == nil {
+		cfg.MaxBlobSize = v
+	}
+
+	if v, err := strconv.ParseInt(os.Getenv("MAX_USER_BLOB_BYTES"), 10, 64); err == nil {
+		cfg.MaxUserBlobs = v
+	}
+
+	if v, err := strconv.Atoi(os.Getenv("MAX_OPEN_UPLOADS")); err == nil {
+		cfg.MaxUploads = v
+	}
+
+	if v, err := time.ParseDuration(os.Getenv("BLOB_UPLOAD_TTL")); err == nil {
+		cfg.UploadTTL = v
+	}
+
+	if v, err := strconv.Atoi(os.Getenv("HISTORY_VERSIONS")); err == nil {
+		cfg.HistoryKeep = v
+	}
+
+	if v, err := time.ParseDuration(os.Getenv("HISTORY_AGE")); err == nil {
+		cfg.HistoryAge = v
+	}
+
+	if v, err := time.ParseDuration(os.Getenv("TRASH_RETENTION")); err == nil {
+		cfg.TrashAge = v
+	}
+
+	if v, err := strconv.ParseUint(os.Getenv("ARGON2_TIME"), 10, 32); err == nil {
+		cfg.Argon2Time = uint(v)
+	}
+
+	if v, err := strconv.ParseUint(os.Getenv("ARGON2_MEMORY"), 10, 32); err == nil {
+		cfg.Argon2Memory = uint(v)
+	}
+
+	if v, err := strconv.ParseUint(os.Getenv("ARGON2_THREADS"), 10, 8); err == nil {
+		cfg.Argon2Threads = uint(v)
+	}
 }
 
 // parseConfig Чтение JSON-конфига
@@ -115,7 +351,79 @@ func parseConfig(cfg *Config) {
 	if flag.Lookup("crypto-key").Value.String() == "" {
 		cfg.CryptoKey = tmpCfg.CryptoKey
 	}
-	if flag.Lookup("trusted_subnet").Value.String() == "" {
+	if flag.Lookup("t").Value.String() == "" {
 		cfg.TrustedSubnet = tmpCfg.TrustedSubnet
 	}
+	if flag.Lookup("t-all").Value.String() == "false" {
+		cfg.SubnetForAll = tmpCfg.SubnetForAll
+	}
+	if flag.Lookup("trusted-proxies").Value.String() == "" {
+		cfg.TrustProxies = tmpCfg.TrustProxies
+	}
+	if flag.Lookup("client-ca").Value.String() == "" {
+		cfg.ClientCA = tmpCfg.ClientCA
+	}
+	if flag.Lookup("mtls").Value.String() == "" {
+		cfg.MTLSMode = tmpCfg.MTLSMode
+	}
+	if flag.Lookup("admin").Value.String() == "" {
+		cfg.AdminLogin = tmpCfg.AdminLogin
+	}
+	if flag.Lookup("jwt-issuer").Value.String() == "" {
+		cfg.JWTIssuer = tmpCfg.JWTIssuer
+	}
+	if flag.Lookup("jwt-audience").Value.String() == "" {
+		cfg.JWTAudience = tmpCfg.JWTAudience
+	}
+	if flag.Lookup("access-ttl").Value.String() == "0s" {
+		cfg.AccessTTL = tmpCfg.AccessTTL
+	}
+	if flag.Lookup("refresh-ttl").Value.String() == "0s" {
+		cfg.RefreshTTL = tmpCfg.RefreshTTL
+	}
+	if flag.Lookup("login-attempts").Value.String() == "0" {
+		cfg.LoginAttempts = tmpCfg.LoginAttempts
+	}
+	if flag.Lookup("lockout-base").Value.String() == "0s" {
+		cfg.LockoutBase = tmpCfg.LockoutBase
+	}
+	if flag.Lookup("lockout-max").Value.String() == "0s" {
+		cfg.LockoutMax = tmpCfg.LockoutMax
+	}
+	if flag.Lookup("reencrypt-interval").Value.String() == "0s" {
+		cfg.Reencrypt = tmpCfg.Reencrypt
+	}
+	if flag.Lookup("blob-dir").Value.String() == "" {
+		cfg.BlobDir = tmpCfg.BlobDir
+	}
+	if flag.Lookup("max-blob-size").Value.String() == "0" {
+		cfg.MaxBlobSize = tmpCfg.MaxBlobSize
+	}
+	if flag.Lookup("max-user-blob-bytes").Value.String() == "0" {
+		cfg.MaxUserBlobs = tmpCfg.MaxUserBlobs
+	}
+	if flag.Lookup("max-open-uploads").Value.String() == "0" {
+		cfg.MaxUploads = tmpCfg.MaxUploads
+	}
+	if flag.Lookup("blob-upload-ttl").Value.String() == "0s" {
+		cfg.UploadTTL = tmpCfg.UploadTTL
+	}
+	if flag.Lookup("history-versions").Value.String() == "0" {
+		cfg.HistoryKeep = tmpCfg.HistoryKeep
+	}
+	if flag.Lookup("history-age").Value.String() == "0s" {
+		cfg.HistoryAge = tmpCfg.HistoryAge
+	}
+	if flag.Lookup("trash-retention").Value.String() == "0s" {
+		cfg.TrashAge = tmpCfg.TrashAge
+	}
+	if flag.Lookup("argon2-time").Value.String() == "0" {
+		cfg.Argon2Time = tmpCfg.Argon2Time
+	}
+	if flag.Lookup("argon2-memory").Value.String() == "0" {
+		cfg.Argon2Memory = tmpCfg.Argon2Memory
+	}
+	if flag.Lookup("argon2-threads").Value.String() == "0" {
+		cfg.Argon2Threads = tmpCfg.Argon2Threads
+	}
 }
@@ -1,20 +1,68 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
 
+	"GophKeeper.ru/internal/entities"
+	"GophKeeper.ru/internal/server/blobstore"
+	"GophKeeper.ru/internal/server/hasher"
 	server "GophKeeper.ru/internal/server/http"
+	"GophKeeper.ru/internal/server/http/middlewares"
+	"GophKeeper.ru/internal/server/keyring"
+	"GophKeeper.ru/internal/server/limiter"
 	"GophKeeper.ru/internal/server/storage"
 )
 
+// reencryptBatch строк за одну транзакцию фонового перешифрования
+const reencryptBatch = 100
+
+// cleanupInterval как часто удаляются устаревшие данные
+const cleanupInterval = time.Hour
+
 type Keeper struct {
-	databse *storage.Database
-	server  *server.Server
+	databse    *storage.Database
+	server     *server.Server
+	blobs      blobstore.Store
+	reencrypt  time.Duration
+	historyAge time.Duration
+	trashAge   time.Duration
+	uploadTTL  time.Duration
+
+	// ctx фоновых задач, stop завершает их при остановке сервера
+	ctx  context.Context
+	stop context.CancelFunc
 }
 
-// NewKeeper(addrDatabase)  (*Keeper, error) конструктор сервера хранилища
-func NewKeeper(addrDatabase string) (*Keeper, error) {
-	db, err := storage.New(addrDatabase)
+// NewKeeper(cfg)  (*Keeper, error) конструктор сервера хранилища
+func NewKeeper(cfg *Config) (*Keeper, error) {
+	keys, activeKID, err := entities.ParseSigningKeys(cfg.SecretKey)
+	if err != nil {
+		return nil, err
+	}
+
+	subnets, err := middlewares.ParseSubnets(cfg.TrustedSubnet)
+	if err != nil {
+		return nil, fmt.Errorf("error parse trusted subnet %s", err)
+	}
+
+	var proxies []string
+	if cfg.TrustProxies != "" {
+		proxies = strings.Split(cfg.TrustProxies, ",")
+	}
+
+	clientCAs, clientAuth, err := clientTLS(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := storage.New(cfg.AddrDatabase)
 	if err != nil {
 		return nil, fmt.Errorf("error connect database %s", err)
 	}
@@ -24,23 +72,234 @@ func NewKeeper(addrDatabase string) (*Keeper, error) {
 	}
 	defer db.Stop()
 
-	s, err := server.New(db)
+	if cfg.CryptoKey != "" {
+		keys, err := keyring.Load(cfg.CryptoKey)
+		if err != nil {
+			return nil, fmt.Errorf("error load server keyring %s", err)
+		}
+		db.SetKeyring(keys)
+	}
+
+	db.SetHistoryVersions(cfg.HistoryKeep)
+
+	if cfg.AdminLogin != "" {
+		if err = db.SetAdmin(context.Background(), cfg.AdminLogin, true); err != nil {
+			return nil, fmt.Errorf("error grant admin role to %s: %s", cfg.AdminLogin, err)
+		}
+	}
+
+	blobs := blobstore.NewFS(cfg.BlobDir)
+
+	h := hasher.New(uint32(cfg.Argon2Time), uint32(cfg.Argon2Memory), uint8(cfg.Argon2Threads))
+
+	s, err := server.New(db, server.Options{
+		Hasher: h,
+		Tokens: &entities.TokenConfig{
+			Keys:       keys,
+			ActiveKID:  activeKID,
+			Issuer:     cfg.JWTIssuer,
+			Audience:   cfg.JWTAudience,
+			TTL:        cfg.AccessTTL,
+			RefreshTTL: cfg.RefreshTTL,
+		},
+		Logins: limiter.NewMemory(limiter.Config{
+			FreeAttempts: cfg.LoginAttempts,
+			BaseDelay:    cfg.LockoutBase,
+			MaxDelay:     cfg.LockoutMax,
+		}),
+		TrustedSubnets: subnets,
+		TrustedProxies: proxies,
+		SubnetForAll:   cfg.SubnetForAll,
+		ClientCAs:      clientCAs,
+		ClientAuth:     clientAuth,
+		Blobs:          blobs,
+		MaxBlobSize:    cfg.MaxBlobSize,
+		BlobQuota: entities.BlobQuota{
+			MaxBytes: cfg.MaxUserBlobs,
+			MaxOpen:  cfg.MaxUploads,
+		},
+	})
 
 	if err != nil {
 		return nil, fmt.Errorf("error create server %s", err)
 	}
 
+	ctx, stop := context.WithCancel(context.Background())
+
 	return &Keeper{
-		databse: db,
-		server:  s}, nil
+		ctx:        ctx,
+		stop:       stop,
+		databse:    db,
+		server:     s,
+		blobs:      blobs,
+		reencrypt:  cfg.Reencrypt,
+		historyAge: cfg.HistoryAge,
+		trashAge:   cfg.TrashAge,
+		uploadTTL:  cfg.UploadTTL}, nil
+}
+
+// clientTLS загружает УЦ сертификатов клиентов и режим mTLS из конфига
+func clientTLS(cfg *Config) (*x509.CertPool, tls.ClientAuthType, error) {
+	var mode tls.ClientAuthType
+	switch cfg.MTLSMode {
+	case "optional":
+		mode = tls.VerifyClientCertIfGiven
+	case "require":
+		mode = tls.RequireAndVerifyClientCert
+	default:
+		return nil, tls.NoClientCert, nil
+	}
+
+	bundle, err := os.ReadFile(cfg.ClientCA)
+	if err != nil {
+		return nil, mode, fmt.Errorf("error read client CA %s", err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(bundle) {
+		return nil, mode, fmt.Errorf("no certificates in client CA %s", cfg.ClientCA)
+	}
+
+	return pool, mode, nil
 }
 
 // NewKeeper(addrDatabase)  (*Keeper, error) конструктор сервера хранилища
 func (k *Keeper) Run(addr string) error {
+	go k.reencryptLoop(k.ctx)
+	go k.cleanupLoop(k.ctx)
+
 	return k.server.Run(addr)
 }
 
+// reencryptLoop фоновое перешифрование data.value активным ключом сервера
+// после его смены. Строки берутся пачками, пока не закончатся.
+func (k *Keeper) reencryptLoop(ctx context.Context) {
+	for {
+		var (
+			cur   storage.ReencryptCursor
+			total int
+		)
+		for ctx.Err() == nil {
+			done, seen, err := k.databse.ReencryptValues(ctx, &cur, reencryptBatch)
+			if err != nil {
+				slog.Error("Re-encryption failed", "error", err, "method", "Keeper.reencryptLoop")
+				break
+			}
+			total += done
+			if seen < reencryptBatch {
+				break
+			}
+		}
+
+		if total > 0 {
+			slog.Info("Rows re-encrypted with active server key", "rows", total, "method", "Keeper.reencryptLoop")
+		}
+
+		if !sleep(ctx, k.reencrypt) {
+			return
+		}
+	}
+}
+
+// cleanupLoop периодически удаляет версии записей старше срока хранения,
+// окончательно удаляет записи, пролежавшие в корзине дольше срока, и
+// двоичные данные, на которые больше никто не ссылается, и брошенные загрузки
+func (k *Keeper) cleanupLoop(ctx context.Context) {
+	for {
+		k.purgeTrash(ctx)
+
+		if k.historyAge > 0 {
+			n, err := k.databse.PruneHistory(ctx, k.historyAge)
+			if err != nil {
+				slog.Error("History cleanup failed", "error", err, "method", "Keeper.cleanupLoop")
+			} else if n > 0 {
+				slog.Info("Old record versions removed", "rows", n, "method", "Keeper.cleanupLoop")
+			}
+		}
+
+		k.collectBlobs(ctx)
+		k.purgeUploads(ctx)
+
+		if !sleep(ctx, cleanupInterval) {
+			return
+		}
+	}
+}
+
+// sleep ждёт d или отмены ctx, false — задачу пора завершать
+func sleep(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
+
+// purgeTrash окончательно удаляет старые записи из корзины вместе с фрагментами их файлов
+func (k *Keeper) purgeTrash(ctx context.Context) {
+	n, blobs, err := k.databse.PurgeTrash(ctx, k.trashAge)
+	if err != nil {
+		slog.Error("Trash cleanup failed", "error", err, "method", "Keeper.purgeTrash")
+		return
+	}
+
+	// Строки уже удалены, оставшиеся файлы не мешают, только занимают место
+	for _, id := range blobs {
+		if err = k.blobs.Remove(ctx, id); err != nil {
+			slog.Error("Failed to remove blob chunks", "blob_id", id, "error", err, "method", "Keeper.purgeTrash")
+		}
+	}
+
+	if n > 0 {
+		slog.Info("Records purged from trash", "rows", n, "blobs", len(blobs), "method", "Keeper.purgeTrash")
+	}
+}
+
+// collectBlobs удаляет двоичные данные без ссылок из записей и истории,
+// а также завершённые загрузки, не попавшие в запись за срок загрузки
+func (k *Keeper) collectBlobs(ctx context.Context) {
+	blobs, err := k.databse.CollectBlobs(ctx, k.uploadTTL)
+	if err != nil {
+		slog.Error("Blob cleanup failed", "error", err, "method", "Keeper.collectBlobs")
+		return
+	}
+
+	for _, id := range blobs {
+		if err = k.blobs.Remove(ctx, id); err != nil {
+			slog.Error("Failed to remove blob chunks", "blob_id", id, "error", err, "method", "Keeper.collectBlobs")
+		}
+	}
+
+	if len(blobs) > 0 {
+		slog.Info("Unreferenced blobs removed", "blobs", len(blobs), "method", "Keeper.collectBlobs")
+	}
+}
+
+// purgeUploads удаляет загрузки, не завершённые за срок, вместе с фрагментами
+func (k *Keeper) purgeUploads(ctx context.Context) {
+	blobs, err := k.databse.PurgeStaleBlobs(ctx, k.uploadTTL)
+	if err != nil {
+		slog.Error("Upload cleanup failed", "error", err, "method", "Keeper.purgeUploads")
+		return
+	}
+
+	for _, id := range blobs {
+		if err = k.blobs.Remove(ctx, id); err != nil {
+			slog.Error("Failed to remove blob chunks", "blob_id", id, "error", err, "method", "Keeper.purgeUploads")
+		}
+	}
+
+	if len(blobs) > 0 {
+		slog.Info("Unfinished uploads removed", "blobs", len(blobs), "method", "Keeper.purgeUploads")
+	}
+}
+
 func (k *Keeper) Stop() error {
+	k.stop()
 	k.server.Stop()
 
 	return nil
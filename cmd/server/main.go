@@ -10,7 +10,7 @@ func main() {
 		panic(err)
 	}
 
-	keeper, err := NewKeeper(config.AddrDatabase)
+	keeper, err := NewKeeper(config)
 	if err != nil {
 		panic(err)
 	}
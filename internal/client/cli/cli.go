@@ -9,8 +9,37 @@ import (
 
 type Keeper interface {
 	Remove(key string) error
+	Trash() error
+	Undelete(name string) error
+	Purge(name string) error
 	UpdateRecord(key, value string) error
+	NewLogin(name, login, password string) error
+	NewCard(name, number, expiry, cvv, holder string) error
+	NewText(name, text string) error
+	NewFile(name, path string) error
+	Upload(name, path string) error
+	Download(name, path string) error
+	SetMeta(record, key, value string) error
+	RemoveMeta(record, key string) error
 	Print()
+	Get(name, path string) error
+	History(name string) error
+	Restore(name, version string) error
+	Sessions() error
+	Logout(id string) error
+	EnrollTwoFactor() error
+	ConfirmTwoFactor(code string) error
+	RotateKey(password string) error
+	AbortRotation() error
+	NewRecoveryKey() error
+	SplitKey(k, n int) error
+	Share(name, login string) error
+	Unshare(id string) error
+	MyShares() error
+	Shared() error
+	DownloadShared(name, path string) error
+	MyKey() error
+	ResetManifest() error
 }
 
 func ReadCmd(k Keeper) {
@@ -39,22 +68,292 @@ func ReadCmd(k Keeper) {
 			}
 			key := parts[1]
 			value := parts[2]
-			k.UpdateRecord(key, value)
+			if err := k.UpdateRecord(key, value); err != nil {
+				fmt.Println("Ошибка:", err)
+			}
+
+		case "new-login":
+			if len(parts) != 4 {
+				fmt.Println("Используйте: new-login KEY LOGIN PASSWORD")
+				continue
+			}
+			if err := k.NewLogin(parts[1], parts[2], parts[3]); err != nil {
+				fmt.Println("Ошибка:", err)
+			}
+
+		case "new-card":
+			if len(parts) < 6 {
+				fmt.Println("Используйте: new-card KEY NUMBER MM/YY CVV HOLDER")
+				continue
+			}
+			holder := strings.Join(parts[5:], " ")
+			if err := k.NewCard(parts[1], parts[2], parts[3], parts[4], holder); err != nil {
+				fmt.Println("Ошибка:", err)
+			}
+
+		case "new-text":
+			if len(parts) < 3 {
+				fmt.Println("Используйте: new-text KEY TEXT")
+				continue
+			}
+			// Текст берётся из строки как есть, с пробелами
+			text := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(input), parts[0]))
+			text = strings.TrimSpace(strings.TrimPrefix(text, parts[1]))
+			if err := k.NewText(parts[1], text); err != nil {
+				fmt.Println("Ошибка:", err)
+			}
+
+		case "new-file":
+			if len(parts) != 3 {
+				fmt.Println("Используйте: new-file KEY PATH")
+				continue
+			}
+			if err := k.NewFile(parts[1], parts[2]); err != nil {
+				fmt.Println("Ошибка:", err)
+			}
+
+		case "upload":
+			if len(parts) != 3 {
+				fmt.Println("Используйте: upload KEY PATH")
+				continue
+			}
+			if err := k.Upload(parts[1], parts[2]); err != nil {
+				fmt.Println("Ошибка:", err)
+			}
+
+		case "download":
+			if len(parts) != 3 {
+				fmt.Println("Используйте: download KEY PATH")
+				continue
+			}
+			if err := k.Download(parts[1], parts[2]); err != nil {
+				fmt.Println("Ошибка:", err)
+			}
+
+		case "meta":
+			var err error
+			switch {
+			case len(parts) >= 5 && parts[1] == "set":
+				err = k.SetMeta(parts[2], parts[3], strings.Join(parts[4:], " "))
+			case len(parts) == 4 && parts[1] == "rm":
+				err = k.RemoveMeta(parts[2], parts[3])
+			default:
+				fmt.Println("Используйте: meta set KEY NAME VALUE или meta rm KEY NAME")
+				continue
+			}
+			if err != nil {
+				fmt.Println("Ошибка:", err)
+			}
 
 		case "del":
 			if len(parts) != 2 {
 				fmt.Println("Используйте: del KEY")
+				continue
 			}
 			key := parts[1]
-			k.Remove(key)
+			if err := k.Remove(key); err != nil {
+				fmt.Println("Ошибка:", err)
+				continue
+			}
+			fmt.Println("Запись перемещена в корзину, вернуть её: undelete", key)
 			continue
 
+		case "trash":
+			if err := k.Trash(); err != nil {
+				fmt.Println("Ошибка:", err)
+			}
+
+		case "undelete":
+			if len(parts) != 2 {
+				fmt.Println("Используйте: undelete KEY")
+				continue
+			}
+			if err := k.Undelete(parts[1]); err != nil {
+				fmt.Println("Ошибка:", err)
+				continue
+			}
+			fmt.Println("Запись возвращена из корзины")
+
+		case "purge":
+			if len(parts) != 2 {
+				fmt.Println("Используйте: purge KEY")
+				continue
+			}
+			if err := k.Purge(parts[1]); err != nil {
+				fmt.Println("Ошибка:", err)
+				continue
+			}
+			fmt.Println("Запись удалена окончательно")
+
 		case "print":
 			k.Print()
 			continue
 
+		case "get":
+			if len(parts) != 2 && len(parts) != 3 {
+				fmt.Println("Используйте: get KEY [PATH]")
+				continue
+			}
+			path := ""
+			if len(parts) == 3 {
+				path = parts[2]
+			}
+			if err := k.Get(parts[1], path); err != nil {
+				fmt.Println("Ошибка:", err)
+			}
+
+		case "history":
+			if len(parts) != 2 {
+				fmt.Println("Используйте: history KEY")
+				continue
+			}
+			if err := k.History(parts[1]); err != nil {
+				fmt.Println("Ошибка:", err)
+			}
+
+		case "restore":
+			if len(parts) != 3 {
+				fmt.Println("Используйте: restore KEY VERSION")
+				continue
+			}
+			if err := k.Restore(parts[1], parts[2]); err != nil {
+				fmt.Println("Ошибка:", err)
+				continue
+			}
+			fmt.Println("Версия восстановлена")
+
+		case "sessions":
+			if err := k.Sessions(); err != nil {
+				fmt.Println("Ошибка:", err)
+			}
+
+		case "logout":
+			if len(parts) > 2 {
+				fmt.Println("Используйте: logout [SESSION_ID]")
+				continue
+			}
+			if len(parts) == 2 {
+				if err := k.Logout(parts[1]); err != nil {
+					fmt.Println("Ошибка:", err)
+					continue
+				}
+				fmt.Println("Сессия завершена")
+				continue
+			}
+			if err := k.Logout(""); err != nil {
+				fmt.Println("Ошибка:", err)
+				continue
+			}
+			fmt.Println("Вы вышли из GophKeeper")
+			os.Exit(0)
+
+		case "2fa":
+			var err error
+			switch {
+			case len(parts) == 2 && parts[1] == "enroll":
+				err = k.EnrollTwoFactor()
+			case len(parts) == 3 && parts[1] == "confirm":
+				err = k.ConfirmTwoFactor(parts[2])
+			default:
+				fmt.Println("Используйте: 2fa enroll или 2fa confirm CODE")
+				continue
+			}
+			if err != nil {
+				fmt.Println("Ошибка:", err)
+			}
+
+		case "rotate-key":
+			if len(parts) != 2 {
+				fmt.Println("Используйте: rotate-key NEW_MASTER_PASSWORD")
+				continue
+			}
+			if err := k.RotateKey(parts[1]); err != nil {
+				fmt.Println("Ошибка:", err)
+				continue
+			}
+			fmt.Println("Ключ хранилища заменён, используйте новый мастер-пароль")
+
+		case "rotate-abort":
+			if err := k.AbortRotation(); err != nil {
+				fmt.Println("Ошибка:", err)
+				continue
+			}
+			fmt.Println("Смена ключа отменена")
+
+		case "recovery-key":
+			if err := k.NewRecoveryKey(); err != nil {
+				fmt.Println("Ошибка:", err)
+			}
+
+		case "shares":
+			var need, total int
+			if len(parts) != 3 {
+				fmt.Println("Используйте: shares K N")
+				continue
+			}
+			if _, err := fmt.Sscan(parts[1], &need); err != nil {
+				fmt.Println("Используйте: shares K N")
+				continue
+			}
+			if _, err := fmt.Sscan(parts[2], &total); err != nil {
+				fmt.Println("Используйте: shares K N")
+				continue
+			}
+			if err := k.SplitKey(need, total); err != nil {
+				fmt.Println("Ошибка:", err)
+			}
+
+		case "share":
+			if len(parts) != 3 {
+				fmt.Println("Используйте: share KEY LOGIN")
+				continue
+			}
+			if err := k.Share(parts[1], parts[2]); err != nil {
+				fmt.Println("Ошибка:", err)
+			}
+
+		case "unshare":
+			if len(parts) != 2 {
+				fmt.Println("Используйте: unshare SHARE_ID")
+				continue
+			}
+			if err := k.Unshare(parts[1]); err != nil {
+				fmt.Println("Ошибка:", err)
+				continue
+			}
+			fmt.Println("Доступ отозван")
+
+		case "my-shares":
+			if err := k.MyShares(); err != nil {
+				fmt.Println("Ошибка:", err)
+			}
+
+		case "shared":
+			if err := k.Shared(); err != nil {
+				fmt.Println("Ошибка:", err)
+			}
+
+		case "download-shared":
+			if len(parts) != 3 {
+				fmt.Println("Используйте: download-shared OWNER/KEY PATH")
+				continue
+			}
+			if err := k.DownloadShared(parts[1], parts[2]); err != nil {
+				fmt.Println("Ошибка:", err)
+			}
+
+		case "my-key":
+			if err := k.MyKey(); err != nil {
+				fmt.Println("Ошибка:", err)
+			}
+
+		case "manifest-reset":
+			if err := k.ResetManifest(); err != nil {
+				fmt.Println("Ошибка:", err)
+			}
+
 		default:
-			fmt.Println("Неизвестная команда. Доступные команды: new, del, print")
+			fmt.Println("Неизвестная команда. Доступные команды: new, new-login, new-card, new-text, new-file, upload, download, meta, del, trash, undelete, purge, print, get, history, restore, sessions, logout, 2fa, rotate-key, rotate-abort, recovery-key, shares, share, unshare, my-shares, shared, download-shared, my-key, manifest-reset")
 		}
 	}
 }
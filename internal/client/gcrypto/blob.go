@@ -0,0 +1,83 @@
+package gcrypto
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+)
+
+// blobContext контекст HKDF для фрагментов больших двоичных данных
+const blobContext = "GophKeeper blob"
+
+// ChunkOverhead на сколько зашифрованный фрагмент длиннее исходного:
+// заголовок, nonce и тег AES-GCM
+const ChunkOverhead = 2 + 12 + 16
+
+// NewBlobKey случайный ключ двоичных данных в hex, хранится в записи
+func NewBlobKey() (string, error) {
+	key, err := randomKey()
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString([]byte(key)), nil
+}
+
+// SealChunk шифрует фрагмент n из total. ID данных, номер и число фрагментов
+// аутентифицируются: фрагменты нельзя переставить, подменить чужими или отрезать.
+func SealChunk(key, blobID string, n, total int, plain []byte) ([]byte, error) {
+	raw, err := hex.DecodeString(key)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+
+	aead, err := newAEAD(string(raw), blobContext)
+	if err != nil {
+		return nil, err
+	}
+
+	header := []byte{envelopeVersion, algAES256GCM}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, 0, len(plain)+ChunkOverhead)
+	out = append(append(out, header...), nonce...)
+	return aead.Seal(out, nonce, plain, chunkAD(header, blobID, n, total)), nil
+}
+
+// OpenChunk расшифровывает фрагмент и проверяет его место в данных
+func OpenChunk(key, blobID string, n, total int, sealed []byte) ([]byte, error) {
+	raw, err := hex.DecodeString(key)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+
+	if len(sealed) < ChunkOverhead {
+		return nil, ErrDecrypt
+	}
+	if sealed[0] != envelopeVersion || sealed[1] != algAES256GCM {
+		return nil, ErrUnsupported
+	}
+
+	aead, err := newAEAD(string(raw), blobContext)
+	if err != nil {
+		return nil, err
+	}
+
+	header, nonce, body := sealed[:2], sealed[2:2+aead.NonceSize()], sealed[2+aead.NonceSize():]
+	plain, err := aead.Open(nil, nonce, body, chunkAD(header, blobID, n, total))
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+
+	return plain, nil
+}
+
+// chunkAD заголовок, ID данных, номер и число фрагментов
+func chunkAD(header []byte, blobID string, n, total int) []byte {
+	ad := append(header[:2:2], blobID...)
+	ad = binary.BigEndian.AppendUint32(ad, uint32(n))
+	return binary.BigEndian.AppendUint32(ad, uint32(total))
+}
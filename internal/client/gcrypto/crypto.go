@@ -4,46 +4,283 @@ import (
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/hmac"
+	"crypto/rand"
 	"crypto/sha256"
 	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
 
 	"GophKeeper.ru/internal/entities"
+	"golang.org/x/crypto/hkdf"
 )
 
-func EnecryptRecord(record *entities.Record) error {
-	return nil
+// Формат конверта: "gk:" + hex(версия | алгоритм | nonce | шифротекст+тег)
+const (
+	envelopePrefix = "gk:"
+
+	envelopeVersion byte = 1
+	algAES256GCM    byte = 1
+)
+
+var (
+	ErrDecrypt     = errors.New("данные повреждены или ключ неверный")
+	ErrUnsupported = errors.New("неподдерживаемая версия или алгоритм шифрования")
+)
+
+// Контексты HKDF для записей хранилища, их ключей данных и слепого индекса
+const (
+	recordContext  = "GophKeeper record"
+	dataKeyContext = "GophKeeper data key"
+	indexContext   = "GophKeeper blind index"
+)
+
+// NewRecordID случайный ID записи
+func NewRecordID() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(id), nil
 }
 
-func DecryptRecord(record *entities.Record) error {
-	return nil
+// BlindIndex слепой индекс имени записи: HMAC-SHA256 на ключе, выведенном
+// из ключа хранилища. Одинаковые имена дают одинаковый индекс, но сервер
+// не может по нему узнать имя.
+func BlindIndex(name, vaultKey string) (string, error) {
+	indexKey := make([]byte, 32)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, []byte(vaultKey), nil, []byte(indexContext)), indexKey); err != nil {
+		return "", err
+	}
+
+	h := hmac.New(sha256.New, indexKey)
+	h.Write([]byte(name))
+	return hex.EncodeToString(h.Sum(nil)), nil
 }
 
-// deriveNonce генерирует nonce на основе ключа и контекста
-func deriveNonce(key, context string) []byte {
-	h := hmac.New(sha256.New, []byte(key))
-	h.Write([]byte(context))
-	sum := h.Sum(nil)
-	return sum[:16]
+// WrapDataKey шифрует ключ данных записи ключом хранилища kek.
+// ID записи аутентифицируется, ключ нельзя перенести на другую запись.
+func WrapDataKey(id, dek, kek string) (string, error) {
+	return seal([]byte(dek), kek, dataKeyContext, []byte(id))
+}
+
+// UnwrapDataKey расшифровывает ключ данных записи
+func UnwrapDataKey(id, wrapped, kek string) (string, error) {
+	dek, err := open(wrapped, kek, dataKeyContext, []byte(id))
+	if err != nil {
+		return "", err
+	}
+
+	return string(dek), nil
+}
+
+// EnecryptRecord шифрует запись целиком: имя, значение, тип и метаданные —
+// собственным ключом данных, а сам ключ оборачивает ключом хранилища kek.
+// ID записи аутентифицируется, поэтому сервер не может переставить значения.
+func EnecryptRecord(secret *entities.Secret, kek string) (*entities.Record, error) {
+	if secret.ID == "" {
+		return nil, errors.New("у записи нет ID")
+	}
+
+	if secret.DEK == "" {
+		dek, err := randomKey()
+		if err != nil {
+			return nil, err
+		}
+		secret.DEK = dek
+	}
+
+	plain, err := json.Marshal(secret)
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := seal(plain, secret.DEK, recordContext, []byte(secret.ID))
+	if err != nil {
+		return nil, err
+	}
+
+	wrapped, err := WrapDataKey(secret.ID, secret.DEK, kek)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err := BlindIndex(secret.Name, kek)
+	if err != nil {
+		return nil, err
+	}
+
+	return &entities.Record{Key: secret.ID, Value: value, WrappedKey: wrapped, Index: index}, nil
 }
 
-// Encrypt шифруем данные
+// DecryptRecord расшифровывает запись, зашифрованную целиком. Записи, где
+// имя и значение зашифрованы по отдельности, отклоняются с ErrDecrypt: их
+// значение может быть в AES-CTR без проверки целостности, и подменённое
+// сервером значение расшифровалось бы в мусор без ошибки.
+func DecryptRecord(record *entities.Record, kek string) (*entities.Secret, error) {
+	if IsLegacy(record.Value) || !IsLegacy(record.Key) {
+		return nil, ErrDecrypt
+	}
+
+	// Запись без ключа данных зашифрована прямо ключом хранилища
+	dek := kek
+	if record.WrappedKey != "" {
+		var err error
+		if dek, err = UnwrapDataKey(record.Key, record.WrappedKey, kek); err != nil {
+			return nil, err
+		}
+	}
+
+	plain, err := open(record.Value, dek, recordContext, []byte(record.Key))
+	if err != nil {
+		return nil, err
+	}
+
+	var secret entities.Secret
+	if err = json.Unmarshal(plain, &secret); err != nil {
+		return nil, ErrDecrypt
+	}
+	secret.ID = record.Key
+	if record.WrappedKey != "" {
+		secret.DEK = dek
+	}
+
+	return &secret, nil
+}
+
+// DecryptLegacyRecord расшифровывает запись старых форматов: имя и значение
+// по отдельности в конверте или в AES-CTR. AES-CTR не аутентифицирован,
+// поэтому вызывающий сам решает, можно ли верить такой записи.
+func DecryptLegacyRecord(record *entities.Record, key string) (*entities.Secret, error) {
+	if !IsLegacy(record.Value) && IsLegacy(record.Key) {
+		return DecryptRecord(record, key)
+	}
+
+	name, err := Decrypt(record.Key, key, "GophKeeper")
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := Decrypt(record.Value, key, "GophKeeper")
+	if err != nil {
+		return nil, err
+	}
+
+	return &entities.Secret{ID: record.Key, Name: name, Value: value}, nil
+}
+
+// IsLegacyRecord запись сохранена в старом формате и требует перешифровки:
+// значение в AES-CTR, имя зашифровано отдельно, нет ключа данных или индекса
+func IsLegacyRecord(record *entities.Record) bool {
+	return IsLegacy(record.Value) || !IsLegacy(record.Key) || record.WrappedKey == "" || record.Index == ""
+}
+
+// IsLegacy данные зашифрованы старой схемой AES-CTR и требуют миграции
+func IsLegacy(cipherText string) bool {
+	return !strings.HasPrefix(cipherText, envelopePrefix)
+}
+
+// Encrypt шифрует данные AES-256-GCM со случайным nonce.
+// Ключ шифрования выводится из key и context через HKDF.
 func Encrypt(data, key, context string) (string, error) {
-	block, err := aes.NewCipher([]byte(key))
+	return seal([]byte(data), key, context, nil)
+}
+
+// Decrypt расшифровывает конверт, отклоняя изменённые данные.
+// Строки без префикса конверта читаются как устаревший AES-CTR.
+func Decrypt(cipherText, key, context string) (string, error) {
+	if IsLegacy(cipherText) {
+		return decryptLegacy(cipherText, key, context)
+	}
+
+	plain, err := open(cipherText, key, context, nil)
 	if err != nil {
 		return "", err
 	}
 
-	nonce := deriveNonce(key, context)
-	ciphertext := make([]byte, len(data))
+	return string(plain), nil
+}
 
-	stream := cipher.NewCTR(block, nonce)
-	stream.XORKeyStream(ciphertext, []byte(data))
+// newAEAD AES-256-GCM с подключом для заданного контекста
+func newAEAD(key, context string) (cipher.AEAD, error) {
+	subkey := make([]byte, 32)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, []byte(key), nil, []byte(context)), subkey); err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(subkey)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// seal упаковывает данные в конверт, ad аутентифицируется, но не шифруется
+func seal(plain []byte, key, context string, ad []byte) (string, error) {
+	aead, err := newAEAD(key, context)
+	if err != nil {
+		return "", err
+	}
 
-	return hex.EncodeToString(ciphertext), nil
+	header := []byte{envelopeVersion, algAES256GCM}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	// Заголовок тоже аутентифицируется, чтобы нельзя было подменить версию
+	out := append(header, nonce...)
+	out = aead.Seal(out, nonce, plain, append(header, ad...))
+
+	return envelopePrefix + hex.EncodeToString(out), nil
+}
+
+// open распаковывает конверт и проверяет тег
+func open(cipherText, key, context string, ad []byte) ([]byte, error) {
+	raw, err := hex.DecodeString(strings.TrimPrefix(cipherText, envelopePrefix))
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+
+	if len(raw) < 2 {
+		return nil, ErrDecrypt
+	}
+	if raw[0] != envelopeVersion || raw[1] != algAES256GCM {
+		return nil, ErrUnsupported
+	}
+
+	aead, err := newAEAD(key, context)
+	if err != nil {
+		return nil, err
+	}
+
+	header, body := raw[:2], raw[2:]
+	if len(body) < aead.NonceSize()+aead.Overhead() {
+		return nil, ErrDecrypt
+	}
+
+	nonce, sealed := body[:aead.NonceSize()], body[aead.NonceSize():]
+	plain, err := aead.Open(nil, nonce, sealed, append(header[:2:2], ad...))
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+
+	return plain, nil
+}
+
+// deriveNonce генерирует nonce на основе ключа и контекста
+func deriveNonce(key, context string) []byte {
+	h := hmac.New(sha256.New, []byte(key))
+	h.Write([]byte(context))
+	sum := h.Sum(nil)
+	return sum[:16]
 }
 
-// Decrypt дешифруем данные
-func Decrypt(cipherTextHex, key, context string) (string, error) {
+// decryptLegacy дешифруем данные старой схемы AES-CTR, только для миграции
+func decryptLegacy(cipherTextHex, key, context string) (string, error) {
 	ciphertext, err := hex.DecodeString(cipherTextHex)
 	if err != nil {
 		return "", err
@@ -0,0 +1,201 @@
+package gcrypto
+
+import (
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+
+	"GophKeeper.ru/internal/entities"
+)
+
+// flip меняет один байт конверта после префикса
+func flip(envelope string, at int) string {
+	raw, _ := hex.DecodeString(strings.TrimPrefix(envelope, envelopePrefix))
+	raw[at] ^= 0x01
+	return envelopePrefix + hex.EncodeToString(raw)
+}
+
+func TestSealOpen(t *testing.T) {
+	key, _ := randomKey()
+
+	sealed, err := seal([]byte("secret"), key, "ctx", []byte("ad"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	again, _ := seal([]byte("secret"), key, "ctx", []byte("ad"))
+	if again == sealed {
+		t.Fatal("nonce reused: equal envelopes")
+	}
+
+	plain, err := open(sealed, key, "ctx", []byte("ad"))
+	if err != nil || string(plain) != "secret" {
+		t.Fatalf("open: %q, %v", plain, err)
+	}
+}
+
+func TestOpenRejectsTamperAndWrongInputs(t *testing.T) {
+	key, _ := randomKey()
+	other, _ := randomKey()
+
+	sealed, err := seal([]byte("secret"), key, "ctx", []byte("ad"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	size := (len(sealed) - len(envelopePrefix)) / 2 // байт после префикса
+
+	tests := []struct {
+		name     string
+		envelope string
+		key, ctx string
+		ad       string
+		want     error
+	}{
+		{"wrong key", sealed, other, "ctx", "ad", ErrDecrypt},
+		{"wrong context", sealed, key, "other", "ad", ErrDecrypt},
+		{"wrong ad", sealed, key, "ctx", "da", ErrDecrypt},
+		{"missing ad", sealed, key, "ctx", "", ErrDecrypt},
+		{"nonce changed", flip(sealed, 2), key, "ctx", "ad", ErrDecrypt},
+		{"ciphertext changed", flip(sealed, 2+12), key, "ctx", "ad", ErrDecrypt},
+		{"tag changed", flip(sealed, size-1), key, "ctx", "ad", ErrDecrypt},
+		{"version changed", flip(sealed, 0), key, "ctx", "ad", ErrUnsupported},
+		{"algorithm changed", flip(sealed, 1), key, "ctx", "ad", ErrUnsupported},
+		{"truncated", sealed[:len(sealed)-8], key, "ctx", "ad", ErrDecrypt},
+		{"not hex", envelopePrefix + "zz", key, "ctx", "ad", ErrDecrypt},
+		{"empty", envelopePrefix, key, "ctx", "ad", ErrDecrypt},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ad []byte
+			if tt.ad != "" {
+				ad = []byte(tt.ad)
+			}
+			if _, err := open(tt.envelope, tt.key, tt.ctx, ad); !errors.Is(err, tt.want) {
+				t.Fatalf("err %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	key, _ := randomKey()
+
+	sealed, err := Encrypt("secret", key, "GophKeeper")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if IsLegacy(sealed) {
+		t.Fatal("new envelope looks legacy")
+	}
+
+	plain, err := Decrypt(sealed, key, "GophKeeper")
+	if err != nil || plain != "secret" {
+		t.Fatalf("decrypt: %q, %v", plain, err)
+	}
+
+	if _, err = Decrypt(flip(sealed, 5), key, "GophKeeper"); !errors.Is(err, ErrDecrypt) {
+		t.Fatalf("tampered: err %v, want ErrDecrypt", err)
+	}
+}
+
+func newTestRecord(t *testing.T, kek string) (*entities.Secret, *entities.Record) {
+	t.Helper()
+
+	id, err := NewRecordID()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	secret := &entities.Secret{ID: id, Name: "mail", Value: "p@ss", Type: entities.TypeText,
+		Metadata: map[string]string{"site": "example.com"}}
+	record, err := EnecryptRecord(secret, kek)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return secret, record
+}
+
+func TestRecordRoundTrip(t *testing.T) {
+	kek, _ := NewVaultKey()
+	secret, record := newTestRecord(t, kek)
+
+	if IsLegacyRecord(record) {
+		t.Fatal("new record looks legacy")
+	}
+
+	got, err := DecryptRecord(record, kek)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != secret.ID || got.Name != secret.Name || got.Value != secret.Value ||
+		got.Type != secret.Type || got.Metadata["site"] != "example.com" || got.DEK != secret.DEK {
+		t.Fatalf("decrypted %+v, want %+v", got, secret)
+	}
+
+	// Повторное шифрование сохраняет ключ данных записи
+	again, err := EnecryptRecord(got, kek)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Index != record.Index {
+		t.Fatal("blind index changed for the same name")
+	}
+	if dek, err := UnwrapDataKey(again.Key, again.WrappedKey, kek); err != nil || dek != secret.DEK {
+		t.Fatalf("data key changed: %v", err)
+	}
+}
+
+func TestDecryptRecordRejects(t *testing.T) {
+	kek, _ := NewVaultKey()
+	other, _ := NewVaultKey()
+	_, record := newTestRecord(t, kek)
+	_, foreign := newTestRecord(t, kek)
+
+	tests := []struct {
+		name   string
+		record entities.Record
+		kek    string
+	}{
+		{"wrong vault key", *record, other},
+		{"value moved to another ID", entities.Record{Key: foreign.Key, Value: record.Value, WrappedKey: foreign.WrappedKey}, kek},
+		{"wrapped key moved to another ID", entities.Record{Key: foreign.Key, Value: foreign.Value, WrappedKey: record.WrappedKey}, kek},
+		{"value tampered", entities.Record{Key: record.Key, Value: flip(record.Value, 20), WrappedKey: record.WrappedKey}, kek},
+		{"wrapped key tampered", entities.Record{Key: record.Key, Value: record.Value, WrappedKey: flip(record.WrappedKey, 20)}, kek},
+		{"value replaced with non-envelope hex", entities.Record{Key: record.Key, Value: "00ff00ff", WrappedKey: record.WrappedKey}, kek},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := DecryptRecord(&tt.record, tt.kek); !errors.Is(err, ErrDecrypt) {
+				t.Fatalf("err %v, want ErrDecrypt", err)
+			}
+		})
+	}
+}
+
+func TestEncryptRecordNeedsID(t *testing.T) {
+	kek, _ := NewVaultKey()
+	if _, err := EnecryptRecord(&entities.Secret{Name: "x"}, kek); err == nil {
+		t.Fatal("record without ID encrypted")
+	}
+}
+
+func TestDecryptLegacyRecord(t *testing.T) {
+	key, _ := NewVaultKey()
+	name, _ := Encrypt("site", key, "GophKeeper")
+	value, _ := Encrypt("secret", key, "GophKeeper")
+	record := entities.Record{Key: name, Value: value}
+
+	if _, err := DecryptRecord(&record, key); !errors.Is(err, ErrDecrypt) {
+		t.Fatalf("legacy record opened by DecryptRecord: %v", err)
+	}
+
+	secret, err := DecryptLegacyRecord(&record, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if secret.Name != "site" || secret.Value != "secret" {
+		t.Fatalf("got %q=%q", secret.Name, secret.Value)
+	}
+}
@@ -0,0 +1,122 @@
+package gcrypto
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+
+	"GophKeeper.ru/internal/entities"
+	"golang.org/x/crypto/argon2"
+)
+
+// CurrentKDFVersion версия параметров KDF для новых и перешифрованных хранилищ
+const CurrentKDFVersion = 1
+
+const (
+	vaultKeySize = 32
+	kdfSaltSize  = 16
+)
+
+// Верхние границы параметров KDF: параметры приходят с сервера, и
+// враждебный сервер не должен исчерпать память или процессор клиента
+const (
+	maxKDFTime    = 10
+	maxKDFMemory  = 1 << 20 // КиБ, то есть 1 ГиБ
+	maxKDFThreads = 64
+	maxKDFSalt    = 64
+)
+
+// kdfVersions параметры Argon2id по версиям. Старые версии не удаляются,
+// но сами параметры хранятся вместе с хранилищем.
+var kdfVersions = map[int]entities.KDFParams{
+	1: {Version: 1, Time: 3, Memory: 64 * 1024, Threads: 4},
+}
+
+var (
+	ErrWeakKDF   = errors.New("параметры KDF слабее допустимых")
+	ErrCostlyKDF = errors.New("параметры KDF превышают допустимые")
+)
+
+// NewKDFParams параметры текущей версии со случайной солью
+func NewKDFParams() (entities.KDFParams, error) {
+	params := kdfVersions[CurrentKDFVersion]
+
+	salt := make([]byte, kdfSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return params, err
+	}
+	params.Salt = hex.EncodeToString(salt)
+
+	return params, nil
+}
+
+// DeriveKey вырабатывает ключ шифрования ключа (KEK) из мастер-пароля
+func DeriveKey(password string, params entities.KDFParams) (string, error) {
+	salt, err := hex.DecodeString(params.Salt)
+	if err != nil {
+		return "", err
+	}
+
+	// Сервер не должен подсунуть параметры, которые легко перебрать
+	if len(salt) < kdfSaltSize || params.Time == 0 || params.Memory < 8*1024 || params.Threads == 0 {
+		return "", ErrWeakKDF
+	}
+	if len(salt) > maxKDFSalt || params.Time > maxKDFTime || params.Memory > maxKDFMemory || params.Threads > maxKDFThreads {
+		return "", ErrCostlyKDF
+	}
+
+	return string(argon2.IDKey([]byte(password), salt, params.Time, params.Memory, params.Threads, vaultKeySize)), nil
+}
+
+// NewVaultKey случайный ключ хранилища
+func NewVaultKey() (string, error) {
+	return randomKey()
+}
+
+// randomKey случайный 256-битный ключ
+func randomKey() (string, error) {
+	key := make([]byte, vaultKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+
+	return string(key), nil
+}
+
+// WrapKey шифрует ключ хранилища ключом из мастер-пароля
+func WrapKey(vaultKey, kek string) (string, error) {
+	return seal([]byte(vaultKey), kek, "GophKeeper vault", nil)
+}
+
+// UnwrapKey расшифровывает ключ хранилища, при неверном пароле — ErrDecrypt
+func UnwrapKey(wrapped, kek string) (string, error) {
+	key, err := open(wrapped, kek, "GophKeeper vault", nil)
+	if err != nil {
+		return "", err
+	}
+
+	return string(key), nil
+}
+
+// KeyID открытый отпечаток ключа хранилища
+func KeyID(vaultKey string) string {
+	h := hmac.New(sha256.New, []byte(vaultKey))
+	h.Write([]byte("GophKeeper key id"))
+	return hex.EncodeToString(h.Sum(nil)[:8])
+}
+
+// LegacyTag отметка хранилища, созданного поверх записей прежнего -k.
+// Её может выпустить только владелец ключа хранилища, поэтому сервер не
+// включит расшифровку старых форматов для хранилища, где их не было.
+func LegacyTag(vaultKey string) string {
+	h := hmac.New(sha256.New, []byte(vaultKey))
+	h.Write([]byte("GophKeeper legacy records"))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// VerifyLegacyTag отметка выпущена этим ключом хранилища
+func VerifyLegacyTag(tag, vaultKey string) bool {
+	return hmac.Equal([]byte(tag), []byte(LegacyTag(vaultKey)))
+}
@@ -0,0 +1,88 @@
+package gcrypto
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+
+	"GophKeeper.ru/internal/entities"
+	"golang.org/x/crypto/hkdf"
+)
+
+// manifestContext контекст HKDF для ключа подписи манифеста
+const manifestContext = "GophKeeper manifest"
+
+var ErrManifest = errors.New("подпись манифеста записей неверна")
+
+// ManifestEntry запись в манифесте: имя и версия зашифрованного значения
+type ManifestEntry struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// Manifest записи хранилища с их версиями, как их видел клиент.
+// Seq растёт с каждым изменением, MAC — HMAC-SHA256 на ключе из ключа хранилища.
+type Manifest struct {
+	Seq     uint64                   `json:"seq"`
+	Records map[string]ManifestEntry `json:"records"`
+	MAC     string                   `json:"mac,omitempty"`
+}
+
+// RecordVersion версия записи: хэш зашифрованного значения и ключа данных.
+// Nonce случайный, поэтому каждое сохранение даёт новую версию.
+func RecordVersion(record *entities.Record) string {
+	h := sha256.New()
+	h.Write([]byte(record.Value))
+	h.Write([]byte{0})
+	h.Write([]byte(record.WrappedKey))
+	return hex.EncodeToString(h.Sum(nil)[:16])
+}
+
+// SignManifest подписывает манифест ключом хранилища
+func SignManifest(m *Manifest, vaultKey string) error {
+	mac, err := manifestMAC(m, vaultKey)
+	if err != nil {
+		return err
+	}
+
+	m.MAC = hex.EncodeToString(mac)
+	return nil
+}
+
+// VerifyManifest проверяет подпись манифеста
+func VerifyManifest(m *Manifest, vaultKey string) error {
+	want, err := manifestMAC(m, vaultKey)
+	if err != nil {
+		return err
+	}
+
+	got, err := hex.DecodeString(m.MAC)
+	if err != nil || !hmac.Equal(got, want) {
+		return ErrManifest
+	}
+
+	return nil
+}
+
+// manifestMAC HMAC манифеста без поля MAC. Ключи map в JSON
+// сортируются, поэтому сериализация однозначна.
+func manifestMAC(m *Manifest, vaultKey string) ([]byte, error) {
+	unsigned := *m
+	unsigned.MAC = ""
+	plain, err := json.Marshal(unsigned)
+	if err != nil {
+		return nil, err
+	}
+
+	macKey := make([]byte, 32)
+	if _, err = io.ReadFull(hkdf.New(sha256.New, []byte(vaultKey), nil, []byte(manifestContext)), macKey); err != nil {
+		return nil, err
+	}
+
+	h := hmac.New(sha256.New, macKey)
+	h.Write(plain)
+	return h.Sum(nil), nil
+}
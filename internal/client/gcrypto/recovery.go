@@ -0,0 +1,118 @@
+package gcrypto
+
+import (
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// recoveryContext контекст HKDF для обёртки ключа хранилища ключом восстановления
+const recoveryContext = "GophKeeper recovery"
+
+// sharePrefix начало строки доли ключа хранилища
+const sharePrefix = "gks-"
+
+var (
+	ErrRecoveryKey = errors.New("неверный формат ключа восстановления")
+	ErrShareFormat = errors.New("неверный формат доли")
+)
+
+var recoveryEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// NewRecoveryKey случайный ключ восстановления и его печатный вид
+// группами по 4 символа
+func NewRecoveryKey() (key, printable string, err error) {
+	if key, err = randomKey(); err != nil {
+		return "", "", err
+	}
+
+	encoded := recoveryEncoding.EncodeToString([]byte(key))
+	groups := make([]string, 0, len(encoded)/4+1)
+	for len(encoded) > 4 {
+		groups = append(groups, encoded[:4])
+		encoded = encoded[4:]
+	}
+	groups = append(groups, encoded)
+
+	return key, strings.Join(groups, "-"), nil
+}
+
+// ParseRecoveryKey разбирает печатный вид ключа восстановления
+func ParseRecoveryKey(printable string) (string, error) {
+	clean := strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(printable))
+
+	key, err := recoveryEncoding.DecodeString(clean)
+	if err != nil || len(key) != vaultKeySize {
+		return "", ErrRecoveryKey
+	}
+
+	return string(key), nil
+}
+
+// WrapRecovery шифрует ключ хранилища ключом восстановления
+func WrapRecovery(vaultKey, recoveryKey string) (string, error) {
+	return seal([]byte(vaultKey), recoveryKey, recoveryContext, nil)
+}
+
+// UnwrapRecovery расшифровывает ключ хранилища ключом восстановления
+func UnwrapRecovery(wrapped, recoveryKey string) (string, error) {
+	key, err := open(wrapped, recoveryKey, recoveryContext, nil)
+	if err != nil {
+		return "", err
+	}
+
+	return string(key), nil
+}
+
+// SplitKey делит ключ хранилища на n печатных долей, любые k восстанавливают его
+func SplitKey(vaultKey string, k, n int) ([]string, error) {
+	shares, err := Split([]byte(vaultKey), k, n)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]string, len(shares))
+	for i, share := range shares {
+		out[i] = fmt.Sprintf("%s%d-%s", sharePrefix, k, hex.EncodeToString(share))
+	}
+
+	return out, nil
+}
+
+// IsShare строка похожа на долю ключа хранилища
+func IsShare(s string) bool {
+	return strings.HasPrefix(strings.TrimSpace(s), sharePrefix)
+}
+
+// ParseShare разбирает печатную долю, k — сколько долей нужно для сборки
+func ParseShare(s string) (share []byte, k int, err error) {
+	rest := strings.TrimPrefix(strings.TrimSpace(s), sharePrefix)
+
+	kPart, hexPart, ok := strings.Cut(rest, "-")
+	if !ok {
+		return nil, 0, ErrShareFormat
+	}
+
+	if k, err = strconv.Atoi(kPart); err != nil || k < 2 {
+		return nil, 0, ErrShareFormat
+	}
+
+	if share, err = hex.DecodeString(hexPart); err != nil || len(share) < 2 {
+		return nil, 0, ErrShareFormat
+	}
+
+	return share, k, nil
+}
+
+// CombineKey собирает ключ хранилища из печатных долей
+func CombineKey(shares [][]byte) (string, error) {
+	key, err := Combine(shares)
+	if err != nil {
+		return "", err
+	}
+
+	return string(key), nil
+}
@@ -0,0 +1,136 @@
+package gcrypto
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestRecoveryKeyRoundTrip(t *testing.T) {
+	vaultKey, err := NewVaultKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, printable, err := NewRecoveryKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Ключ вводится вручную: регистр и пробелы не важны
+	parsed, err := ParseRecoveryKey(strings.ToLower(strings.ReplaceAll(printable, "-", " ")))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if parsed != key {
+		t.Fatal("parsed key differs")
+	}
+
+	wrapped, err := WrapRecovery(vaultKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := UnwrapRecovery(wrapped, parsed)
+	if err != nil {
+		t.Fatalf("unwrap: %v", err)
+	}
+	if got != vaultKey {
+		t.Fatal("unwrapped key differs")
+	}
+}
+
+func TestRecoveryKeyRejectsWrongKeyAndTamper(t *testing.T) {
+	vaultKey, _ := NewVaultKey()
+	key, _, _ := NewRecoveryKey()
+	other, _, _ := NewRecoveryKey()
+
+	wrapped, err := WrapRecovery(vaultKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = UnwrapRecovery(wrapped, other); !errors.Is(err, ErrDecrypt) {
+		t.Fatalf("wrong key: err %v, want ErrDecrypt", err)
+	}
+
+	tampered := []byte(wrapped)
+	last := len(tampered) - 1
+	if tampered[last] == '0' {
+		tampered[last] = '1'
+	} else {
+		tampered[last] = '0'
+	}
+	if _, err = UnwrapRecovery(string(tampered), key); !errors.Is(err, ErrDecrypt) {
+		t.Fatalf("tampered: err %v, want ErrDecrypt", err)
+	}
+
+	for _, bad := range []string{"", "ABCD-EFGH", "!!!!"} {
+		if _, err = ParseRecoveryKey(bad); !errors.Is(err, ErrRecoveryKey) {
+			t.Errorf("parse %q: err %v, want ErrRecoveryKey", bad, err)
+		}
+	}
+}
+
+func TestKeySharesKeyID(t *testing.T) {
+	vaultKey, _ := NewVaultKey()
+	otherKey, _ := NewVaultKey()
+
+	printed, err := SplitKey(vaultKey, 3, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	foreign, err := SplitKey(otherKey, 3, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parse := func(lines ...string) [][]byte {
+		t.Helper()
+		var shares [][]byte
+		for _, line := range lines {
+			if !IsShare(line) {
+				t.Fatalf("%q is not a share", line)
+			}
+			share, k, err := ParseShare(line)
+			if err != nil || k != 3 {
+				t.Fatalf("parse %q: k %d, err %v", line, k, err)
+			}
+			shares = append(shares, share)
+		}
+		return shares
+	}
+
+	key, err := CombineKey(parse(printed[4], printed[0], printed[2]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if KeyID(key) != KeyID(vaultKey) {
+		t.Fatal("combined key has wrong KeyID")
+	}
+
+	// Доля чужого хранилища даёт ключ с другим отпечатком
+	key, err = CombineKey(parse(printed[0], printed[1], foreign[2]))
+	if err == nil && KeyID(key) == KeyID(vaultKey) {
+		t.Fatal("mixed shares matched KeyID")
+	}
+
+	// Изменённая доля тоже
+	shares := parse(printed[0], printed[1], printed[2])
+	shares[1][1] ^= 0x01
+	key, err = CombineKey(shares)
+	if err == nil && KeyID(key) == KeyID(vaultKey) {
+		t.Fatal("tampered share matched KeyID")
+	}
+
+	// Меньше k долей
+	key, err = CombineKey(parse(printed[0], printed[1]))
+	if err == nil && KeyID(key) == KeyID(vaultKey) {
+		t.Fatal("key recovered from fewer than k shares")
+	}
+
+	for _, bad := range []string{"gks-", "gks-1-0102", "gks-x-0102", "gks-2-zz", "gks-2-01"} {
+		if _, _, err = ParseShare(bad); !errors.Is(err, ErrShareFormat) {
+			t.Errorf("parse %q: err %v, want ErrShareFormat", bad, err)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package gcrypto
+
+import (
+	"crypto/rand"
+	"errors"
+)
+
+// Разделение секрета Шамира над GF(256) с многочленом x^8+x^4+x^3+x+1.
+// Доля — байт x и значения многочлена в точке x для каждого байта секрета.
+
+var (
+	ErrShareParams = errors.New("нужно 2 <= K <= N <= 255")
+	ErrShares      = errors.New("доли повреждены или от разных секретов")
+)
+
+var gfExp, gfLog [256]byte
+
+func init() {
+	x := byte(1)
+	for i := 0; i < 255; i++ {
+		gfExp[i] = x
+		gfLog[x] = byte(i)
+		// умножение на генератор 3
+		x ^= gfMulSlow(x, 2)
+	}
+	gfExp[255] = gfExp[0]
+}
+
+// gfMulSlow умножение без таблиц, нужно только для их построения
+func gfMulSlow(a, b byte) byte {
+	var p byte
+	for b > 0 {
+		if b&1 != 0 {
+			p ^= a
+		}
+		carry := a & 0x80
+		a <<= 1
+		if carry != 0 {
+			a ^= 0x1b
+		}
+		b >>= 1
+	}
+	return p
+}
+
+func gfMul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return gfExp[(int(gfLog[a])+int(gfLog[b]))%255]
+}
+
+func gfDiv(a, b byte) byte {
+	if a == 0 {
+		return 0
+	}
+	return gfExp[(int(gfLog[a])+255-int(gfLog[b]))%255]
+}
+
+// Split делит секрет на n долей, любые k из которых восстанавливают его
+func Split(secret []byte, k, n int) ([][]byte, error) {
+	if k < 2 || k > n || n > 255 {
+		return nil, ErrShareParams
+	}
+
+	shares := make([][]byte, n)
+	for i := range shares {
+		shares[i] = make([]byte, len(secret)+1)
+		shares[i][0] = byte(i + 1)
+	}
+
+	coeffs := make([]byte, k)
+	for idx, b := range secret {
+		coeffs[0] = b
+		if _, err := rand.Read(coeffs[1:]); err != nil {
+			return nil, err
+		}
+
+		// Значение многочлена по схеме Горнера
+		for _, share := range shares {
+			x, y := share[0], byte(0)
+			for c := k - 1; c >= 0; c-- {
+				y = gfMul(y, x) ^ coeffs[c]
+			}
+			share[idx+1] = y
+		}
+	}
+
+	return shares, nil
+}
+
+// Combine восстанавливает секрет из долей интерполяцией Лагранжа в нуле.
+// Долей должно быть не меньше k, с которым вызывался Split.
+func Combine(shares [][]byte) ([]byte, error) {
+	if len(shares) < 2 {
+		return nil, ErrShares
+	}
+
+	size := len(shares[0])
+	seen := make(map[byte]bool, len(shares))
+	for _, share := range shares {
+		if len(share) != size || size < 2 || share[0] == 0 || seen[share[0]] {
+			return nil, ErrShares
+		}
+		seen[share[0]] = true
+	}
+
+	secret := make([]byte, size-1)
+	for i, si := range shares {
+		// Базисный многочлен i в точке 0: произведение xj / (xj - xi)
+		basis := byte(1)
+		for j, sj := range shares {
+			if i != j {
+				basis = gfMul(basis, gfDiv(sj[0], sj[0]^si[0]))
+			}
+		}
+
+		for idx := range secret {
+			secret[idx] ^= gfMul(si[idx+1], basis)
+		}
+	}
+
+	return secret, nil
+}
@@ -0,0 +1,104 @@
+package gcrypto
+
+import (
+	"bytes"
+	"crypto/rand"
+	"testing"
+)
+
+// subsets все подмножества индексов 0..n-1 размера k
+func subsets(n, k int) [][]int {
+	var out [][]int
+	var walk func(start int, cur []int)
+	walk = func(start int, cur []int) {
+		if len(cur) == k {
+			out = append(out, append([]int(nil), cur...))
+			return
+		}
+		for i := start; i < n; i++ {
+			walk(i+1, append(cur, i))
+		}
+	}
+	walk(0, nil)
+	return out
+}
+
+func pick(shares [][]byte, idx []int) [][]byte {
+	out := make([][]byte, len(idx))
+	for i, j := range idx {
+		out[i] = shares[j]
+	}
+	return out
+}
+
+func TestSplitCombineAllSubsets(t *testing.T) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct{ k, n int }{{2, 2}, {2, 3}, {3, 5}, {4, 6}, {5, 5}} {
+		shares, err := Split(secret, tt.k, tt.n)
+		if err != nil {
+			t.Fatalf("%d-of-%d: split: %v", tt.k, tt.n, err)
+		}
+		if len(shares) != tt.n {
+			t.Fatalf("%d-of-%d: got %d shares", tt.k, tt.n, len(shares))
+		}
+
+		// Любые k и более долей восстанавливают секрет
+		for size := tt.k; size <= tt.n; size++ {
+			for _, idx := range subsets(tt.n, size) {
+				got, err := Combine(pick(shares, idx))
+				if err != nil {
+					t.Fatalf("%d-of-%d %v: combine: %v", tt.k, tt.n, idx, err)
+				}
+				if !bytes.Equal(got, secret) {
+					t.Fatalf("%d-of-%d %v: wrong secret", tt.k, tt.n, idx)
+				}
+			}
+		}
+
+		// Меньше k долей секрет не дают
+		for size := 2; size < tt.k; size++ {
+			for _, idx := range subsets(tt.n, size) {
+				got, err := Combine(pick(shares, idx))
+				if err == nil && bytes.Equal(got, secret) {
+					t.Fatalf("%d-of-%d %v: secret recovered from %d shares", tt.k, tt.n, idx, size)
+				}
+			}
+		}
+	}
+}
+
+func TestSplitParams(t *testing.T) {
+	for _, tt := range []struct{ k, n int }{{1, 3}, {4, 3}, {2, 256}, {0, 0}} {
+		if _, err := Split([]byte("secret"), tt.k, tt.n); err != ErrShareParams {
+			t.Errorf("%d-of-%d: err %v, want ErrShareParams", tt.k, tt.n, err)
+		}
+	}
+}
+
+func TestCombineRejectsBadShares(t *testing.T) {
+	shares, err := Split([]byte("secret"), 2, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		shares [][]byte
+	}{
+		{"single share", shares[:1]},
+		{"duplicate x", [][]byte{shares[0], shares[0]}},
+		{"different length", [][]byte{shares[0], shares[1][:3]}},
+		{"zero x", [][]byte{shares[0], append([]byte{0}, shares[1][1:]...)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Combine(tt.shares); err != ErrShares {
+				t.Fatalf("err %v, want ErrShares", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,151 @@
+package gcrypto
+
+import (
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+
+	"GophKeeper.ru/internal/entities"
+)
+
+// Контексты HKDF для закрытого ключа X25519 и ключа данных, выданного получателю
+const (
+	privateKeyContext = "GophKeeper private key"
+	shareContext      = "GophKeeper share"
+)
+
+// NewKeyPair новая пара ключей X25519 для обмена записями, в hex
+func NewKeyPair() (public, private string, err error) {
+	key, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return "", "", err
+	}
+
+	return hex.EncodeToString(key.PublicKey().Bytes()), hex.EncodeToString(key.Bytes()), nil
+}
+
+// WrapPrivateKey шифрует закрытый ключ X25519 ключом хранилища
+func WrapPrivateKey(private, vaultKey string) (string, error) {
+	return seal([]byte(private), vaultKey, privateKeyContext, nil)
+}
+
+// UnwrapPrivateKey расшифровывает закрытый ключ X25519
+func UnwrapPrivateKey(wrapped, vaultKey string) (string, error) {
+	private, err := open(wrapped, vaultKey, privateKeyContext, nil)
+	if err != nil {
+		return "", err
+	}
+
+	return string(private), nil
+}
+
+// ShareDataKey шифрует ключ данных записи открытым ключом получателя:
+// эфемерный X25519, общий секрет через HKDF, AES-256-GCM.
+// Результат: hex(эфемерный открытый ключ) + ":" + конверт.
+func ShareDataKey(id, dek, recipientPublic string) (string, error) {
+	peer, err := parsePublicKey(recipientPublic)
+	if err != nil {
+		return "", err
+	}
+
+	eph, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return "", err
+	}
+
+	shared, err := eph.ECDH(peer)
+	if err != nil {
+		return "", err
+	}
+
+	ephPublic := eph.PublicKey().Bytes()
+	sealed, err := seal([]byte(dek), string(shared), shareContext, shareAD(id, ephPublic, peer.Bytes()))
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(ephPublic) + ":" + sealed, nil
+}
+
+// OpenDataKey расшифровывает выданный ключ данных закрытым ключом получателя
+func OpenDataKey(id, wrapped, private string) (string, error) {
+	ephHex, sealed, ok := strings.Cut(wrapped, ":")
+	if !ok {
+		return "", ErrDecrypt
+	}
+
+	raw, err := hex.DecodeString(private)
+	if err != nil {
+		return "", ErrDecrypt
+	}
+	key, err := ecdh.X25519().NewPrivateKey(raw)
+	if err != nil {
+		return "", ErrDecrypt
+	}
+
+	eph, err := parsePublicKey(ephHex)
+	if err != nil {
+		return "", ErrDecrypt
+	}
+
+	shared, err := key.ECDH(eph)
+	if err != nil {
+		return "", ErrDecrypt
+	}
+
+	dek, err := open(sealed, string(shared), shareContext, shareAD(id, eph.Bytes(), key.PublicKey().Bytes()))
+	if err != nil {
+		return "", err
+	}
+
+	return string(dek), nil
+}
+
+// DecryptWithDataKey расшифровывает запись известным ключом данных:
+// выданным владельцем или взятым из текущей версии записи
+func DecryptWithDataKey(id, value, dek string) (*entities.Secret, error) {
+	plain, err := open(value, dek, recordContext, []byte(id))
+	if err != nil {
+		return nil, err
+	}
+
+	var secret entities.Secret
+	if err = json.Unmarshal(plain, &secret); err != nil {
+		return nil, ErrDecrypt
+	}
+	secret.ID = id
+
+	return &secret, nil
+}
+
+// Fingerprint отпечаток открытого ключа для сверки по другому каналу
+func Fingerprint(public string) string {
+	sum := sha256.Sum256([]byte(public))
+	encoded := strings.ToUpper(hex.EncodeToString(sum[:10]))
+
+	groups := make([]string, 0, len(encoded)/4)
+	for i := 0; i < len(encoded); i += 4 {
+		groups = append(groups, encoded[i:i+4])
+	}
+
+	return strings.Join(groups, " ")
+}
+
+// parsePublicKey разбирает открытый ключ X25519 из hex
+func parsePublicKey(public string) (*ecdh.PublicKey, error) {
+	raw, err := hex.DecodeString(public)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+
+	return ecdh.X25519().NewPublicKey(raw)
+}
+
+// shareAD связывает выданный ключ с записью и обоими открытыми ключами
+func shareAD(id string, ephPublic, recipientPublic []byte) []byte {
+	ad := append([]byte(id), ephPublic...)
+	return append(ad, recipientPublic...)
+}
@@ -1,42 +1,114 @@
 package entities
 
 import (
+	"errors"
 	"fmt"
 	"log/slog"
+	"regexp"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
+// PurposeMFA назначение промежуточного токена между вводом пароля и кода 2FA
+const PurposeMFA = "2fa"
+
 type Claims struct {
-	IDUser int `json:"id_user"`
+	IDUser  int    `json:"id_user"`
+	Purpose string `json:"purpose,omitempty"`
 	jwt.RegisteredClaims
 }
 
+// DefaultKID идентификатор ключа, если в конфиге задан один секрет без kid
+const DefaultKID = "default"
+
+// TokenConfig параметры выпуска и проверки JWT
+type TokenConfig struct {
+	Keys       map[string][]byte // Секреты подписи по идентификатору ключа (kid)
+	ActiveKID  string            // Ключ, которым подписываются новые токены
+	Issuer     string            // Значение iss
+	Audience   string            // Значение aud
+	TTL        time.Duration     // Время жизни access-токена
+	RefreshTTL time.Duration     // Время жизни refresh-токена
+}
+
+// ParseSigningKeys разбирает секреты подписи из конфига.
+// Формат: "secret" либо "kid1:secret1,kid2:secret2", первый ключ — активный.
+// Остальные ключи принимаются только для проверки, пока идёт ротация.
+func ParseSigningKeys(value string) (map[string][]byte, string, error) {
+	if value == "" {
+		return nil, "", errors.New("не задан секретный ключ подписи токенов")
+	}
+
+	if !strings.Contains(value, ":") {
+		return map[string][]byte{DefaultKID: []byte(value)}, DefaultKID, nil
+	}
+
+	keys := make(map[string][]byte)
+	active := ""
+	for _, item := range strings.Split(value, ",") {
+		kid, secret, ok := strings.Cut(strings.TrimSpace(item), ":")
+		if !ok || kid == "" || secret == "" {
+			return nil, "", fmt.Errorf("неверный формат ключа подписи: %q", item)
+		}
+		if _, exists := keys[kid]; exists {
+			return nil, "", fmt.Errorf("ключ %q задан несколько раз", kid)
+		}
+		if active == "" {
+			active = kid
+		}
+		keys[kid] = []byte(secret)
+	}
+
+	return keys, active, nil
+}
+
 type User struct {
-	ID        int    `json:"id,omitempty"`
-	Login     string `json:"login"`
-	Password  string `json:"password"`
-	IsDisable bool   `json:"is_disable,omitempty"`
+	ID          int           `json:"id,omitempty"`
+	Login       string        `json:"login"`
+	Password    string        `json:"password,omitempty"`
+	IsDisable   bool          `json:"is_disable,omitempty"`
+	IsAdmin     bool          `json:"is_admin,omitempty"`
+	CertID      string        `json:"cert_identity,omitempty"`
+	LockedUntil *time.Time    `json:"locked_until,omitempty"`
+	LockedFor   time.Duration `json:"-"` // Сколько ещё действует блокировка входа
 }
 
-// IsEcual сравнение двух пользователей
-func (u *User) IsEcual(user *User) bool {
-	return u.Login == user.Login && u.Password == user.Password
+// loginPattern допустимые символы и длина логина
+var loginPattern = regexp.MustCompile(`^[a-zA-Z0-9_.-]{3,32}$`)
+
+// Validate проверка логина и пароля перед регистрацией
+func (u *User) Validate() error {
+	if !loginPattern.MatchString(u.Login) {
+		return errors.New("логин должен состоять из 3-32 символов: латиница, цифры, '_', '.', '-'")
+	}
+
+	if u.Password == "" || len(u.Password) > 128 {
+		return errors.New("неверный формат пароля")
+	}
+
+	return nil
 }
 
-// GetToken создает токен для пользователя
-func GetToken(u *User) (string, error) {
-	expirationTime := time.Now().Add(time.Hour)
+// GetToken создает токен для пользователя в рамках сессии sessionID (jti)
+func GetToken(u *User, sessionID string, cfg *TokenConfig) (string, error) {
+	now := time.Now()
 	claims := &Claims{
 		IDUser: u.ID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(expirationTime),
+			ID:        sessionID,
+			Issuer:    cfg.Issuer,
+			Audience:  jwt.ClaimStrings{cfg.Audience},
+			ExpiresAt: jwt.NewNumericDate(now.Add(cfg.TTL)),
+			NotBefore: jwt.NewNumericDate(now),
+			IssuedAt:  jwt.NewNumericDate(now),
 		},
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString([]byte("secret_key"))
+	token.Header["kid"] = cfg.ActiveKID
+	tokenString, err := token.SignedString(cfg.Keys[cfg.ActiveKID])
 	if err != nil {
 		slog.Error(fmt.Sprintf("getToken %s", err))
 		return "", err
@@ -44,3 +116,28 @@ func GetToken(u *User) (string, error) {
 
 	return tokenString, nil
 }
+
+// MFATokenTTL время жизни токена второго шага входа
+const MFATokenTTL = 5 * time.Minute
+
+// GetMFAToken создает короткоживущий токен второго шага входа с ID tokenID (jti).
+// Доступа к API он не даёт, только к /api/auth/2fa, и принимается один раз.
+func GetMFAToken(u *User, tokenID string, cfg *TokenConfig) (string, error) {
+	now := time.Now()
+	claims := &Claims{
+		IDUser:  u.ID,
+		Purpose: PurposeMFA,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        tokenID,
+			Issuer:    cfg.Issuer,
+			Audience:  jwt.ClaimStrings{cfg.Audience},
+			ExpiresAt: jwt.NewNumericDate(now.Add(MFATokenTTL)),
+			NotBefore: jwt.NewNumericDate(now),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	token.Header["kid"] = cfg.ActiveKID
+	return token.SignedString(cfg.Keys[cfg.ActiveKID])
+}
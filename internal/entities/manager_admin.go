@@ -0,0 +1,66 @@
+package entities
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+var (
+	// ErrUserNotFound пользователь с указанным ID не существует
+	ErrUserNotFound = errors.New("пользователь не найден")
+	// ErrCertIdentityTaken сертификат уже привязан к другому пользователю
+	ErrCertIdentityTaken = errors.New("идентификатор сертификата уже используется")
+	// ErrCertIdentityType у идентификатора сертификата нет известного типа
+	ErrCertIdentityType = errors.New("идентификатор сертификата должен начинаться с uri:, email:, dns:, cn: или subject:")
+)
+
+// Типы идентификатора сертификата клиента. Идентификатор хранится как
+// "тип:значение" и сравнивается только со значением того же типа из
+// сертификата, иначе CN одного сертификата совпал бы с email другого.
+const (
+	CertURI     = "uri"
+	CertEmail   = "email"
+	CertDNS     = "dns"
+	CertCN      = "cn"
+	CertSubject = "subject"
+)
+
+// CertIdentity идентификатор сертификата заданного типа
+func CertIdentity(typ, value string) string {
+	return typ + ":" + value
+}
+
+// ValidCertIdentity идентификатор имеет известный тип и непустое значение
+func ValidCertIdentity(identity string) bool {
+	typ, value, ok := strings.Cut(identity, ":")
+	if !ok || value == "" {
+		return false
+	}
+
+	switch typ {
+	case CertURI, CertEmail, CertDNS, CertCN, CertSubject:
+		return true
+	}
+	return false
+}
+
+type AdminManager interface {
+	// Users все пользователи без хэшей паролей
+	Users(ctx context.Context) ([]User, error)
+	// LockedUsers пользователи с действующей блокировкой входа
+	LockedUsers(ctx context.Context) ([]User, error)
+	// SetUserDisabled отключает или включает пользователя.
+	// При отключении завершает все его сессии, при включении снимает блокировку входа.
+	SetUserDisabled(ctx context.Context, id int, disabled bool) error
+	// ResetPassword задаёт новый хэш пароля, снимает блокировку и завершает сессии
+	ResetPassword(ctx context.Context, id int, hash string) error
+	// DeleteUser удаляет пользователя вместе с его данными, возвращает ID
+	// двоичных данных, фрагменты которых нужно удалить из хранилища
+	DeleteUser(ctx context.Context, id int) ([]string, error)
+	// SetAdmin выдаёт или снимает роль администратора по логину
+	SetAdmin(ctx context.Context, login string, admin bool) error
+	// SetCertIdentity привязывает идентификатор сертификата клиента вида
+	// "тип:значение", пустая строка отвязывает
+	SetCertIdentity(ctx context.Context, id int, identity string) error
+}
@@ -1,9 +1,28 @@
 package entities
 
-import "context"
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrUserExists пользователь с таким логином уже зарегистрирован
+var ErrUserExists = errors.New("пользователь уже существует")
 
 type AuthManager interface {
 	CompareUser(hash string) error
 	User(ctx context.Context, login string) (*User, error)
 	UserFromID(ctx context.Context, id int) (*User, error)
+	CreateUser(ctx context.Context, user *User) error
+	UpdatePassword(ctx context.Context, id int, hash string) error
+	// LockUser блокирует вход пользователя на время d после перебора паролей
+	LockUser(ctx context.Context, id int, d time.Duration) error
+	// UserFromCertIdentity ищет пользователя, привязанного к сертификату клиента
+	UserFromCertIdentity(ctx context.Context, identity string) (*User, error)
+}
+
+// PasswordHasher хэширует и проверяет пароли пользователей
+type PasswordHasher interface {
+	Hash(password string) (string, error)
+	Verify(password, encoded string) (ok bool, needRehash bool, err error)
 }
@@ -0,0 +1,57 @@
+package entities
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	ErrBlobNotFound   = errors.New("двоичные данные не найдены")
+	ErrBlobIncomplete = errors.New("загружены не все фрагменты")
+	ErrBlobComplete   = errors.New("загрузка уже завершена")
+	ErrBlobQuota      = errors.New("превышена квота двоичных данных")
+	ErrTooManyUploads = errors.New("слишком много незавершённых загрузок")
+)
+
+// BlobQuota ограничения на двоичные данные одного пользователя.
+// MaxBytes — общий заявленный размер всех его данных, MaxOpen — число
+// незавершённых загрузок. Ноль снимает ограничение.
+type BlobQuota struct {
+	MaxBytes int64
+	MaxOpen  int
+}
+
+// Blob большие двоичные данные, загружаемые фрагментами. Сервер хранит
+// только зашифрованные фрагменты, ключ и имя файла лежат в записи клиента.
+// Received — номера уже загруженных фрагментов, по ним клиент продолжает загрузку.
+// Record — ID записи, ссылающейся на данные: при окончательном удалении
+// записи из корзины данные удаляются вместе с ней.
+type Blob struct {
+	ID        string    `json:"id"`
+	Record    string    `json:"record,omitempty"`
+	Size      int64     `json:"size"`
+	Chunks    int       `json:"chunks"`
+	Received  []int     `json:"received,omitempty"`
+	Complete  bool      `json:"complete"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type BlobManager interface {
+	// CreateBlob начинает загрузку с заданным ID, если она укладывается в квоту
+	CreateBlob(ctx context.Context, userID int, b *Blob, quota BlobQuota) error
+	// Blob состояние загрузки, ErrBlobNotFound — нет у пользователя
+	Blob(ctx context.Context, userID int, id string) (*Blob, error)
+	// ReadableBlob данные, которые пользователь может выгрузить: свои или
+	// файл записи, к которой ему выдан доступ. ErrBlobNotFound — нет таких
+	ReadableBlob(ctx context.Context, userID int, id string) (*Blob, error)
+	// PutChunk отмечает фрагмент n загруженным и, пока загрузка не завершена,
+	// вызывает commit, заменяющий фрагмент в хранилище
+	PutChunk(ctx context.Context, userID int, id string, n int, size int64, commit func() error) error
+	// CompleteBlob завершает загрузку, если получены все фрагменты нужного размера
+	CompleteBlob(ctx context.Context, userID int, id string) error
+	// RemoveBlob удаляет загрузку
+	RemoveBlob(ctx context.Context, userID int, id string) error
+	// LinkBlob связывает данные с записью, ErrRecordNotFound — записи нет
+	LinkBlob(ctx context.Context, userID int, id, record string) error
+}
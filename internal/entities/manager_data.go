@@ -1,10 +1,40 @@
 package entities
 
-import "context"
+import (
+	"context"
+	"errors"
+	"time"
+)
 
+var (
+	ErrRecordNotFound  = errors.New("запись не найдена")
+	ErrRecordExists    = errors.New("запись с таким именем уже существует")
+	ErrVersionNotFound = errors.New("версия записи не найдена")
+)
+
+// Record запись в том виде, в каком её хранит сервер. WrappedKey — ключ
+// данных записи, зашифрованный ключом хранилища пользователя. Index —
+// слепой индекс имени (HMAC), по нему сервер ищет запись, не зная имени.
+// Blob — ID двоичных данных, на которые ссылается запись: пока на них
+// ссылается запись или одна из её версий, сервер их не удаляет.
 type Record struct {
-	Key   string `json:"key,omitempty"`
-	Value string `json:"value,omitempty"`
+	Key        string `json:"key,omitempty"`
+	Value      string `json:"value,omitempty"`
+	WrappedKey string `json:"wrapped_key,omitempty"`
+	Index      string `json:"index,omitempty"`
+	Blob       string `json:"blob,omitempty"`
+}
+
+// Version прежняя версия записи. CreatedAt — когда её заменила следующая.
+// WrappedKey обёрнут текущим ключом хранилища: смена ключа перешифровывает
+// его вместе с записями. RestoredFrom — версия, из которой восстановлено
+// это значение.
+type Version struct {
+	ID           int       `json:"id"`
+	Value        string    `json:"value"`
+	WrappedKey   string    `json:"wrapped_key,omitempty"`
+	RestoredFrom int       `json:"restored_from,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
 }
 
 type Update struct {
@@ -18,7 +48,15 @@ func NewUpdate() *Update {
 
 type DataManager interface {
 	GetData(ctx context.Context, id int) (*Update, error)
+	// Record ищет запись по слепому индексу имени
+	Record(ctx context.Context, userID int, index string) (*Record, error)
 	GetCountUpdate(ctx context.Context, userID int) (int, error)
+	// UpdateRecord добавляет или обновляет запись, ErrRecordExists — индекс занят другой записью
 	UpdateRecord(ctx context.Context, userID int, r Record) error
 	RemoveRecord(ctx context.Context, userID int, key string) error
+	// History прежние версии записи, новые первыми
+	History(ctx context.Context, userID int, key string) ([]Version, error)
+	// RestoreRecord сохраняет перешифрованное клиентом значение версии version
+	// текущим, ErrVersionNotFound — такой версии у записи нет
+	RestoreRecord(ctx context.Context, userID int, version int, r Record) error
 }
@@ -0,0 +1,47 @@
+package entities
+
+import (
+	"context"
+	"errors"
+)
+
+var (
+	ErrRotationInProgress = errors.New("идёт смена ключа хранилища")
+	ErrRotationNotFound   = errors.New("смена ключа не начата")
+	ErrRotationIncomplete = errors.New("перешифрованы не все записи")
+)
+
+// Rotation незавершённая смена ключа хранилища: новое хранилище и ID уже
+// перешифрованных записей, по ним клиент продолжает прерванную смену.
+type Rotation struct {
+	Vault Vault    `json:"vault"`
+	Done  []string `json:"done"`
+}
+
+// HistoryKey ключ данных прежней версии записи Record, обёрнутый ключом
+// хранилища. При смене ключа клиент перешифровывает и его: пустой
+// WrappedKey отмечает ключ, который не удалось открыть, версия станет
+// нечитаемой.
+type HistoryKey struct {
+	ID         int    `json:"id"`
+	Record     string `json:"record"`
+	WrappedKey string `json:"wrapped_key"`
+}
+
+type RotationManager interface {
+	// Rotation возвращает незавершённую смену ключа, nil — её нет
+	Rotation(ctx context.Context, userID int) (*Rotation, error)
+	// BeginRotation начинает смену ключа или продолжает начатую с тем же KeyID.
+	// Пока смена не завершена, запись данных отклоняется с ErrRotationInProgress.
+	BeginRotation(ctx context.Context, userID int, v *Vault) (*Rotation, error)
+	// StageRotation сохраняет перешифрованные записи до фиксации
+	StageRotation(ctx context.Context, userID int, records []Record) error
+	// RotationHistory ключи данных версий в истории, ещё не перешифрованные
+	RotationHistory(ctx context.Context, userID int) ([]HistoryKey, error)
+	// StageHistoryKeys сохраняет перешифрованные ключи версий до фиксации
+	StageHistoryKeys(ctx context.Context, userID int, keys []HistoryKey) error
+	// CommitRotation атомарно заменяет записи, ключи версий и хранилище на перешифрованные
+	CommitRotation(ctx context.Context, userID int) error
+	// AbortRotation отменяет смену ключа
+	AbortRotation(ctx context.Context, userID int) error
+}
@@ -0,0 +1,30 @@
+package entities
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrSessionNotFound сессия не найдена или принадлежит другому пользователю
+var ErrSessionNotFound = errors.New("сессия не найдена")
+
+// Session активный вход пользователя. ID сессии совпадает с jti
+// access-токенов и с семейством refresh-токенов этого входа.
+type Session struct {
+	ID         string    `json:"id"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	Current    bool      `json:"current,omitempty"`
+}
+
+type SessionManager interface {
+	TokenManager
+	CreateSession(ctx context.Context, userID int, s *Session) error
+	Sessions(ctx context.Context, userID int) ([]Session, error)
+	SessionActive(ctx context.Context, id string) (bool, error)
+	// RevokeSession завершает сессию и отзывает её refresh-токены
+	RevokeSession(ctx context.Context, userID int, id string) error
+}
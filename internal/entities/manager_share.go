@@ -0,0 +1,38 @@
+package entities
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	ErrShareNotFound = errors.New("доступ к записи не найден")
+	ErrShareSelf     = errors.New("нельзя выдать доступ самому себе")
+)
+
+// Share доступ другого пользователя к записи. WrappedKey — ключ данных
+// записи, зашифрованный открытым ключом получателя (X25519).
+// Получателю вместе с ним отдаётся зашифрованное значение записи.
+type Share struct {
+	ID         int       `json:"id"`
+	Record     string    `json:"record"`
+	Owner      string    `json:"owner,omitempty"`
+	Recipient  string    `json:"recipient,omitempty"`
+	WrappedKey string    `json:"wrapped_key,omitempty"`
+	Value      string    `json:"value,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type ShareManager interface {
+	// PublicKey открытый ключ пользователя, "" — ключ ещё не создан
+	PublicKey(ctx context.Context, login string) (string, error)
+	// CreateShare выдаёт доступ или заменяет ключ у уже выданного
+	CreateShare(ctx context.Context, ownerID int, s *Share) error
+	// Shares доступы, выданные владельцем
+	Shares(ctx context.Context, ownerID int) ([]Share, error)
+	// SharedWith записи, к которым у пользователя есть доступ
+	SharedWith(ctx context.Context, recipientID int) ([]Share, error)
+	// RevokeShare отзывает доступ, выданный владельцем
+	RevokeShare(ctx context.Context, ownerID, id int) error
+}
@@ -0,0 +1,34 @@
+package entities
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	// ErrTokenNotFound refresh-токен не выдавался сервером
+	ErrTokenNotFound = errors.New("refresh-токен не найден")
+	// ErrTokenExpired срок действия refresh-токена истёк или семейство отозвано
+	ErrTokenExpired = errors.New("refresh-токен просрочен или отозван")
+	// ErrTokenReused токен уже был обменян — семейство отозвано целиком
+	ErrTokenReused = errors.New("повторное использование refresh-токена")
+)
+
+// RefreshToken запись о выданном refresh-токене. Сам токен на сервере
+// не хранится, только его хэш. Все токены, полученные цепочкой обменов
+// от одного входа, составляют семейство FamilyID, равное ID сессии.
+type RefreshToken struct {
+	UserID   int
+	FamilyID string
+	Hash     string
+	TTL      time.Duration // Срок жизни, отсчитывается по часам БД
+}
+
+type TokenManager interface {
+	CreateRefreshToken(ctx context.Context, t *RefreshToken) error
+	// RotateRefreshToken помечает токен с хэшем hash использованным и сохраняет
+	// next в том же семействе. При повторном предъявлении уже использованного
+	// токена отзывает всё семейство и возвращает ErrTokenReused.
+	RotateRefreshToken(ctx context.Context, hash string, next *RefreshToken) error
+}
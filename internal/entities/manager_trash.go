@@ -0,0 +1,23 @@
+package entities
+
+import (
+	"context"
+	"time"
+)
+
+// TrashItem удалённая запись в корзине. Слепой индекс на время нахождения
+// в корзине освобождается, имя можно занять новой записью.
+type TrashItem struct {
+	Record
+	DeletedAt time.Time `json:"deleted_at"`
+}
+
+type TrashManager interface {
+	// Trash удалённые записи пользователя, последние удалённые первыми
+	Trash(ctx context.Context, userID int) ([]TrashItem, error)
+	// UndeleteRecord возвращает запись из корзины, ErrRecordExists — имя уже занято
+	UndeleteRecord(ctx context.Context, userID int, key string) (*Record, error)
+	// PurgeRecord окончательно удаляет запись из корзины вместе с историей.
+	// Возвращает ID двоичных данных записи, их фрагменты удаляет вызывающий.
+	PurgeRecord(ctx context.Context, userID int, key string) ([]string, error)
+}
@@ -0,0 +1,28 @@
+package entities
+
+import (
+	"context"
+	"time"
+)
+
+// TwoFactor состояние TOTP пользователя. Secret заполнен уже после
+// enroll, Enabled — только после подтверждения первым кодом.
+type TwoFactor struct {
+	Secret   string
+	Enabled  bool
+	LastStep int64
+}
+
+type TwoFactorManager interface {
+	TwoFactor(ctx context.Context, userID int) (*TwoFactor, error)
+	// SetTOTPSecret сохраняет секрет, ожидающий подтверждения
+	SetTOTPSecret(ctx context.Context, userID int, secret string) error
+	// EnableTOTP включает 2FA и заменяет коды восстановления на новые
+	EnableTOTP(ctx context.Context, userID int, step int64, codeHashes []string) error
+	// UseTOTPStep фиксирует использованный шаг, false — код уже использовался
+	UseTOTPStep(ctx context.Context, userID int, step int64) (bool, error)
+	// UseRecoveryCode гасит код восстановления, false — код не найден
+	UseRecoveryCode(ctx context.Context, userID int, hash string) (bool, error)
+	// UseMFAToken гасит токен второго шага входа, false — токен уже предъявлялся
+	UseMFAToken(ctx context.Context, id string, expires time.Time) (bool, error)
+}
@@ -0,0 +1,45 @@
+package entities
+
+import (
+	"context"
+	"errors"
+)
+
+var ErrVaultKeyMismatch = errors.New("ключ хранилища не совпадает с сохранённым")
+
+// KDFParams параметры выработки ключа из мастер-пароля (Argon2id).
+// Не секретны и хранятся на сервере; Version позволяет усилить параметры позже.
+type KDFParams struct {
+	Version int    `json:"version"`
+	Salt    string `json:"salt"`
+	Time    uint32 `json:"time"`
+	Memory  uint32 `json:"memory"`
+	Threads uint8  `json:"threads"`
+}
+
+// Vault ключ хранилища пользователя, зашифрованный ключом из мастер-пароля.
+// KeyID — открытый отпечаток ключа хранилища, по нему сервер отличает
+// перешифровку того же ключа от замены ключа. RecoveryKey — тот же ключ,
+// зашифрованный офлайн-ключом восстановления. PublicKey и PrivateKey —
+// пара X25519 для обмена записями, закрытый ключ зашифрован ключом хранилища.
+// LegacyTag — подпись клиента: хранилище создано поверх записей прежнего -k.
+type Vault struct {
+	KDF         KDFParams `json:"kdf"`
+	WrappedKey  string    `json:"wrapped_key"`
+	KeyID       string    `json:"key_id"`
+	RecoveryKey string    `json:"recovery_key,omitempty"`
+	PublicKey   string    `json:"public_key,omitempty"`
+	PrivateKey  string    `json:"private_key,omitempty"`
+	LegacyTag   string    `json:"legacy_tag,omitempty"`
+}
+
+type VaultManager interface {
+	// Vault возвращает хранилище пользователя, nil — ещё не создано
+	Vault(ctx context.Context, userID int) (*Vault, error)
+	// SaveVault создаёт хранилище или перешифровывает ключ с тем же KeyID
+	SaveVault(ctx context.Context, userID int, v *Vault) error
+	// SetRecoveryKey заменяет обёртку ключом восстановления для ключа с keyID
+	SetRecoveryKey(ctx context.Context, userID int, keyID, wrapped string) error
+	// SetKeyPair сохраняет пару ключей для обмена записями, если её ещё нет
+	SetKeyPair(ctx context.Context, userID int, keyID, public, private string) error
+}
@@ -0,0 +1,126 @@
+package entities
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+// Типы записей. Тип хранится внутри зашифрованной записи, сервер его не видит.
+// Пустой тип — произвольная строка, как в записях до появления типов.
+const (
+	TypeLogin  = "login"
+	TypeCard   = "card"
+	TypeText   = "text"
+	TypeBinary = "binary"
+	TypeBlob   = "blob"
+
+	// TypeManifest служебная запись клиента со списком записей и их версий
+	TypeManifest = "manifest"
+)
+
+var (
+	expiryPattern = regexp.MustCompile(`^(0[1-9]|1[0-2])/[0-9]{2}$`)
+	cvvPattern    = regexp.MustCompile(`^[0-9]{3,4}$`)
+)
+
+// Secret расшифрованная запись. На сервер она уходит целиком зашифрованной
+// в Record.Value, а в Record.Key хранится только случайный ID записи.
+// DEK — собственный ключ данных записи, на сервер уходит только обёрнутым.
+// Для типизированных записей Value — JSON соответствующей структуры.
+type Secret struct {
+	ID       string            `json:"-"`
+	DEK      string            `json:"-"`
+	Name     string            `json:"name"`
+	Value    string            `json:"value"`
+	Type     string            `json:"type,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// LoginData пара логин/пароль
+type LoginData struct {
+	Login    string `json:"login"`
+	Password string `json:"password"`
+}
+
+// Validate проверяет пару логин/пароль
+func (l *LoginData) Validate() error {
+	if l.Login == "" || l.Password == "" {
+		return errors.New("логин и пароль не должны быть пустыми")
+	}
+
+	return nil
+}
+
+// CardData данные банковской карты. Expiry в формате MM/YY.
+type CardData struct {
+	Number string `json:"number"`
+	Holder string `json:"holder"`
+	Expiry string `json:"expiry"`
+	CVV    string `json:"cvv"`
+}
+
+// Validate проверяет номер карты по алгоритму Луна, срок и CVV.
+// Пробелы и дефисы в номере убираются.
+func (c *CardData) Validate() error {
+	c.Number = strings.NewReplacer(" ", "", "-", "").Replace(c.Number)
+	if len(c.Number) < 12 || len(c.Number) > 19 || !luhn(c.Number) {
+		return errors.New("неверный номер карты")
+	}
+
+	if !expiryPattern.MatchString(c.Expiry) {
+		return errors.New("срок действия карты должен быть в формате MM/YY")
+	}
+
+	if !cvvPattern.MatchString(c.CVV) {
+		return errors.New("CVV должен состоять из 3-4 цифр")
+	}
+
+	if strings.TrimSpace(c.Holder) == "" {
+		return errors.New("не указан владелец карты")
+	}
+
+	return nil
+}
+
+// BinaryData двоичные данные с именем исходного файла
+type BinaryData struct {
+	FileName string `json:"file_name"`
+	Data     []byte `json:"data"`
+}
+
+// BlobRef большие двоичные данные: зашифрованные фрагменты лежат на сервере
+// отдельно (/api/blobs), в записи — их ID, ключ и контрольная сумма файла.
+type BlobRef struct {
+	ID        string `json:"id"`
+	FileName  string `json:"file_name"`
+	Size      int64  `json:"size"`
+	ChunkSize int    `json:"chunk_size"`
+	Chunks    int    `json:"chunks"`
+	SHA256    string `json:"sha256"`
+	Key       string `json:"key"`
+}
+
+// luhn проверка контрольной цифры номера карты
+func luhn(number string) bool {
+	sum := 0
+	double := false
+	for i := len(number) - 1; i >= 0; i-- {
+		if number[i] < '0' || number[i] > '9' {
+			return false
+		}
+
+		d := int(number[i] - '0')
+
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+
+	return sum%10 == 0
+}
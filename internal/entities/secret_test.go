@@ -0,0 +1,64 @@
+package entities
+
+import "testing"
+
+func TestCardDataValidate(t *testing.T) {
+	valid := CardData{Number: "4111 1111 1111 1111", Holder: "IVAN IVANOV", Expiry: "12/29", CVV: "123"}
+
+	tests := []struct {
+		name string
+		edit func(c *CardData)
+		ok   bool
+	}{
+		{"valid", func(c *CardData) {}, true},
+		{"dashes in number", func(c *CardData) { c.Number = "4111-1111-1111-1111" }, true},
+		{"19 digits", func(c *CardData) { c.Number = "6011000990139424009" }, true},
+		{"amex 15 digits", func(c *CardData) { c.Number = "378282246310005"; c.CVV = "1234" }, true},
+		{"luhn mismatch", func(c *CardData) { c.Number = "4111111111111112" }, false},
+		{"letters", func(c *CardData) { c.Number = "4111a11111111111" }, false},
+		{"too short", func(c *CardData) { c.Number = "42424242426" }, false},
+		{"too long", func(c *CardData) { c.Number = "41111111111111111111" }, false},
+		{"month 00", func(c *CardData) { c.Expiry = "00/29" }, false},
+		{"month 13", func(c *CardData) { c.Expiry = "13/29" }, false},
+		{"four-digit year", func(c *CardData) { c.Expiry = "12/2029" }, false},
+		{"no slash", func(c *CardData) { c.Expiry = "1229" }, false},
+		{"single-digit month", func(c *CardData) { c.Expiry = "1/29" }, false},
+		{"cvv too short", func(c *CardData) { c.CVV = "12" }, false},
+		{"cvv too long", func(c *CardData) { c.CVV = "12345" }, false},
+		{"cvv letters", func(c *CardData) { c.CVV = "12a" }, false},
+		{"no holder", func(c *CardData) { c.Holder = "  " }, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := valid
+			tt.edit(&c)
+			if err := c.Validate(); (err == nil) != tt.ok {
+				t.Fatalf("Validate() = %v, want ok %v", err, tt.ok)
+			}
+		})
+	}
+}
+
+func TestCardDataValidateNormalizesNumber(t *testing.T) {
+	c := CardData{Number: "5555 5555-5555 4444", Holder: "A", Expiry: "01/30", CVV: "000"}
+	if err := c.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if c.Number != "5555555555554444" {
+		t.Fatalf("number %q not normalized", c.Number)
+	}
+}
+
+func TestLuhn(t *testing.T) {
+	for number, ok := range map[string]bool{
+		"79927398713":      true,
+		"79927398710":      false,
+		"4012888888881881": true,
+		"0000000000000":    true,
+		"1":                false,
+	} {
+		if luhn(number) != ok {
+			t.Errorf("luhn(%s) = %v, want %v", number, !ok, ok)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+var (
+	ErrNotFound  = errors.New("фрагмент не найден")
+	ErrInvalidID = errors.New("неверный ID двоичных данных")
+)
+
+// Store хранилище зашифрованных фрагментов больших записей.
+// Фрагменты читаются и пишутся потоком, целиком в память не попадают.
+type Store interface {
+	// Stage записывает фрагмент n, но прежний заменяется только при Commit
+	Stage(ctx context.Context, blobID string, n int, r io.Reader) (Staged, error)
+	// Get открывает фрагмент n на чтение и возвращает его размер
+	Get(ctx context.Context, blobID string, n int) (io.ReadCloser, int64, error)
+	// Remove удаляет все фрагменты
+	Remove(ctx context.Context, blobID string) error
+}
+
+// Staged записанный, но ещё не видимый фрагмент. Фрагмент становится
+// доступен после Commit, Discard удаляет его, если Commit не было.
+type Staged interface {
+	// Size размер записанного фрагмента
+	Size() int64
+	// Commit заменяет фрагмент записанным
+	Commit() error
+	// Discard удаляет незафиксированную запись
+	Discard() error
+}
@@ -0,0 +1,126 @@
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+)
+
+// idPattern ID выдаёт сервер, но он приходит из URL и становится частью пути
+var idPattern = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// FS хранит фрагменты файлами: dir/<blobID>/<n>
+type FS struct {
+	dir string
+}
+
+// NewFS хранилище в каталоге dir, каталог создаётся при первой записи
+func NewFS(dir string) *FS {
+	return &FS{dir: dir}
+}
+
+// Stage пишет фрагмент во временный файл рядом с постоянным. Переименование
+// откладывается до Commit: сервер сперва убеждается, что загрузка не
+// завершена, и только потом заменяет фрагмент. Оборванная загрузка не
+// оставляет половину фрагмента.
+func (s *FS) Stage(ctx context.Context, blobID string, n int, r io.Reader) (Staged, error) {
+	dir, err := s.blobDir(blobID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+
+	tmp, err := os.CreateTemp(dir, "upload-*")
+	if err != nil {
+		return nil, err
+	}
+
+	size, err := io.Copy(tmp, r)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return nil, err
+	}
+
+	return &staged{tmp: tmp.Name(), path: filepath.Join(dir, strconv.Itoa(n)), size: size}, nil
+}
+
+// staged временный файл фрагмента и путь, под которым он станет видим
+type staged struct {
+	tmp  string
+	path string
+	size int64
+}
+
+func (f *staged) Size() int64 {
+	return f.size
+}
+
+func (f *staged) Commit() error {
+	return os.Rename(f.tmp, f.path)
+}
+
+// Discard после Commit ничего не делает: временного файла уже нет
+func (f *staged) Discard() error {
+	err := os.Remove(f.tmp)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// Get открывает файл фрагмента
+func (s *FS) Get(ctx context.Context, blobID string, n int) (io.ReadCloser, int64, error) {
+	dir, err := s.blobDir(blobID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	f, err := os.Open(filepath.Join(dir, strconv.Itoa(n)))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, 0, ErrNotFound
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, 0, err
+	}
+
+	return f, info.Size(), nil
+}
+
+// Remove удаляет каталог с фрагментами
+func (s *FS) Remove(ctx context.Context, blobID string) error {
+	dir, err := s.blobDir(blobID)
+	if err != nil {
+		return err
+	}
+
+	return os.RemoveAll(dir)
+}
+
+// blobDir каталог фрагментов, ID проверяется до построения пути
+func (s *FS) blobDir(blobID string) (string, error) {
+	if !idPattern.MatchString(blobID) {
+		return "", ErrInvalidID
+	}
+
+	return filepath.Join(s.dir, blobID), nil
+}
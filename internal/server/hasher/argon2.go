@@ -0,0 +1,113 @@
+package hasher
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+const (
+	saltLen = 16
+	keyLen  = 32
+)
+
+// ErrInvalidHash хэш в базе не соответствует ни одному известному формату
+var ErrInvalidHash = errors.New("неизвестный формат хэша пароля")
+
+// Argon2 хэширует пароли алгоритмом Argon2id с индивидуальной солью.
+// Параметры стоимости задаются в конфиге сервера.
+type Argon2 struct {
+	Time    uint32 // Количество проходов
+	Memory  uint32 // Объём памяти в КиБ
+	Threads uint8  // Степень параллелизма
+}
+
+// New создаёт хэшер, подставляя рекомендуемые значения вместо нулевых.
+func New(time, memory uint32, threads uint8) *Argon2 {
+	h := &Argon2{Time: time, Memory: memory, Threads: threads}
+	if h.Time == 0 {
+		h.Time = 2
+	}
+	if h.Memory == 0 {
+		h.Memory = 64 * 1024
+	}
+	if h.Threads == 0 {
+		h.Threads = 2
+	}
+	return h
+}
+
+// Hash возвращает хэш пароля в формате PHC:
+// $argon2id$v=19$m=65536,t=2,p=2$<salt>$<hash>
+func (h *Argon2) Hash(password string) (string, error) {
+	salt := make([]byte, saltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, h.Time, h.Memory, h.Threads, keyLen)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, h.Memory, h.Time, h.Threads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// Verify сравнивает пароль с хэшем из базы за постоянное время.
+// needRehash сообщает, что хэш устарел (SHA-256 или другие параметры стоимости)
+// и его нужно пересчитать после успешного входа.
+func (h *Argon2) Verify(password, encoded string) (ok bool, needRehash bool, err error) {
+	if !strings.HasPrefix(encoded, "$argon2id$") {
+		return h.verifyLegacy(password, encoded)
+	}
+
+	var (
+		version        int
+		memory, time   uint32
+		threads        uint8
+		salt, expected []byte
+	)
+
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 {
+		return false, false, ErrInvalidHash
+	}
+	if _, err = fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return false, false, ErrInvalidHash
+	}
+	if _, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &time, &threads); err != nil {
+		return false, false, ErrInvalidHash
+	}
+	if salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return false, false, ErrInvalidHash
+	}
+	if expected, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil {
+		return false, false, ErrInvalidHash
+	}
+
+	key := argon2.IDKey([]byte(password), salt, time, memory, threads, uint32(len(expected)))
+	if subtle.ConstantTimeCompare(key, expected) != 1 {
+		return false, false, nil
+	}
+
+	needRehash = memory != h.Memory || time != h.Time || threads != h.Threads
+	return true, needRehash, nil
+}
+
+// verifyLegacy проверяет старые записи, где в колонке password лежит
+// присланный клиентом SHA-256 в hex без соли
+func (h *Argon2) verifyLegacy(password, encoded string) (bool, bool, error) {
+	if _, err := hex.DecodeString(encoded); err != nil || len(encoded) != sha256.Size*2 {
+		return false, false, ErrInvalidHash
+	}
+
+	ok := subtle.ConstantTimeCompare([]byte(password), []byte(encoded)) == 1
+	return ok, ok, nil
+}
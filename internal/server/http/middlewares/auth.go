@@ -9,41 +9,145 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+// publicPaths маршруты, доступные без токена
+var publicPaths = map[string]bool{
+	"/api/auth":         true,
+	"/api/auth/refresh": true,
+	"/api/auth/2fa":     true,
+	"/api/register":     true,
+}
+
 // WarpAuth обертка авторизации
-func WarpAuth(mngr entities.AuthManager) func(ctx *gin.Context) {
+func WarpAuth(mngr entities.AuthManager, sessions entities.SessionManager, tokens *entities.TokenConfig) func(ctx *gin.Context) {
 	return func(ctx *gin.Context) {
-		Auth(ctx, mngr)
+		Auth(ctx, mngr, sessions, tokens)
 	}
 }
 
 // Auth проверяет наличие токена, если он есть — пускает дальше
-func Auth(ctx *gin.Context, mngr entities.AuthManager) {
-	if ctx.Request.URL.Path == "/api/auth" {
+func Auth(ctx *gin.Context, mngr entities.AuthManager, sessions entities.SessionManager, tokens *entities.TokenConfig) {
+	if publicPaths[ctx.Request.URL.Path] {
 		ctx.Next()
 		return
 	}
 
 	token, err := ctx.Cookie("token")
 	if err != nil {
+		// Без токена пробуем войти по проверенному сертификату клиента (mTLS)
+		if ids := certIdentities(ctx); len(ids) > 0 {
+			authByCert(ctx, mngr, ids)
+			return
+		}
+
 		slog.Error("Failed to get token cookie", "error", err, "method", "middlewares.Auth")
 		ctx.AbortWithError(http.StatusUnauthorized, err)
 		return
 	}
 
-	IDUser, err := utils.LoginFromToken(token, "secret_key")
+	claims, err := utils.ClaimsFromToken(token, tokens)
 	if err != nil {
 		slog.Error("Failed to parse token", "error", err, "method", "middlewares.Auth")
 		ctx.AbortWithError(http.StatusUnauthorized, err)
 		return
 	}
+	IDUser := claims.IDUser
+
+	// Токен валиден до истечения срока, поэтому отзыв проверяем по сессии
+	active, err := sessions.SessionActive(ctx.Request.Context(), claims.ID)
+	if err != nil {
+		slog.Error("Failed to check session", "error", err, "method", "middlewares.Auth")
+		ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	if !active {
+		slog.Warn("Session revoked or unknown", "user_id", IDUser, "session_id", claims.ID, "method", "middlewares.Auth")
+		ctx.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
 
 	user, err := mngr.UserFromID(ctx.Request.Context(), IDUser)
-	if err != nil || user.IsDisable {
+	if err != nil || user == nil || user.IsDisable {
 		slog.Error("User not found or disabled", "error", err, "method", "middlewares.Auth")
 		ctx.AbortWithError(http.StatusForbidden, err)
 		return
 	}
 
 	ctx.Set("user_id", IDUser)
+	ctx.Set("session_id", claims.ID)
+	ctx.Set("is_admin", user.IsAdmin)
 	ctx.Next()
 }
+
+// certIdentities возможные идентификаторы пользователя из проверенного
+// сертификата клиента: SAN (URI, email, DNS), CN и полный субъект.
+// Каждый помечен своим типом и совпадает только с привязкой того же типа.
+func certIdentities(ctx *gin.Context) []string {
+	state := ctx.Request.TLS
+	if state == nil || len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	leaf := state.VerifiedChains[0][0]
+
+	var ids []string
+	for _, uri := range leaf.URIs {
+		ids = append(ids, entities.CertIdentity(entities.CertURI, uri.String()))
+	}
+	for _, email := range leaf.EmailAddresses {
+		ids = append(ids, entities.CertIdentity(entities.CertEmail, email))
+	}
+	for _, name := range leaf.DNSNames {
+		ids = append(ids, entities.CertIdentity(entities.CertDNS, name))
+	}
+	if leaf.Subject.CommonName != "" {
+		ids = append(ids, entities.CertIdentity(entities.CertCN, leaf.Subject.CommonName))
+	}
+	ids = append(ids, entities.CertIdentity(entities.CertSubject, leaf.Subject.String()))
+
+	return ids
+}
+
+// authByCert авторизация по сертификату клиента. Сессии у такого входа нет:
+// доступ отзывается отвязкой сертификата или отключением пользователя.
+func authByCert(ctx *gin.Context, mngr entities.AuthManager, ids []string) {
+	for _, id := range ids {
+		user, err := mngr.UserFromCertIdentity(ctx.Request.Context(), id)
+		if err != nil {
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+		if user == nil {
+			continue
+		}
+
+		if user.IsDisable {
+			slog.Warn("Certificate of disabled user", "user_id", user.ID, "identity", id, "method", "middlewares.Auth")
+			ctx.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+
+		ctx.Set("user_id", user.ID)
+		ctx.Set("session_id", "")
+		ctx.Set("is_admin", user.IsAdmin)
+		ctx.Next()
+		return
+	}
+
+	slog.Warn("Client certificate is not bound to any user", "identities", ids, "method", "middlewares.Auth")
+	ctx.AbortWithStatus(http.StatusUnauthorized)
+}
+
+// RequireAdmin пускает дальше только пользователей с ролью администратора.
+// Подключается после Auth.
+func RequireAdmin() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if !ctx.GetBool("is_admin") {
+			slog.Warn("Admin route denied",
+				"user_id", ctx.GetInt("user_id"),
+				"path", ctx.Request.URL.Path,
+				"method", "middlewares.RequireAdmin")
+			ctx.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+		ctx.Next()
+	}
+}
@@ -0,0 +1,70 @@
+package middlewares
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ParseSubnets разбирает список CIDR через запятую.
+// Одиночный адрес трактуется как подсеть /32 (/128 для IPv6).
+func ParseSubnets(value string) ([]*net.IPNet, error) {
+	var subnets []*net.IPNet
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		if !strings.Contains(item, "/") {
+			ip := net.ParseIP(item)
+			if ip == nil {
+				return nil, fmt.Errorf("неверный адрес %q", item)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			subnets = append(subnets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, subnet, err := net.ParseCIDR(item)
+		if err != nil {
+			return nil, fmt.Errorf("неверная подсеть %q: %w", item, err)
+		}
+		subnets = append(subnets, subnet)
+	}
+
+	return subnets, nil
+}
+
+// TrustedSubnet пропускает только запросы из доверенных подсетей.
+// IP клиента берётся из ctx.ClientIP(): заголовки X-Real-IP и X-Forwarded-For
+// учитываются, только если запрос пришёл от доверенного прокси движка.
+// Пустой список подсетей запрещает доступ всем.
+func TrustedSubnet(subnets []*net.IPNet) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ip := net.ParseIP(ctx.ClientIP())
+		if ip != nil {
+			for _, subnet := range subnets {
+				if subnet.Contains(ip) {
+					ctx.Next()
+					return
+				}
+			}
+		}
+
+		slog.Warn("Request from untrusted address denied",
+			"ip", ctx.ClientIP(),
+			"remote_addr", ctx.Request.RemoteAddr,
+			"http_method", ctx.Request.Method,
+			"path", ctx.Request.URL.Path,
+			"method", "middlewares.TrustedSubnet")
+		ctx.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "доступ из этой сети запрещён"})
+	}
+}
@@ -1,10 +1,17 @@
 package server
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"log/slog"
+	"net"
+	"net/http"
 
+	"GophKeeper.ru/internal/entities"
+	"GophKeeper.ru/internal/server/blobstore"
 	"GophKeeper.ru/internal/server/http/middlewares"
+	"GophKeeper.ru/internal/server/limiter"
 	"GophKeeper.ru/internal/server/services"
 	"GophKeeper.ru/internal/server/storage"
 	"github.com/gin-gonic/gin"
@@ -21,9 +28,34 @@ type ServiceHTTP interface {
 // Server — структура, представляющая HTTP-сервер приложения.
 // Содержит ссылку на Gin-движок, адрес запуска и подключение к БД.
 type Server struct {
-	addr   string            // Адрес, на котором будет запущен сервер
-	engine *gin.Engine       // Gin-движок для обработки HTTP-запросов
-	db     *storage.Database // Подключение к базе данных
+	addr    string                  // Адрес, на котором будет запущен сервер
+	engine  *gin.Engine             // Gin-движок для обработки HTTP-запросов
+	db      *storage.Database       // Подключение к базе данных
+	hasher  entities.PasswordHasher // Хэширование паролей пользователей
+	tokens  *entities.TokenConfig   // Параметры выпуска и проверки JWT
+	logins  limiter.Limiter         // Ограничение перебора паролей
+	tls     *tls.Config             // Настройки TLS, в том числе проверка сертификатов клиентов
+	blobs   blobstore.Store         // Фрагменты больших двоичных записей
+	maxBlob int64                   // Наибольший размер двоичных данных
+	quota   entities.BlobQuota      // Квота двоичных данных пользователя
+}
+
+// Options — зависимости, которые сервер получает из конфига.
+type Options struct {
+	Hasher entities.PasswordHasher // Хэширование паролей пользователей
+	Tokens *entities.TokenConfig   // Ключи подписи, iss/aud и время жизни токенов
+	Logins limiter.Limiter         // Счётчики неудачных попыток входа
+
+	TrustedSubnets []*net.IPNet // Подсети, из которых доступны административные маршруты
+	TrustedProxies []string     // Прокси, чьим X-Real-IP/X-Forwarded-For можно верить
+	SubnetForAll   bool         // Проверять подсеть для всего API, а не только для /api/admin
+
+	ClientCAs  *x509.CertPool     // УЦ сертификатов клиентов для mTLS
+	ClientAuth tls.ClientAuthType // Режим проверки сертификатов клиентов
+
+	Blobs       blobstore.Store    // Хранилище фрагментов больших двоичных записей
+	MaxBlobSize int64              // Наибольший размер двоичных данных в байтах
+	BlobQuota   entities.BlobQuota // Квота двоичных данных одного пользователя
 }
 
 // NewServer создаёт новый экземпляр сервера с указанным адресом.
@@ -34,7 +66,13 @@ func NewServer(addr string) *Server {
 // Run запускает HTTP-сервер с TLS (HTTPS), используя указанный адрес,
 // а также сертификат и приватный ключ из файлов.
 func (s *Server) Run(addr string) error {
-	return s.engine.RunTLS(addr,
+	srv := &http.Server{
+		Addr:      addr,
+		Handler:   s.engine,
+		TLSConfig: s.tls,
+	}
+
+	return srv.ListenAndServeTLS(
 		"./localhost/cert.pem", // Путь к SSL-сертификату
 		"./localhost/key.pem")  // Путь к приватному ключу
 }
@@ -51,17 +89,64 @@ func (s *Server) Stop() error {
 // - создаёт движок с middleware'ами
 // - регистрирует маршруты сервисов
 // - возвращает готовый сервер или ошибку
-func New(db *storage.Database) (*Server, error) {
+func New(db *storage.Database, opts Options) (*Server, error) {
 	if db == nil {
 		return nil, fmt.Errorf("storage is nil")
 	}
 
+	if opts.Hasher == nil {
+		return nil, fmt.Errorf("password hasher is nil")
+	}
+
+	if opts.Tokens == nil || len(opts.Tokens.Keys[opts.Tokens.ActiveKID]) == 0 {
+		return nil, fmt.Errorf("token signing key is not set")
+	}
+
+	if opts.Logins == nil {
+		opts.Logins = limiter.NewMemory(limiter.Config{})
+	}
+
+	if opts.Blobs == nil {
+		opts.Blobs = blobstore.NewFS("./blobs")
+	}
+
+	if opts.MaxBlobSize <= 0 {
+		opts.MaxBlobSize = 100 << 20
+	}
+
+	if opts.ClientAuth >= tls.VerifyClientCertIfGiven && opts.ClientCAs == nil {
+		return nil, fmt.Errorf("client CA is required for mTLS")
+	}
+
 	server := &Server{
-		db: db,
+		db:      db,
+		hasher:  opts.Hasher,
+		tokens:  opts.Tokens,
+		logins:  opts.Logins,
+		blobs:   opts.Blobs,
+		maxBlob: opts.MaxBlobSize,
+		quota:   opts.BlobQuota,
+		tls: &tls.Config{
+			MinVersion: tls.VersionTLS12,
+			ClientCAs:  opts.ClientCAs,
+			ClientAuth: opts.ClientAuth,
+		},
 	}
 	gin.SetMode(gin.ReleaseMode)
 	server.engine = gin.New()
 
+	// По умолчанию gin верит заголовкам от любого клиента — это позволило бы
+	// подменить IP и обойти и проверку подсети, и счётчики попыток входа
+	server.engine.RemoteIPHeaders = []string{"X-Real-IP", "X-Forwarded-For"}
+	if err := server.engine.SetTrustedProxies(opts.TrustedProxies); err != nil {
+		return nil, fmt.Errorf("invalid trusted proxies: %w", err)
+	}
+
+	subnet := middlewares.TrustedSubnet(opts.TrustedSubnets)
+	if opts.SubnetForAll {
+		server.engine.Use(subnet)
+	}
+
 	server.engine.Use(func(c *gin.Context) {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", c.GetHeader("Origin"))
 		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
@@ -79,12 +164,21 @@ func New(db *storage.Database) (*Server, error) {
 		ctx.Next()
 	})
 
-	server.engine.Use(middlewares.WarpAuth(server.db))
+	server.engine.Use(middlewares.WarpAuth(server.db, server.db, server.tokens))
 
 	apiGroup := server.engine.Group("api")
 	{
-		services.Auth(apiGroup, server.db)       // Маршруты аутентификации
-		services.AccessData(apiGroup, server.db) // Маршруты доступа к данным
+		services.Auth(apiGroup, server.db, server.hasher, server.tokens, server.logins) // Маршруты аутентификации
+		services.Register(apiGroup, server.db, server.hasher)                           // Маршрут регистрации
+		services.AccessData(apiGroup, server.db)                                        // Маршруты доступа к данным
+		services.Sessions(apiGroup, server.db)                                          // Управление сессиями
+		services.TwoFactor(apiGroup, server.db, server.tokens)                          // Подключение 2FA
+		services.Vault(apiGroup, server.db)                                             // Ключ хранилища
+		services.Rotation(apiGroup, server.db)                                          // Смена ключа хранилища
+		services.Shares(apiGroup, server.db)                                            // Обмен записями
+		services.Blobs(apiGroup, server.db, server.blobs, server.maxBlob, server.quota) // Большие двоичные записи
+		services.Trash(apiGroup, server.db, server.blobs)                               // Корзина удалённых записей
+		services.Admin(apiGroup, server.db, server.blobs, server.hasher, subnet)        // Администрирование
 	}
 
 	routes := server.engine.Routes()
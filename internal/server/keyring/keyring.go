@@ -0,0 +1,117 @@
+package keyring
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+var (
+	ErrUnknownKey = errors.New("ключ шифрования данных сервера не найден")
+	ErrDecrypt    = errors.New("данные повреждены или ключ сервера неверный")
+)
+
+// Keyring ключи сервера для шифрования данных в БД (AES-256-GCM).
+// Новые значения шифруются активным ключом, остальные нужны для чтения
+// строк, ещё не перешифрованных после смены ключа.
+type Keyring struct {
+	keys   map[string]cipher.AEAD
+	active string
+}
+
+// Load читает файл ключей: по строке "kid:hex" с 32-байтным ключом,
+// первая строка — активный ключ. Пустые строки и строки с # пропускаются.
+func Load(path string) (*Keyring, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	k := &Keyring{keys: make(map[string]cipher.AEAD)}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		kid, encoded, ok := strings.Cut(text, ":")
+		if !ok || kid == "" {
+			return nil, fmt.Errorf("%s:%d: ожидается kid:hex", path, line)
+		}
+		if _, dup := k.keys[kid]; dup {
+			return nil, fmt.Errorf("%s:%d: повторный kid %q", path, line, kid)
+		}
+
+		key, err := hex.DecodeString(encoded)
+		if err != nil || len(key) != 32 {
+			return nil, fmt.Errorf("%s:%d: ключ должен быть 32 байта в hex", path, line)
+		}
+
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+
+		k.keys[kid] = aead
+		if k.active == "" {
+			k.active = kid
+		}
+	}
+
+	if k.active == "" {
+		return nil, fmt.Errorf("%s: нет ни одного ключа", path)
+	}
+
+	return k, scanner.Err()
+}
+
+// Active идентификатор активного ключа
+func (k *Keyring) Active() string {
+	return k.active
+}
+
+// Seal шифрует значение активным ключом, ad привязывает его к строке БД
+func (k *Keyring) Seal(plain, ad string) (kid, sealed string, err error) {
+	aead := k.keys[k.active]
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return "", "", err
+	}
+
+	out := aead.Seal(nonce, nonce, []byte(plain), []byte(ad))
+	return k.active, hex.EncodeToString(out), nil
+}
+
+// Open расшифровывает значение ключом kid
+func (k *Keyring) Open(kid, sealed, ad string) (string, error) {
+	aead, ok := k.keys[kid]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnknownKey, kid)
+	}
+
+	raw, err := hex.DecodeString(sealed)
+	if err != nil || len(raw) < aead.NonceSize()+aead.Overhead() {
+		return "", ErrDecrypt
+	}
+
+	plain, err := aead.Open(nil, raw[:aead.NonceSize()], raw[aead.NonceSize():], []byte(ad))
+	if err != nil {
+		return "", ErrDecrypt
+	}
+
+	return string(plain), nil
+}
@@ -0,0 +1,148 @@
+package limiter
+
+import (
+	"sync"
+	"time"
+)
+
+// Limiter учитывает неудачные попытки входа по произвольному ключу
+// (логин, IP-адрес клиента) и назначает растущую блокировку.
+type Limiter interface {
+	// Allow возвращает 0, если попытка разрешена, иначе время до разблокировки
+	Allow(key string) time.Duration
+	// Fail регистрирует неудачную попытку и возвращает назначенную блокировку
+	Fail(key string) time.Duration
+	// Reset сбрасывает счётчик ключа после успешного входа
+	Reset(key string)
+}
+
+// Config параметры экспоненциальной задержки
+type Config struct {
+	FreeAttempts int           // Попыток без задержки
+	BaseDelay    time.Duration // Первая блокировка, дальше удваивается
+	MaxDelay     time.Duration // Верхняя граница блокировки
+	ResetAfter   time.Duration // Через сколько после последней ошибки счётчик забывается
+}
+
+type entry struct {
+	failures    int
+	lastFailure time.Time
+	lockedUntil time.Time
+}
+
+// Memory хранит счётчики в памяти процесса
+type Memory struct {
+	mu        sync.Mutex
+	cfg       Config
+	entries   map[string]*entry
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// NewMemory создаёт лимитер, подставляя значения по умолчанию вместо нулевых
+func NewMemory(cfg Config) *Memory {
+	if cfg.FreeAttempts <= 0 {
+		cfg.FreeAttempts = 5
+	}
+	if cfg.BaseDelay <= 0 {
+		cfg.BaseDelay = time.Second
+	}
+	if cfg.MaxDelay <= 0 {
+		cfg.MaxDelay = 15 * time.Minute
+	}
+	if cfg.ResetAfter <= 0 {
+		cfg.ResetAfter = 2 * cfg.MaxDelay
+	}
+
+	return &Memory{
+		cfg:     cfg,
+		entries: make(map[string]*entry),
+		now:     time.Now,
+	}
+}
+
+// Allow возвращает оставшееся время блокировки ключа
+func (m *Memory) Allow(key string) time.Duration {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e := m.get(key)
+	if e == nil {
+		return 0
+	}
+
+	if wait := e.lockedUntil.Sub(m.now()); wait > 0 {
+		return wait
+	}
+	return 0
+}
+
+// Fail увеличивает счётчик и после бесплатных попыток удваивает блокировку
+func (m *Memory) Fail(key string) time.Duration {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	m.sweep(now)
+
+	e := m.get(key)
+	if e == nil {
+		e = &entry{}
+		m.entries[key] = e
+	}
+	e.failures++
+	e.lastFailure = now
+
+	over := e.failures - m.cfg.FreeAttempts
+	if over <= 0 {
+		return 0
+	}
+
+	delay := m.cfg.BaseDelay
+	for i := 1; i < over && delay < m.cfg.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > m.cfg.MaxDelay {
+		delay = m.cfg.MaxDelay
+	}
+
+	e.lockedUntil = now.Add(delay)
+	return delay
+}
+
+// Reset забывает ключ
+func (m *Memory) Reset(key string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.entries, key)
+}
+
+// get возвращает живую запись ключа, устаревшую удаляет
+func (m *Memory) get(key string) *entry {
+	e, ok := m.entries[key]
+	if !ok {
+		return nil
+	}
+
+	now := m.now()
+	if now.Sub(e.lastFailure) > m.cfg.ResetAfter && !now.Before(e.lockedUntil) {
+		delete(m.entries, key)
+		return nil
+	}
+	return e
+}
+
+// sweep раз в минуту вычищает забытые ключи, чтобы карта не росла бесконечно
+func (m *Memory) sweep(now time.Time) {
+	if now.Sub(m.lastSweep) < time.Minute {
+		return
+	}
+	m.lastSweep = now
+
+	for key, e := range m.entries {
+		if now.Sub(e.lastFailure) > m.cfg.ResetAfter && !now.Before(e.lockedUntil) {
+			delete(m.entries, key)
+		}
+	}
+}
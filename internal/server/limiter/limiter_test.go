@@ -0,0 +1,138 @@
+package limiter
+
+import (
+	"testing"
+	"time"
+)
+
+// clock управляемое время для лимитера
+type clock struct{ t time.Time }
+
+func (c *clock) now() time.Time          { return c.t }
+func (c *clock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func newTestMemory(cfg Config) (*Memory, *clock) {
+	c := &clock{t: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	m := NewMemory(cfg)
+	m.now = c.now
+	return m, c
+}
+
+func TestFailDoublesDelayUpToMax(t *testing.T) {
+	tests := []struct {
+		name  string
+		cfg   Config
+		delay []time.Duration // блокировка после каждой очередной неудачи
+	}{
+		{
+			name:  "free attempts then doubling",
+			cfg:   Config{FreeAttempts: 2, BaseDelay: time.Second, MaxDelay: time.Minute},
+			delay: []time.Duration{0, 0, time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second},
+		},
+		{
+			name:  "capped at max delay",
+			cfg:   Config{FreeAttempts: 1, BaseDelay: 10 * time.Second, MaxDelay: 30 * time.Second},
+			delay: []time.Duration{0, 10 * time.Second, 20 * time.Second, 30 * time.Second, 30 * time.Second},
+		},
+		{
+			name:  "base above max is capped",
+			cfg:   Config{FreeAttempts: 1, BaseDelay: time.Hour, MaxDelay: time.Minute},
+			delay: []time.Duration{0, time.Minute, time.Minute},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, _ := newTestMemory(tt.cfg)
+			for i, want := range tt.delay {
+				if got := m.Fail("login:user"); got != want {
+					t.Fatalf("failure %d: delay %v, want %v", i+1, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestAllowReportsRemainingLock(t *testing.T) {
+	m, c := newTestMemory(Config{FreeAttempts: 1, BaseDelay: 10 * time.Second, MaxDelay: time.Minute})
+
+	m.Fail("ip:1.2.3.4")
+	if wait := m.Allow("ip:1.2.3.4"); wait != 0 {
+		t.Fatalf("free attempt locked for %v", wait)
+	}
+
+	m.Fail("ip:1.2.3.4")
+	tests := []struct {
+		after time.Duration
+		want  time.Duration
+	}{
+		{0, 10 * time.Second},
+		{4 * time.Second, 6 * time.Second},
+		{6 * time.Second, 0},
+	}
+	for _, tt := range tests {
+		c.advance(tt.after)
+		if got := m.Allow("ip:1.2.3.4"); got != tt.want {
+			t.Fatalf("after +%v: wait %v, want %v", tt.after, got, tt.want)
+		}
+	}
+
+	if wait := m.Allow("ip:5.6.7.8"); wait != 0 {
+		t.Fatalf("unrelated key locked for %v", wait)
+	}
+}
+
+func TestResetClearsFailures(t *testing.T) {
+	m, _ := newTestMemory(Config{FreeAttempts: 1, BaseDelay: time.Second, MaxDelay: time.Minute})
+
+	m.Fail("login:user")
+	m.Fail("login:user")
+	if m.Allow("login:user") == 0 {
+		t.Fatal("expected lock before reset")
+	}
+
+	m.Reset("login:user")
+	if wait := m.Allow("login:user"); wait != 0 {
+		t.Fatalf("locked for %v after reset", wait)
+	}
+	if d := m.Fail("login:user"); d != 0 {
+		t.Fatalf("first failure after reset locked for %v", d)
+	}
+}
+
+func TestFailuresExpire(t *testing.T) {
+	m, c := newTestMemory(Config{
+		FreeAttempts: 1,
+		BaseDelay:    time.Second,
+		MaxDelay:     time.Minute,
+		ResetAfter:   10 * time.Minute,
+	})
+
+	m.Fail("login:user")
+	m.Fail("login:user")
+	m.Fail("login:user")
+
+	// Счётчик ещё помнится: следующая неудача удваивает блокировку
+	c.advance(5 * time.Minute)
+	if d := m.Fail("login:user"); d != 4*time.Second {
+		t.Fatalf("delay %v before expiry, want 4s", d)
+	}
+
+	// После ResetAfter без ошибок счётчик начинается заново
+	c.advance(10*time.Minute + time.Second)
+	if wait := m.Allow("login:user"); wait != 0 {
+		t.Fatalf("locked for %v after expiry", wait)
+	}
+	if d := m.Fail("login:user"); d != 0 {
+		t.Fatalf("first failure after expiry locked for %v", d)
+	}
+}
+
+func TestDefaults(t *testing.T) {
+	m := NewMemory(Config{})
+
+	if m.cfg.FreeAttempts != 5 || m.cfg.BaseDelay != time.Second ||
+		m.cfg.MaxDelay != 15*time.Minute || m.cfg.ResetAfter != 30*time.Minute {
+		t.Fatalf("unexpected defaults %+v", m.cfg)
+	}
+}
@@ -1,8 +1,10 @@
 package services
 
 import (
+	"errors"
 	"log/slog"
 	"net/http"
+	"strconv"
 
 	"GophKeeper.ru/internal/entities"
 	"GophKeeper.ru/internal/server/storage"
@@ -32,6 +34,10 @@ func accessData(group *gin.RouterGroup, mngr entities.DataManager) {
 
 		// Передаем контекст из Gin в UpdateRecord
 		err = mngr.UpdateRecord(ctx.Request.Context(), userID, record)
+		if errors.Is(err, entities.ErrRotationInProgress) || errors.Is(err, entities.ErrRecordExists) {
+			ctx.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
 		if err != nil {
 			slog.Error("database error: "+err.Error(), "method", "accessData::POST")
 			ctx.AbortWithStatus(http.StatusInternalServerError)
@@ -77,6 +83,91 @@ func accessData(group *gin.RouterGroup, mngr entities.DataManager) {
 		ctx.JSON(http.StatusOK, data)
 	})
 
+	// Поиск одной записи по слепому индексу имени
+	group.GET("/:index", func(ctx *gin.Context) {
+		userID, ok := ctx.Value("user_id").(int)
+		if !ok {
+			slog.Error("user_id not found", "method", "accessData::GET/:index")
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		record, err := mngr.Record(ctx.Request.Context(), userID, ctx.Param("index"))
+		if errors.Is(err, entities.ErrRecordNotFound) {
+			ctx.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			slog.Error("Record error: "+err.Error(), "method", "accessData::GET/:index")
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+
+		ctx.JSON(http.StatusOK, record)
+	})
+
+	// Прежние версии записи. Параметр называется :index из-за общего
+	// префикса маршрутов gin, но здесь это ID записи.
+	group.GET("/:index/history", func(ctx *gin.Context) {
+		userID, ok := ctx.Value("user_id").(int)
+		if !ok {
+			slog.Error("user_id not found", "method", "accessData::GET/:id/history")
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		versions, err := mngr.History(ctx.Request.Context(), userID, ctx.Param("index"))
+		if err != nil {
+			slog.Error("History error: "+err.Error(), "method", "accessData::GET/:id/history")
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+
+		ctx.JSON(http.StatusOK, versions)
+	})
+
+	// Возврат к прежней версии. Клиент сам расшифровывает версию и присылает
+	// её перешифрованной текущим ключом данных записи.
+	group.POST("/:index/history/:version/restore", func(ctx *gin.Context) {
+		userID, ok := ctx.Value("user_id").(int)
+		if !ok {
+			slog.Error("user_id not found", "method", "accessData::POST/:id/restore")
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		version, err := strconv.Atoi(ctx.Param("version"))
+		if err != nil {
+			ctx.AbortWithStatus(http.StatusBadRequest)
+			return
+		}
+
+		record := entities.Record{}
+		if err = ctx.BindJSON(&record); err != nil {
+			slog.Error("unmarshal to record: "+err.Error(), "method", "accessData::POST/:id/restore")
+			ctx.AbortWithStatus(http.StatusBadRequest)
+			return
+		}
+		record.Key = ctx.Param("index")
+
+		err = mngr.RestoreRecord(ctx.Request.Context(), userID, version, record)
+		if errors.Is(err, entities.ErrRotationInProgress) || errors.Is(err, entities.ErrRecordExists) {
+			ctx.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, entities.ErrRecordNotFound) || errors.Is(err, entities.ErrVersionNotFound) {
+			ctx.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			slog.Error("RestoreRecord error: "+err.Error(), "method", "accessData::POST/:id/restore")
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		ctx.JSON(http.StatusOK, gin.H{"status": "ok"})
+	})
+
 	group.DELETE("", func(ctx *gin.Context) {
 		userID, ok := ctx.Value("user_id").(int)
 		if !ok {
@@ -95,6 +186,10 @@ func accessData(group *gin.RouterGroup, mngr entities.DataManager) {
 
 		// Удаляем запись с использованием контекста
 		err = mngr.RemoveRecord(ctx.Request.Context(), userID, record.Key)
+		if errors.Is(err, entities.ErrRotationInProgress) {
+			ctx.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
 		if err != nil {
 			slog.Error("RemoveRecord error: "+err.Error(), "method", "accessData::DELETE")
 			ctx.AbortWithStatus(http.StatusInternalServerError)
@@ -0,0 +1,227 @@
+package services
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"GophKeeper.ru/internal/entities"
+	"GophKeeper.ru/internal/server/blobstore"
+	"GophKeeper.ru/internal/server/http/middlewares"
+	"GophKeeper.ru/internal/server/storage"
+	"github.com/gin-gonic/gin"
+)
+
+// Admin административные маршруты. Проверка роли администратора подключается
+// здесь, а не вызывающим кодом, чтобы маршрут нельзя было зарегистрировать без неё;
+// guard — дополнительные проверки, например доверенной подсети.
+func Admin(group *gin.RouterGroup, db *storage.Database, store blobstore.Store, hasher entities.PasswordHasher, guard ...gin.HandlerFunc) {
+	adminGroup := group.Group("/admin", append(guard, middlewares.RequireAdmin())...)
+	admin(adminGroup, db)
+	adminUsers(adminGroup.Group("/users"), db, db, store, hasher)
+}
+
+// admin административные маршруты
+func admin(group *gin.RouterGroup, mngr entities.AdminManager) {
+	group.GET("/lockouts", func(ctx *gin.Context) {
+		users, err := mngr.LockedUsers(ctx.Request.Context())
+		if err != nil {
+			slog.Error("LockedUsers error: "+err.Error(), "method", "admin::lockouts")
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+
+		ctx.JSON(http.StatusOK, users)
+	})
+}
+
+// adminUsers управление учётными записями
+func adminUsers(group *gin.RouterGroup, mngr entities.AdminManager, authMngr entities.AuthManager, store blobstore.Store, hasher entities.PasswordHasher) {
+	group.GET("", func(ctx *gin.Context) {
+		users, err := mngr.Users(ctx.Request.Context())
+		if err != nil {
+			slog.Error("Users error: "+err.Error(), "method", "admin::users::GET")
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+
+		ctx.JSON(http.StatusOK, users)
+	})
+
+	group.POST("", func(ctx *gin.Context) {
+		var req struct {
+			Login    string `json:"login"`
+			Password string `json:"password"`
+			IsAdmin  bool   `json:"is_admin"`
+		}
+		if err := ctx.BindJSON(&req); err != nil {
+			return
+		}
+		user := entities.User{Login: req.Login, Password: req.Password}
+
+		if err := user.Validate(); err != nil {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		hash, err := hasher.Hash(user.Password)
+		if err != nil {
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+		user.Password = hash
+
+		err = authMngr.CreateUser(ctx.Request.Context(), &user)
+		if errors.Is(err, entities.ErrUserExists) {
+			ctx.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			slog.Error("CreateUser error: "+err.Error(), "method", "admin::users::POST")
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+
+		auditAdmin(ctx, "create", user.ID)
+
+		// Роль администратора выдаётся отдельно от создания учётной записи
+		if req.IsAdmin {
+			if err = mngr.SetAdmin(ctx.Request.Context(), user.Login, true); err != nil {
+				slog.Error("SetAdmin error: "+err.Error(), "method", "admin::users::POST")
+				ctx.AbortWithError(http.StatusInternalServerError, err)
+				return
+			}
+			auditAdmin(ctx, "grant-admin", user.ID)
+		}
+
+		ctx.JSON(http.StatusCreated, gin.H{"status": "ok", "id": user.ID})
+	})
+
+	group.POST("/:id/disable", func(ctx *gin.Context) {
+		id, ok := targetUser(ctx, true)
+		if !ok {
+			return
+		}
+
+		adminResult(ctx, "disable", id, mngr.SetUserDisabled(ctx.Request.Context(), id, true))
+	})
+
+	group.POST("/:id/enable", func(ctx *gin.Context) {
+		id, ok := targetUser(ctx, false)
+		if !ok {
+			return
+		}
+
+		adminResult(ctx, "enable", id, mngr.SetUserDisabled(ctx.Request.Context(), id, false))
+	})
+
+	group.POST("/:id/password", func(ctx *gin.Context) {
+		id, ok := targetUser(ctx, false)
+		if !ok {
+			return
+		}
+
+		var req struct {
+			Password string `json:"password"`
+		}
+		if err := ctx.BindJSON(&req); err != nil {
+			return
+		}
+		if req.Password == "" || len(req.Password) > 128 {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "неверный формат пароля"})
+			return
+		}
+
+		hash, err := hasher.Hash(req.Password)
+		if err != nil {
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+
+		adminResult(ctx, "reset-password", id, mngr.ResetPassword(ctx.Request.Context(), id, hash))
+	})
+
+	group.POST("/:id/cert", func(ctx *gin.Context) {
+		id, ok := targetUser(ctx, false)
+		if !ok {
+			return
+		}
+
+		var req struct {
+			Identity string `json:"identity"`
+		}
+		if err := ctx.BindJSON(&req); err != nil {
+			return
+		}
+		if req.Identity != "" && !entities.ValidCertIdentity(req.Identity) {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": entities.ErrCertIdentityType.Error()})
+			return
+		}
+
+		adminResult(ctx, "bind-cert", id, mngr.SetCertIdentity(ctx.Request.Context(), id, req.Identity))
+	})
+
+	group.DELETE("/:id", func(ctx *gin.Context) {
+		id, ok := targetUser(ctx, true)
+		if !ok {
+			return
+		}
+
+		blobs, err := mngr.DeleteUser(ctx.Request.Context(), id)
+		// Строки уже удалены, оставшиеся файлы не мешают, только занимают место
+		for _, blob := range blobs {
+			if err := store.Remove(ctx.Request.Context(), blob); err != nil {
+				slog.Error("Failed to remove blob chunks", "blob_id", blob, "error", err, "method", "admin::users::DELETE")
+			}
+		}
+
+		adminResult(ctx, "delete", id, err)
+	})
+}
+
+// targetUser разбирает ID пользователя из пути. Отключить или удалить
+// самого себя администратор не может, чтобы не потерять доступ.
+func targetUser(ctx *gin.Context, notSelf bool) (int, bool) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "неверный ID пользователя"})
+		return 0, false
+	}
+
+	if notSelf && id == ctx.GetInt("user_id") {
+		ctx.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "нельзя применить к своей учётной записи"})
+		return 0, false
+	}
+
+	return id, true
+}
+
+// adminResult отвечает на административное действие и пишет его в аудит
+func adminResult(ctx *gin.Context, action string, target int, err error) {
+	if errors.Is(err, entities.ErrUserNotFound) {
+		ctx.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, entities.ErrCertIdentityTaken) {
+		ctx.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		slog.Error("Admin action failed", "action", action, "target_id", target, "error", err)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	auditAdmin(ctx, action, target)
+	ctx.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// auditAdmin журналирует действие администратора
+func auditAdmin(ctx *gin.Context, action string, target int) {
+	slog.Info("Admin action",
+		"action", action,
+		"admin_id", ctx.GetInt("user_id"),
+		"target_id", target,
+		"ip", ctx.ClientIP())
+}
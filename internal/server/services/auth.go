@@ -3,20 +3,37 @@ package services
 import (
 	"errors"
 	"net/http"
-	"time"
 
 	"log/slog"
 
 	"GophKeeper.ru/internal/entities"
+	"GophKeeper.ru/internal/server/limiter"
+	"GophKeeper.ru/internal/server/storage"
+	"GophKeeper.ru/internal/utils"
 	"github.com/gin-gonic/gin"
 )
 
-func Auth(group *gin.RouterGroup, mngr entities.AuthManager) {
-	auth(group.Group("/auth"), mngr)
+func Auth(group *gin.RouterGroup, db *storage.Database, hasher entities.PasswordHasher,
+	tokens *entities.TokenConfig, l limiter.Limiter) {
+	guard := &attemptGuard{limiter: l, mngr: db}
+
+	authGroup := group.Group("/auth")
+	auth(authGroup, db, db, db, hasher, tokens, guard)
+	authSecondFactor(authGroup, db, db, db, tokens, guard)
+	refresh(authGroup, db, db, tokens)
 }
 
-// auth производит аутификацию пользователя
-func auth(group *gin.RouterGroup, mngr entities.AuthManager) {
+// auth производит аутификацию пользователя. Если у пользователя включена 2FA,
+// вместо сессии выдаётся токен второго шага для /api/auth/2fa
+func auth(group *gin.RouterGroup, mngr entities.AuthManager, sessions entities.SessionManager,
+	tfMngr entities.TwoFactorManager, hasher entities.PasswordHasher, tokens *entities.TokenConfig, guard *attemptGuard) {
+	// Пароль неизвестного пользователя сверяется с этим хэшем, чтобы ответ
+	// по времени не отличался от неверного пароля и не выдавал, есть ли логин
+	dummyHash, err := hasher.Hash("GophKeeper dummy password")
+	if err != nil {
+		slog.Error("Failed to create dummy password hash", "error", err, "method", "auth")
+	}
+
 	group.POST("", func(ctx *gin.Context) {
 		var user entities.User
 
@@ -32,6 +49,12 @@ func auth(group *gin.RouterGroup, mngr entities.AuthManager) {
 			return
 		}
 
+		if wait := guard.wait(ipKey(ctx), loginKey(user.Login)); wait > 0 {
+			slog.Warn("Login attempt while locked", "login", user.Login, "ip", ctx.ClientIP(), "method", "auth::POST")
+			tooManyAttempts(ctx, wait)
+			return
+		}
+
 		// Получаем пользователя из БД с использованием контекста
 		u, err := mngr.User(ctx.Request.Context(), user.Login)
 		if err != nil {
@@ -40,21 +63,224 @@ func auth(group *gin.RouterGroup, mngr entities.AuthManager) {
 			return
 		}
 
-		if u == nil || !u.IsEcual(&user) {
+		if u != nil && u.LockedFor > 0 {
+			slog.Warn("Login attempt while locked", "login", user.Login, "ip", ctx.ClientIP(), "method", "auth::POST")
+			tooManyAttempts(ctx, u.LockedFor)
+			return
+		}
+
+		if u == nil {
+			hasher.Verify(user.Password, dummyHash)
+			guard.fail(ctx.Request.Context(), nil, ipKey(ctx), loginKey(user.Login))
 			slog.Warn("Invalid credentials", "login", user.Login, "method", "auth::POST")
 			ctx.AbortWithError(http.StatusUnauthorized, errors.New("неверная пара логин/пароль"))
 			return
 		}
 
-		token, err := entities.GetToken(u)
+		ok, needRehash, err := hasher.Verify(user.Password, u.Password)
+		if err != nil {
+			slog.Error("Failed to verify password", "error", err, "login", user.Login, "method", "auth::POST")
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+
+		if !ok {
+			guard.fail(ctx.Request.Context(), u, ipKey(ctx), loginKey(user.Login))
+			slog.Warn("Invalid credentials", "login", user.Login, "ip", ctx.ClientIP(), "method", "auth::POST")
+			ctx.AbortWithError(http.StatusUnauthorized, errors.New("неверная пара логин/пароль"))
+			return
+		}
+		if u.IsDisable {
+			slog.Warn("Login to disabled account", "login", user.Login, "method", "auth::POST")
+			ctx.AbortWithError(http.StatusForbidden, errors.New("учётная запись отключена"))
+			return
+		}
+
+		// Старый SHA-256 или устаревшие параметры — пересчитываем хэш
+		if needRehash {
+			hash, err := hasher.Hash(user.Password)
+			if err == nil {
+				err = mngr.UpdatePassword(ctx.Request.Context(), u.ID, hash)
+			}
+			if err != nil {
+				slog.Warn("Failed to rehash password", "error", err, "user_id", u.ID, "method", "auth::POST")
+			}
+		}
+
+		tf, err := tfMngr.TwoFactor(ctx.Request.Context(), u.ID)
 		if err != nil {
+			slog.Error("Database error while fetching 2FA state", "error", err, "method", "auth::POST")
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+
+		// С 2FA счётчик по логину сбрасывает только верный код:
+		// иначе верный пароль обнулял бы неудачи второго шага
+		if tf.Enabled {
+			tokenID, err := randomToken(16)
+			if err != nil {
+				slog.Error("Failed to generate 2FA token ID", "error", err, "method", "auth::POST")
+				ctx.AbortWithError(http.StatusInternalServerError, err)
+				return
+			}
+
+			mfaToken, err := entities.GetMFAToken(u, tokenID, tokens)
+			if err != nil {
+				slog.Error("Failed to generate 2FA token", "error", err, "method", "auth::POST")
+				ctx.AbortWithError(http.StatusInternalServerError, err)
+				return
+			}
+
+			ctx.JSON(http.StatusAccepted, gin.H{"status": "2fa_required", "mfa_token": mfaToken})
+			return
+		}
+		guard.limiter.Reset(loginKey(user.Login))
+
+		// Устанавливаем токены в виде cookie
+		if err = issueTokens(ctx, sessions, tokens, u); err != nil {
 			slog.Error("Failed to generate token", "error", err, "method", "auth::POST")
 			ctx.AbortWithError(http.StatusInternalServerError, err)
 			return
 		}
 
-		// Устанавливаем токен в виде cookie
-		ctx.SetCookie("token", token, int(time.Hour), "/", "", false, true)
+		ctx.JSON(http.StatusOK, gin.H{"status": "ok"})
+	})
+}
+
+// authSecondFactor второй шаг входа: TOTP-код или код восстановления.
+// Токен второго шага принимается один раз, неверные коды считаются
+// неудачными попытками входа по логину.
+func authSecondFactor(group *gin.RouterGroup, mngr entities.AuthManager, sessions entities.SessionManager,
+	tfMngr entities.TwoFactorManager, tokens *entities.TokenConfig, guard *attemptGuard) {
+	group.POST("/2fa", func(ctx *gin.Context) {
+		var req struct {
+			MFAToken string `json:"mfa_token"`
+			Code     string `json:"code"`
+		}
+		if err := ctx.BindJSON(&req); err != nil {
+			return
+		}
+
+		claims, err := utils.PurposeClaimsFromToken(req.MFAToken, entities.PurposeMFA, tokens)
+		if err == nil && claims.ID == "" {
+			err = errors.New("token without jti")
+		}
+		if err != nil {
+			slog.Warn("Invalid 2FA token", "error", err, "method", "auth::2fa")
+			ctx.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+
+		u, err := mngr.UserFromID(ctx.Request.Context(), claims.IDUser)
+		if err != nil || u == nil || u.IsDisable {
+			slog.Warn("User not found or disabled", "user_id", claims.IDUser, "error", err, "method", "auth::2fa")
+			ctx.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+
+		if wait := guard.wait(ipKey(ctx), userKey(u.ID), loginKey(u.Login)); wait > 0 || u.LockedFor > 0 {
+			slog.Warn("2FA attempt while locked", "user_id", u.ID, "ip", ctx.ClientIP(), "method", "auth::2fa")
+			tooManyAttempts(ctx, max(wait, u.LockedFor))
+			return
+		}
+
+		fresh, err := tfMngr.UseMFAToken(ctx.Request.Context(), claims.ID, claims.ExpiresAt.Time)
+		if err != nil {
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+		if !fresh {
+			slog.Warn("2FA token reused", "user_id", u.ID, "ip", ctx.ClientIP(), "method", "auth::2fa")
+			ctx.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+
+		tf, err := tfMngr.TwoFactor(ctx.Request.Context(), u.ID)
+		if err != nil {
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+
+		ok := false
+		if tf.Enabled {
+			ok, err = verifySecondFactor(ctx, tfMngr, u.ID, tf, req.Code)
+			if err != nil {
+				ctx.AbortWithError(http.StatusInternalServerError, err)
+				return
+			}
+		}
+
+		if !ok {
+			guard.fail(ctx.Request.Context(), u, ipKey(ctx), userKey(u.ID), loginKey(u.Login))
+			slog.Warn("Invalid 2FA code", "user_id", u.ID, "ip", ctx.ClientIP(), "method", "auth::2fa")
+			ctx.AbortWithError(http.StatusUnauthorized, errors.New("неверный код"))
+			return
+		}
+		guard.limiter.Reset(userKey(u.ID))
+		guard.limiter.Reset(loginKey(u.Login))
+
+		if err = issueTokens(ctx, sessions, tokens, u); err != nil {
+			slog.Error("Failed to generate token", "error", err, "method", "auth::2fa")
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+
+		ctx.JSON(http.StatusOK, gin.H{"status": "ok"})
+	})
+}
+
+// refresh обменивает refresh-токен на новую пару токенов
+func refresh(group *gin.RouterGroup, mngr entities.AuthManager, sessions entities.SessionManager, tokens *entities.TokenConfig) {
+	group.POST("/refresh", func(ctx *gin.Context) {
+		plain, err := ctx.Cookie(refreshCookie)
+		if err != nil || plain == "" {
+			slog.Warn("Refresh token cookie not found", "method", "auth::refresh")
+			ctx.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+
+		nextPlain, next, err := newRefreshToken(tokens)
+		if err != nil {
+			slog.Error("Failed to generate refresh token", "error", err, "method", "auth::refresh")
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+
+		err = sessions.RotateRefreshToken(ctx.Request.Context(), utils.Sha256hash(plain), next)
+		switch {
+		case errors.Is(err, entities.ErrTokenReused):
+			slog.Warn("Refresh token reuse detected, family revoked", "ip", ctx.ClientIP(), "method", "auth::refresh")
+			clearTokenCookies(ctx)
+			ctx.AbortWithStatus(http.StatusUnauthorized)
+			return
+		case errors.Is(err, entities.ErrTokenNotFound), errors.Is(err, entities.ErrTokenExpired):
+			slog.Warn("Invalid refresh token", "error", err, "method", "auth::refresh")
+			clearTokenCookies(ctx)
+			ctx.AbortWithStatus(http.StatusUnauthorized)
+			return
+		case err != nil:
+			slog.Error("Database error while rotating refresh token", "error", err, "method", "auth::refresh")
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+
+		u, err := mngr.UserFromID(ctx.Request.Context(), next.UserID)
+		if err != nil || u == nil || u.IsDisable {
+			slog.Warn("User not found or disabled", "user_id", next.UserID, "error", err, "method", "auth::refresh")
+			sessions.RevokeSession(ctx.Request.Context(), next.UserID, next.FamilyID)
+			clearTokenCookies(ctx)
+			ctx.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+
+		access, err := entities.GetToken(u, next.FamilyID, tokens)
+		if err != nil {
+			slog.Error("Failed to generate token", "error", err, "method", "auth::refresh")
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+
+		setTokenCookies(ctx, tokens, access, nextPlain)
 		ctx.JSON(http.StatusOK, gin.H{"status": "ok"})
 	})
 }
@@ -0,0 +1,72 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"GophKeeper.ru/internal/entities"
+	"GophKeeper.ru/internal/server/limiter"
+)
+
+// fakeUsers пользователи по логину
+type fakeUsers struct {
+	entities.AuthManager
+	users map[string]*entities.User
+}
+
+func (f *fakeUsers) User(ctx context.Context, login string) (*entities.User, error) {
+	return f.users[login], nil
+}
+
+// fakeHasher запоминает, с какими хэшами сверялись пароли
+type fakeHasher struct {
+	verified []string
+}
+
+func (f *fakeHasher) Hash(password string) (string, error) {
+	return "hash:" + password, nil
+}
+
+func (f *fakeHasher) Verify(password, encoded string) (bool, bool, error) {
+	f.verified = append(f.verified, encoded)
+	return "hash:"+password == encoded, false, nil
+}
+
+func TestAuthVerifiesUnknownLogin(t *testing.T) {
+	mngr := &fakeUsers{users: map[string]*entities.User{
+		"alice": {ID: 1, Login: "alice", Password: "hash:secret"},
+	}}
+	tests := []struct {
+		name  string
+		login string
+	}{
+		{name: "unknown login", login: "mallory"},
+		{name: "wrong password", login: "alice"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hasher := &fakeHasher{}
+			guard := &attemptGuard{limiter: limiter.NewMemory(limiter.Config{}), mngr: mngr}
+			r, group := testRouter(0)
+			auth(group.Group("/auth"), mngr, nil, nil, hasher, nil, guard)
+
+			body := `{"login":"` + tt.login + `","password":"wrong"}`
+			req := httptest.NewRequest(http.MethodPost, "/api/auth", strings.NewReader(body))
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != http.StatusUnauthorized {
+				t.Fatalf("status %d, want %d", w.Code, http.StatusUnauthorized)
+			}
+			// Проверка пароля выполняется в обоих случаях, иначе логин
+			// угадывается по времени ответа
+			if len(hasher.verified) != 1 || hasher.verified[0] == "" {
+				t.Fatalf("verified against %v, want one hash", hasher.verified)
+			}
+		})
+	}
+}
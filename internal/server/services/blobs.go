@@ -0,0 +1,303 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"GophKeeper.ru/internal/entities"
+	"GophKeeper.ru/internal/server/blobstore"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// maxChunkSize наибольший размер зашифрованного фрагмента
+	maxChunkSize = 4<<20 + 64
+	// maxBlobChunks наибольшее число фрагментов одной загрузки
+	maxBlobChunks = 100000
+	// maxRecordID наибольшая длина ID записи, к которой относятся данные
+	maxRecordID = 256
+)
+
+func Blobs(group *gin.RouterGroup, mngr entities.BlobManager, store blobstore.Store, maxSize int64, quota entities.BlobQuota) {
+	blobs(group.Group("/blobs"), mngr, store, maxSize, quota)
+}
+
+// blobs загрузка и выгрузка больших двоичных данных фрагментами.
+// Прерванную загрузку клиент продолжает по списку полученных фрагментов из GET.
+// Новая загрузка должна уложиться в квоту пользователя.
+func blobs(group *gin.RouterGroup, mngr entities.BlobManager, store blobstore.Store, maxSize int64, quota entities.BlobQuota) {
+	group.POST("", func(ctx *gin.Context) {
+		userID, ok := ctx.Value("user_id").(int)
+		if !ok {
+			slog.Error("user_id not found", "method", "blobs::POST")
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		var b entities.Blob
+		if err := ctx.BindJSON(&b); err != nil {
+			slog.Error("Failed to parse request body", "error", err, "method", "blobs::POST")
+			return
+		}
+
+		if b.Size < 0 || b.Chunks <= 0 || b.Chunks > maxBlobChunks || b.Size > int64(b.Chunks)*maxChunkSize {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "неверный размер или число фрагментов"})
+			return
+		}
+		if len(b.Record) > maxRecordID {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "неверный ID записи"})
+			return
+		}
+		if b.Size > maxSize {
+			ctx.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{"error": "превышен размер двоичных данных"})
+			return
+		}
+
+		id := make([]byte, 16)
+		if _, err := rand.Read(id); err != nil {
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+		b.ID = hex.EncodeToString(id)
+
+		err := mngr.CreateBlob(ctx.Request.Context(), userID, &b, quota)
+		switch {
+		case errors.Is(err, entities.ErrBlobQuota):
+			ctx.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+			return
+		case errors.Is(err, entities.ErrTooManyUploads):
+			ctx.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+			return
+		case err != nil:
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+
+		ctx.JSON(http.StatusCreated, b)
+	})
+
+	group.GET("/:id", func(ctx *gin.Context) {
+		userID, ok := ctx.Value("user_id").(int)
+		if !ok {
+			slog.Error("user_id not found", "method", "blobs::GET")
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		b, err := mngr.ReadableBlob(ctx.Request.Context(), userID, ctx.Param("id"))
+		if errors.Is(err, entities.ErrBlobNotFound) {
+			ctx.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+
+		ctx.JSON(http.StatusOK, b)
+	})
+
+	group.POST("/:id/chunks/:n", func(ctx *gin.Context) {
+		userID, ok := ctx.Value("user_id").(int)
+		if !ok {
+			slog.Error("user_id not found", "method", "blobs::chunk")
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		b, n, ok := blobChunk(ctx, mngr.Blob, userID)
+		if !ok {
+			return
+		}
+		if b.Complete {
+			ctx.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": entities.ErrBlobComplete.Error()})
+			return
+		}
+
+		if ctx.Request.ContentLength > maxChunkSize {
+			ctx.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{"error": "превышен размер фрагмента"})
+			return
+		}
+		body := http.MaxBytesReader(ctx.Writer, ctx.Request.Body, maxChunkSize)
+
+		// Фрагмент пишется во временный файл и заменяет прежний только после
+		// проверки под блокировкой, что загрузка всё ещё не завершена
+		chunk, err := store.Stage(ctx.Request.Context(), b.ID, n, body)
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			ctx.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{"error": "превышен размер фрагмента"})
+			return
+		}
+		if err != nil {
+			slog.Error("Failed to store blob chunk", "blob_id", b.ID, "chunk", n, "error", err, "method", "blobs::chunk")
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+		defer chunk.Discard()
+
+		size := chunk.Size()
+		err = mngr.PutChunk(ctx.Request.Context(), userID, b.ID, n, size, chunk.Commit)
+		if errors.Is(err, entities.ErrBlobNotFound) {
+			ctx.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, entities.ErrBlobComplete) {
+			ctx.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+
+		ctx.JSON(http.StatusOK, gin.H{"status": "ok", "size": size})
+	})
+
+	group.GET("/:id/chunks/:n", func(ctx *gin.Context) {
+		userID, ok := ctx.Value("user_id").(int)
+		if !ok {
+			slog.Error("user_id not found", "method", "blobs::download")
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		// Выгрузить фрагменты может и получатель доступа к записи с файлом
+		b, n, ok := blobChunk(ctx, mngr.ReadableBlob, userID)
+		if !ok {
+			return
+		}
+		if !b.Complete {
+			ctx.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "загрузка не завершена"})
+			return
+		}
+
+		r, size, err := store.Get(ctx.Request.Context(), b.ID, n)
+		if errors.Is(err, blobstore.ErrNotFound) {
+			slog.Error("Blob chunk is missing in store", "blob_id", b.ID, "chunk", n, "method", "blobs::download")
+			ctx.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+		defer r.Close()
+
+		// Общий middleware уже выставил JSON, а DataFromReader его не заменяет
+		ctx.Header("Content-Type", "application/octet-stream")
+		ctx.DataFromReader(http.StatusOK, size, "application/octet-stream", r, nil)
+	})
+
+	group.POST("/:id/complete", func(ctx *gin.Context) {
+		userID, ok := ctx.Value("user_id").(int)
+		if !ok {
+			slog.Error("user_id not found", "method", "blobs::complete")
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		err := mngr.CompleteBlob(ctx.Request.Context(), userID, ctx.Param("id"))
+		switch {
+		case errors.Is(err, entities.ErrBlobNotFound):
+			ctx.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		case errors.Is(err, entities.ErrBlobIncomplete):
+			ctx.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		case err != nil:
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+
+		ctx.JSON(http.StatusOK, gin.H{"status": "ok"})
+	})
+
+	// Связь с записью для данных, загруженных без неё
+	group.POST("/:id/link", func(ctx *gin.Context) {
+		userID, ok := ctx.Value("user_id").(int)
+		if !ok {
+			slog.Error("user_id not found", "method", "blobs::link")
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		var req struct {
+			Record string `json:"record"`
+		}
+		if err := ctx.BindJSON(&req); err != nil {
+			slog.Error("Failed to parse request body", "error", err, "method", "blobs::link")
+			return
+		}
+		if req.Record == "" || len(req.Record) > maxRecordID {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "неверный ID записи"})
+			return
+		}
+
+		err := mngr.LinkBlob(ctx.Request.Context(), userID, ctx.Param("id"), req.Record)
+		if errors.Is(err, entities.ErrBlobNotFound) || errors.Is(err, entities.ErrRecordNotFound) {
+			ctx.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+
+		ctx.JSON(http.StatusOK, gin.H{"status": "ok"})
+	})
+
+	group.DELETE("/:id", func(ctx *gin.Context) {
+		userID, ok := ctx.Value("user_id").(int)
+		if !ok {
+			slog.Error("user_id not found", "method", "blobs::DELETE")
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		id := ctx.Param("id")
+		err := mngr.RemoveBlob(ctx.Request.Context(), userID, id)
+		if errors.Is(err, entities.ErrBlobNotFound) {
+			ctx.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+
+		// Строка уже удалена, оставшиеся файлы не мешают, только занимают место
+		if err = store.Remove(ctx.Request.Context(), id); err != nil {
+			slog.Error("Failed to remove blob chunks", "blob_id", id, "error", err, "method", "blobs::DELETE")
+		}
+
+		ctx.JSON(http.StatusOK, gin.H{"status": "ok"})
+	})
+}
+
+// blobChunk загрузка из URL и номер фрагмента в её пределах. lookup ищет
+// загрузку: для записи фрагмента — только свою, для выгрузки — и чужую по
+// выданному доступу. При ошибке ответ уже отправлен.
+func blobChunk(ctx *gin.Context, lookup func(context.Context, int, string) (*entities.Blob, error), userID int) (*entities.Blob, int, bool) {
+	b, err := lookup(ctx.Request.Context(), userID, ctx.Param("id"))
+	if errors.Is(err, entities.ErrBlobNotFound) {
+		ctx.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return nil, 0, false
+	}
+	if err != nil {
+		ctx.AbortWithError(http.StatusInternalServerError, err)
+		return nil, 0, false
+	}
+
+	n, err := strconv.Atoi(ctx.Param("n"))
+	if err != nil || n < 0 || n >= b.Chunks {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "неверный номер фрагмента"})
+		return nil, 0, false
+	}
+
+	return b, n, true
+}
@@ -0,0 +1,93 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"GophKeeper.ru/internal/entities"
+	"GophKeeper.ru/internal/server/blobstore"
+	"github.com/gin-gonic/gin"
+)
+
+// fakeBlobs менеджер загрузок, отвечающий заданной ошибкой
+type fakeBlobs struct {
+	entities.BlobManager
+	createErr error
+	quota     entities.BlobQuota
+}
+
+func (f *fakeBlobs) CreateBlob(ctx context.Context, userID int, b *entities.Blob, quota entities.BlobQuota) error {
+	f.quota = quota
+	return f.createErr
+}
+
+// fakeStore хранилище фрагментов, запоминающее удалённые загрузки
+type fakeStore struct {
+	blobstore.Store
+	removed []string
+}
+
+func (f *fakeStore) Remove(ctx context.Context, blobID string) error {
+	f.removed = append(f.removed, blobID)
+	return nil
+}
+
+// testRouter маршрутизатор с пользователем userID, как после авторизации
+func testRouter(userID int) (*gin.Engine, *gin.RouterGroup) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	group := r.Group("/api", func(ctx *gin.Context) {
+		ctx.Set("user_id", userID)
+	})
+
+	return r, group
+}
+
+func TestCreateBlobQuotaStatus(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status int
+	}{
+		{name: "created", status: http.StatusCreated},
+		{name: "over bytes", err: entities.ErrBlobQuota, status: http.StatusRequestEntityTooLarge},
+		{name: "too many uploads", err: entities.ErrTooManyUploads, status: http.StatusTooManyRequests},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mngr := &fakeBlobs{createErr: tt.err}
+			quota := entities.BlobQuota{MaxBytes: 100, MaxOpen: 2}
+			r, group := testRouter(1)
+			Blobs(group, mngr, &fakeStore{}, 1<<20, quota)
+
+			req := httptest.NewRequest(http.MethodPost, "/api/blobs", strings.NewReader(`{"size":10,"chunks":1}`))
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.status {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if mngr.quota != quota {
+				t.Fatalf("quota %+v passed to manager, want %+v", mngr.quota, quota)
+			}
+		})
+	}
+}
+
+func TestCreateBlobRejectsOversize(t *testing.T) {
+	mngr := &fakeBlobs{}
+	r, group := testRouter(1)
+	Blobs(group, mngr, &fakeStore{}, 5, entities.BlobQuota{})
+
+	req := httptest.NewRequest(http.MethodPost, "/api/blobs", strings.NewReader(`{"size":10,"chunks":1}`))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("status %d, want %d", w.Code, http.StatusRequestEntityTooLarge)
+	}
+}
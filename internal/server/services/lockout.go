@@ -0,0 +1,66 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"GophKeeper.ru/internal/entities"
+	"GophKeeper.ru/internal/server/limiter"
+	"github.com/gin-gonic/gin"
+)
+
+// attemptGuard счётчики неудачных попыток входа по логину и по IP клиента
+type attemptGuard struct {
+	limiter limiter.Limiter
+	mngr    entities.AuthManager
+}
+
+func ipKey(ctx *gin.Context) string { return "ip:" + ctx.ClientIP() }
+func loginKey(login string) string  { return "login:" + login }
+func userKey(id int) string         { return "user:" + strconv.Itoa(id) }
+
+// wait возвращает наибольшую из оставшихся блокировок по ключам
+func (g *attemptGuard) wait(keys ...string) time.Duration {
+	var longest time.Duration
+	for _, key := range keys {
+		if w := g.limiter.Allow(key); w > longest {
+			longest = w
+		}
+	}
+	return longest
+}
+
+// fail регистрирует неудачу по всем ключам. Блокировку по учётной записи
+// дублирует в БД, чтобы её видели администраторы и другие экземпляры сервера.
+func (g *attemptGuard) fail(ctx context.Context, u *entities.User, keys ...string) {
+	var longest time.Duration
+	for _, key := range keys {
+		if d := g.limiter.Fail(key); d > longest {
+			longest = d
+		}
+	}
+
+	if u == nil || longest == 0 {
+		return
+	}
+
+	if err := g.mngr.LockUser(ctx, u.ID, longest); err != nil {
+		slog.Warn("Failed to persist lockout", "user_id", u.ID, "error", err)
+		return
+	}
+	slog.Warn("Login locked after failed attempts", "user_id", u.ID, "for", longest)
+}
+
+// tooManyAttempts отвечает 429 с заголовком Retry-After
+func tooManyAttempts(ctx *gin.Context, wait time.Duration) {
+	seconds := int(math.Ceil(wait.Seconds()))
+	ctx.Header("Retry-After", strconv.Itoa(seconds))
+	ctx.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+		"error":       "слишком много попыток входа, повторите позже",
+		"retry_after": seconds,
+	})
+}
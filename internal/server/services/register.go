@@ -0,0 +1,61 @@
+package services
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"GophKeeper.ru/internal/entities"
+	"github.com/gin-gonic/gin"
+)
+
+func Register(group *gin.RouterGroup, mngr entities.AuthManager, hasher entities.PasswordHasher) {
+	register(group.Group("/register"), mngr, hasher)
+}
+
+// register регистрирует нового пользователя
+func register(group *gin.RouterGroup, mngr entities.AuthManager, hasher entities.PasswordHasher) {
+	group.POST("", func(ctx *gin.Context) {
+		// Принимаются только логин и пароль: роль и состояние учётной
+		// записи задаёт сервер, а не регистрирующийся
+		var req struct {
+			Login    string `json:"login"`
+			Password string `json:"password"`
+		}
+
+		if err := ctx.BindJSON(&req); err != nil {
+			slog.Error("Failed to parse request body", "error", err, "method", "register::POST")
+			ctx.AbortWithError(http.StatusBadRequest, err)
+			return
+		}
+		user := entities.User{Login: req.Login, Password: req.Password}
+
+		if err := user.Validate(); err != nil {
+			slog.Warn("Invalid registration data", "login", user.Login, "error", err, "method", "register::POST")
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		hash, err := hasher.Hash(user.Password)
+		if err != nil {
+			slog.Error("Failed to hash password", "error", err, "method", "register::POST")
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+		user.Password = hash
+
+		err = mngr.CreateUser(ctx.Request.Context(), &user)
+		if errors.Is(err, entities.ErrUserExists) {
+			slog.Warn("Login already taken", "login", user.Login, "method", "register::POST")
+			ctx.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			slog.Error("Database error while creating user", "error", err, "method", "register::POST")
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+
+		ctx.JSON(http.StatusCreated, gin.H{"status": "ok", "id": user.ID})
+	})
+}
@@ -0,0 +1,183 @@
+package services
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"GophKeeper.ru/internal/entities"
+	"github.com/gin-gonic/gin"
+)
+
+// maxRotationBatch наибольшее число записей в одной пачке смены ключа
+const maxRotationBatch = 500
+
+func Rotation(group *gin.RouterGroup, mngr entities.RotationManager) {
+	rotation(group.Group("/rotation"), mngr)
+}
+
+// rotation смена ключа хранилища: начало, пачки перешифрованных записей,
+// атомарная фиксация и отмена. Прерванную смену клиент продолжает по GET.
+func rotation(group *gin.RouterGroup, mngr entities.RotationManager) {
+	group.GET("", func(ctx *gin.Context) {
+		userID, ok := ctx.Value("user_id").(int)
+		if !ok {
+			slog.Error("user_id not found", "method", "rotation::GET")
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		r, err := mngr.Rotation(ctx.Request.Context(), userID)
+		if err != nil {
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+		if r == nil {
+			ctx.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+
+		ctx.JSON(http.StatusOK, r)
+	})
+
+	group.POST("", func(ctx *gin.Context) {
+		userID, ok := ctx.Value("user_id").(int)
+		if !ok {
+			slog.Error("user_id not found", "method", "rotation::POST")
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		var v entities.Vault
+		if err := ctx.BindJSON(&v); err != nil {
+			slog.Error("Failed to parse request body", "error", err, "method", "rotation::POST")
+			return
+		}
+
+		if !validVault(&v) {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "неполные параметры хранилища"})
+			return
+		}
+
+		r, err := mngr.BeginRotation(ctx.Request.Context(), userID, &v)
+		if rotationResult(ctx, err, "rotation::POST") {
+			slog.Info("Vault key rotation started", "user_id", userID, "done", len(r.Done), "method", "rotation::POST")
+			ctx.JSON(http.StatusOK, r)
+		}
+	})
+
+	group.POST("/records", func(ctx *gin.Context) {
+		userID, ok := ctx.Value("user_id").(int)
+		if !ok {
+			slog.Error("user_id not found", "method", "rotation::records")
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		var records []entities.Record
+		if err := ctx.BindJSON(&records); err != nil {
+			slog.Error("Failed to parse request body", "error", err, "method", "rotation::records")
+			return
+		}
+
+		if len(records) > maxRotationBatch {
+			ctx.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{"error": "слишком большая пачка записей"})
+			return
+		}
+
+		err := mngr.StageRotation(ctx.Request.Context(), userID, records)
+		if rotationResult(ctx, err, "rotation::records") {
+			ctx.JSON(http.StatusOK, gin.H{"status": "ok", "staged": len(records)})
+		}
+	})
+
+	// Ключи данных версий в истории, обёрнутые старым ключом хранилища
+	group.GET("/history", func(ctx *gin.Context) {
+		userID, ok := ctx.Value("user_id").(int)
+		if !ok {
+			slog.Error("user_id not found", "method", "rotation::history")
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		keys, err := mngr.RotationHistory(ctx.Request.Context(), userID)
+		if err != nil {
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+
+		ctx.JSON(http.StatusOK, keys)
+	})
+
+	group.POST("/history", func(ctx *gin.Context) {
+		userID, ok := ctx.Value("user_id").(int)
+		if !ok {
+			slog.Error("user_id not found", "method", "rotation::history")
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		var keys []entities.HistoryKey
+		if err := ctx.BindJSON(&keys); err != nil {
+			slog.Error("Failed to parse request body", "error", err, "method", "rotation::history")
+			return
+		}
+
+		if len(keys) > maxRotationBatch {
+			ctx.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{"error": "слишком большая пачка ключей"})
+			return
+		}
+
+		err := mngr.StageHistoryKeys(ctx.Request.Context(), userID, keys)
+		if rotationResult(ctx, err, "rotation::history") {
+			ctx.JSON(http.StatusOK, gin.H{"status": "ok", "staged": len(keys)})
+		}
+	})
+
+	group.POST("/commit", func(ctx *gin.Context) {
+		userID, ok := ctx.Value("user_id").(int)
+		if !ok {
+			slog.Error("user_id not found", "method", "rotation::commit")
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		err := mngr.CommitRotation(ctx.Request.Context(), userID)
+		if rotationResult(ctx, err, "rotation::commit") {
+			slog.Info("Vault key rotated", "user_id", userID, "method", "rotation::commit")
+			ctx.JSON(http.StatusOK, gin.H{"status": "ok"})
+		}
+	})
+
+	group.DELETE("", func(ctx *gin.Context) {
+		userID, ok := ctx.Value("user_id").(int)
+		if !ok {
+			slog.Error("user_id not found", "method", "rotation::DELETE")
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		err := mngr.AbortRotation(ctx.Request.Context(), userID)
+		if rotationResult(ctx, err, "rotation::DELETE") {
+			ctx.JSON(http.StatusOK, gin.H{"status": "ok"})
+		}
+	})
+}
+
+// rotationResult отвечает клиенту по ошибке смены ключа, true — ошибки нет
+func rotationResult(ctx *gin.Context, err error, method string) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, entities.ErrRotationNotFound), errors.Is(err, entities.ErrRecordNotFound),
+		errors.Is(err, entities.ErrVersionNotFound):
+		ctx.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, entities.ErrRotationInProgress), errors.Is(err, entities.ErrRotationIncomplete):
+		ctx.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		slog.Error("Rotation error", "error", err, "method", method)
+		ctx.AbortWithError(http.StatusInternalServerError, err)
+	}
+
+	return false
+}
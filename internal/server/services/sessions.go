@@ -0,0 +1,87 @@
+package services
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"GophKeeper.ru/internal/entities"
+	"GophKeeper.ru/internal/server/storage"
+	"github.com/gin-gonic/gin"
+)
+
+func Sessions(group *gin.RouterGroup, db *storage.Database) {
+	sessions(group.Group("/sessions"), db)
+	logout(group.Group("/logout"), db)
+}
+
+// sessions просмотр и завершение активных сессий пользователя
+func sessions(group *gin.RouterGroup, mngr entities.SessionManager) {
+	group.GET("", func(ctx *gin.Context) {
+		userID, ok := ctx.Value("user_id").(int)
+		if !ok {
+			slog.Error("user_id not found", "method", "sessions::GET")
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		list, err := mngr.Sessions(ctx.Request.Context(), userID)
+		if err != nil {
+			slog.Error("Sessions error: "+err.Error(), "method", "sessions::GET")
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+
+		current := ctx.GetString("session_id")
+		for i := range list {
+			list[i].Current = list[i].ID == current
+		}
+
+		ctx.JSON(http.StatusOK, list)
+	})
+
+	group.DELETE("/:id", func(ctx *gin.Context) {
+		userID, ok := ctx.Value("user_id").(int)
+		if !ok {
+			slog.Error("user_id not found", "method", "sessions::DELETE")
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		err := mngr.RevokeSession(ctx.Request.Context(), userID, ctx.Param("id"))
+		if errors.Is(err, entities.ErrSessionNotFound) {
+			ctx.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			slog.Error("RevokeSession error: "+err.Error(), "method", "sessions::DELETE")
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		slog.Info("Session revoked", "user_id", userID, "session_id", ctx.Param("id"))
+		ctx.Status(http.StatusOK)
+	})
+}
+
+// logout завершает текущую сессию
+func logout(group *gin.RouterGroup, mngr entities.SessionManager) {
+	group.POST("", func(ctx *gin.Context) {
+		userID, ok := ctx.Value("user_id").(int)
+		if !ok {
+			slog.Error("user_id not found", "method", "logout::POST")
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		err := mngr.RevokeSession(ctx.Request.Context(), userID, ctx.GetString("session_id"))
+		if err != nil && !errors.Is(err, entities.ErrSessionNotFound) {
+			slog.Error("RevokeSession error: "+err.Error(), "method", "logout::POST")
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		clearTokenCookies(ctx)
+		ctx.JSON(http.StatusOK, gin.H{"status": "ok"})
+	})
+}
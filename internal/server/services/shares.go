@@ -0,0 +1,144 @@
+package services
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"GophKeeper.ru/internal/entities"
+	"github.com/gin-gonic/gin"
+)
+
+func Shares(group *gin.RouterGroup, mngr entities.ShareManager) {
+	publicKeys(group.Group("/keys"), mngr)
+	shares(group.Group("/shares"), mngr)
+	shared(group.Group("/shared"), mngr)
+}
+
+// publicKeys открытые ключи пользователей для выдачи им доступа
+func publicKeys(group *gin.RouterGroup, mngr entities.ShareManager) {
+	group.GET("/:login", func(ctx *gin.Context) {
+		login := ctx.Param("login")
+
+		key, err := mngr.PublicKey(ctx.Request.Context(), login)
+		if errors.Is(err, entities.ErrUserNotFound) {
+			ctx.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+		if key == "" {
+			ctx.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "у пользователя ещё нет ключа для обмена"})
+			return
+		}
+
+		ctx.JSON(http.StatusOK, gin.H{"login": login, "public_key": key})
+	})
+}
+
+// shares выдача, просмотр и отзыв доступа к своим записям
+func shares(group *gin.RouterGroup, mngr entities.ShareManager) {
+	group.POST("", func(ctx *gin.Context) {
+		userID, ok := ctx.Value("user_id").(int)
+		if !ok {
+			slog.Error("user_id not found", "method", "shares::POST")
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		var s entities.Share
+		if err := ctx.BindJSON(&s); err != nil {
+			slog.Error("Failed to parse request body", "error", err, "method", "shares::POST")
+			return
+		}
+
+		if s.Record == "" || s.Recipient == "" || s.WrappedKey == "" {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "нужны запись, получатель и ключ"})
+			return
+		}
+
+		err := mngr.CreateShare(ctx.Request.Context(), userID, &s)
+		switch {
+		case errors.Is(err, entities.ErrUserNotFound), errors.Is(err, entities.ErrRecordNotFound):
+			ctx.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		case errors.Is(err, entities.ErrShareSelf):
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		case err != nil:
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+
+		slog.Info("Record shared", "owner_id", userID, "recipient", s.Recipient, "share_id", s.ID, "method", "shares::POST")
+		ctx.JSON(http.StatusCreated, gin.H{"status": "ok", "id": s.ID})
+	})
+
+	group.GET("", func(ctx *gin.Context) {
+		userID, ok := ctx.Value("user_id").(int)
+		if !ok {
+			slog.Error("user_id not found", "method", "shares::GET")
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		list, err := mngr.Shares(ctx.Request.Context(), userID)
+		if err != nil {
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+
+		ctx.JSON(http.StatusOK, list)
+	})
+
+	group.DELETE("/:id", func(ctx *gin.Context) {
+		userID, ok := ctx.Value("user_id").(int)
+		if !ok {
+			slog.Error("user_id not found", "method", "shares::DELETE")
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		id, err := strconv.Atoi(ctx.Param("id"))
+		if err != nil {
+			ctx.AbortWithStatus(http.StatusBadRequest)
+			return
+		}
+
+		err = mngr.RevokeShare(ctx.Request.Context(), userID, id)
+		if errors.Is(err, entities.ErrShareNotFound) {
+			ctx.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+
+		slog.Info("Share revoked", "owner_id", userID, "share_id", id, "method", "shares::DELETE")
+		ctx.JSON(http.StatusOK, gin.H{"status": "ok"})
+	})
+}
+
+// shared записи других пользователей, доступные текущему
+func shared(group *gin.RouterGroup, mngr entities.ShareManager) {
+	group.GET("", func(ctx *gin.Context) {
+		userID, ok := ctx.Value("user_id").(int)
+		if !ok {
+			slog.Error("user_id not found", "method", "shared::GET")
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		list, err := mngr.SharedWith(ctx.Request.Context(), userID)
+		if err != nil {
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+
+		ctx.JSON(http.StatusOK, list)
+	})
+}
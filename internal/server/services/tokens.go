@@ -0,0 +1,88 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+
+	"GophKeeper.ru/internal/entities"
+	"GophKeeper.ru/internal/utils"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	accessCookie  = "token"
+	refreshCookie = "refresh_token"
+	refreshPath   = "/api/auth/refresh"
+)
+
+// randomToken возвращает n случайных байт в base64url
+func randomToken(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// newRefreshToken генерирует refresh-токен, на сервере хранится только его хэш
+func newRefreshToken(tokens *entities.TokenConfig) (string, *entities.RefreshToken, error) {
+	plain, err := randomToken(32)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return plain, &entities.RefreshToken{
+		Hash: utils.Sha256hash(plain),
+		TTL:  tokens.RefreshTTL,
+	}, nil
+}
+
+// setTokenCookies устанавливает access- и refresh-токены в cookie.
+// Refresh-токен уходит только на маршрут обмена.
+func setTokenCookies(ctx *gin.Context, tokens *entities.TokenConfig, access, refresh string) {
+	ctx.SetCookie(accessCookie, access, int(tokens.TTL.Seconds()), "/", "", false, true)
+	ctx.SetCookie(refreshCookie, refresh, int(tokens.RefreshTTL.Seconds()), refreshPath, "", false, true)
+}
+
+// clearTokenCookies удаляет cookie с токенами
+func clearTokenCookies(ctx *gin.Context) {
+	ctx.SetCookie(accessCookie, "", -1, "/", "", false, true)
+	ctx.SetCookie(refreshCookie, "", -1, refreshPath, "", false, true)
+}
+
+// issueTokens открывает новую сессию после входа
+// и выдаёт пару токенов пользователю
+func issueTokens(ctx *gin.Context, mngr entities.SessionManager, tokens *entities.TokenConfig, u *entities.User) error {
+	sessionID, err := randomToken(16)
+	if err != nil {
+		return err
+	}
+
+	session := &entities.Session{
+		ID:        sessionID,
+		UserAgent: ctx.Request.UserAgent(),
+		IP:        ctx.ClientIP(),
+	}
+	if err = mngr.CreateSession(ctx.Request.Context(), u.ID, session); err != nil {
+		return err
+	}
+
+	access, err := entities.GetToken(u, sessionID, tokens)
+	if err != nil {
+		return err
+	}
+
+	plain, refresh, err := newRefreshToken(tokens)
+	if err != nil {
+		return err
+	}
+	refresh.UserID = u.ID
+	refresh.FamilyID = sessionID
+
+	if err = mngr.CreateRefreshToken(ctx.Request.Context(), refresh); err != nil {
+		return err
+	}
+
+	setTokenCookies(ctx, tokens, access, plain)
+	return nil
+}
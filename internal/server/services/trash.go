@@ -0,0 +1,95 @@
+package services
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"GophKeeper.ru/internal/entities"
+	"GophKeeper.ru/internal/server/blobstore"
+	"github.com/gin-gonic/gin"
+)
+
+func Trash(group *gin.RouterGroup, mngr entities.TrashManager, store blobstore.Store) {
+	trash(group.Group("/trash"), mngr, store)
+}
+
+// trash корзина удалённых записей: просмотр, возврат и окончательное удаление
+func trash(group *gin.RouterGroup, mngr entities.TrashManager, store blobstore.Store) {
+	group.GET("", func(ctx *gin.Context) {
+		userID, ok := ctx.Value("user_id").(int)
+		if !ok {
+			slog.Error("user_id not found", "method", "trash::GET")
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		items, err := mngr.Trash(ctx.Request.Context(), userID)
+		if err != nil {
+			slog.Error("Trash error: "+err.Error(), "method", "trash::GET")
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+
+		ctx.JSON(http.StatusOK, items)
+	})
+
+	group.POST("/:id/restore", func(ctx *gin.Context) {
+		userID, ok := ctx.Value("user_id").(int)
+		if !ok {
+			slog.Error("user_id not found", "method", "trash::restore")
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		record, err := mngr.UndeleteRecord(ctx.Request.Context(), userID, ctx.Param("id"))
+		if errors.Is(err, entities.ErrRotationInProgress) || errors.Is(err, entities.ErrRecordExists) {
+			ctx.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, entities.ErrRecordNotFound) {
+			ctx.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			slog.Error("UndeleteRecord error: "+err.Error(), "method", "trash::restore")
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		ctx.JSON(http.StatusOK, record)
+	})
+
+	group.DELETE("/:id", func(ctx *gin.Context) {
+		userID, ok := ctx.Value("user_id").(int)
+		if !ok {
+			slog.Error("user_id not found", "method", "trash::DELETE")
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		blobs, err := mngr.PurgeRecord(ctx.Request.Context(), userID, ctx.Param("id"))
+		if errors.Is(err, entities.ErrRotationInProgress) {
+			ctx.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, entities.ErrRecordNotFound) {
+			ctx.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			slog.Error("PurgeRecord error: "+err.Error(), "method", "trash::DELETE")
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		// Строки уже удалены, оставшиеся файлы не мешают, только занимают место
+		for _, id := range blobs {
+			if err = store.Remove(ctx.Request.Context(), id); err != nil {
+				slog.Error("Failed to remove blob chunks", "blob_id", id, "error", err, "method", "trash::DELETE")
+			}
+		}
+
+		ctx.JSON(http.StatusOK, gin.H{"status": "ok"})
+	})
+}
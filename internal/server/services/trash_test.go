@@ -0,0 +1,68 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"GophKeeper.ru/internal/entities"
+)
+
+// fakeTrash корзина, окончательно удаляющая записи из records
+type fakeTrash struct {
+	entities.TrashManager
+	records map[string][]string // запись и ID её двоичных данных
+	err     error
+}
+
+func (f *fakeTrash) PurgeRecord(ctx context.Context, userID int, key string) ([]string, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	blobs, ok := f.records[key]
+	if !ok {
+		return nil, entities.ErrRecordNotFound
+	}
+	delete(f.records, key)
+	return blobs, nil
+}
+
+func TestPurgeRemovesBlobChunks(t *testing.T) {
+	tests := []struct {
+		name    string
+		key     string
+		err     error
+		status  int
+		removed []string
+	}{
+		{name: "purged", key: "file", status: http.StatusOK, removed: []string{"b1", "b2"}},
+		{name: "not in trash", key: "other", status: http.StatusNotFound},
+		{name: "rotation", key: "file", err: entities.ErrRotationInProgress, status: http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mngr := &fakeTrash{records: map[string][]string{"file": {"b1", "b2"}}, err: tt.err}
+			store := &fakeStore{}
+			r, group := testRouter(1)
+			Trash(group, mngr, store)
+
+			req := httptest.NewRequest(http.MethodDelete, "/api/trash/"+tt.key, nil)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.status {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if len(store.removed) != len(tt.removed) {
+				t.Fatalf("removed %v, want %v", store.removed, tt.removed)
+			}
+			for i, id := range tt.removed {
+				if store.removed[i] != id {
+					t.Fatalf("removed %v, want %v", store.removed, tt.removed)
+				}
+			}
+		})
+	}
+}
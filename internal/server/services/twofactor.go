@@ -0,0 +1,149 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"GophKeeper.ru/internal/entities"
+	"GophKeeper.ru/internal/server/storage"
+	"GophKeeper.ru/internal/server/totp"
+	"GophKeeper.ru/internal/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// recoveryCodesCount количество кодов восстановления, выдаваемых при включении 2FA
+const recoveryCodesCount = 10
+
+func TwoFactor(group *gin.RouterGroup, db *storage.Database, tokens *entities.TokenConfig) {
+	twoFactor(group.Group("/2fa"), db, db, tokens)
+}
+
+// twoFactor подключение TOTP к учётной записи
+func twoFactor(group *gin.RouterGroup, mngr entities.AuthManager, tfMngr entities.TwoFactorManager, tokens *entities.TokenConfig) {
+	group.POST("/enroll", func(ctx *gin.Context) {
+		userID, ok := ctx.Value("user_id").(int)
+		if !ok {
+			slog.Error("user_id not found", "method", "2fa::enroll")
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		tf, err := tfMngr.TwoFactor(ctx.Request.Context(), userID)
+		if err != nil {
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+		if tf.Enabled {
+			ctx.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "2FA уже включена"})
+			return
+		}
+
+		u, err := mngr.UserFromID(ctx.Request.Context(), userID)
+		if err != nil || u == nil {
+			slog.Error("User not found", "user_id", userID, "error", err, "method", "2fa::enroll")
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		secret, err := totp.GenerateSecret()
+		if err != nil {
+			slog.Error("Failed to generate TOTP secret", "error", err, "method", "2fa::enroll")
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+
+		if err = tfMngr.SetTOTPSecret(ctx.Request.Context(), userID, secret); err != nil {
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+
+		ctx.JSON(http.StatusOK, gin.H{
+			"secret": secret,
+			"uri":    totp.URI(tokens.Issuer, u.Login, secret),
+		})
+	})
+
+	group.POST("/confirm", func(ctx *gin.Context) {
+		userID, ok := ctx.Value("user_id").(int)
+		if !ok {
+			slog.Error("user_id not found", "method", "2fa::confirm")
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		var req struct {
+			Code string `json:"code"`
+		}
+		if err := ctx.BindJSON(&req); err != nil {
+			return
+		}
+
+		tf, err := tfMngr.TwoFactor(ctx.Request.Context(), userID)
+		if err != nil {
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+		if tf.Enabled {
+			ctx.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "2FA уже включена"})
+			return
+		}
+		if tf.Secret == "" {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "сначала выполните /api/2fa/enroll"})
+			return
+		}
+
+		step, ok := totp.Validate(req.Code, tf.Secret, time.Now())
+		if !ok {
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "неверный код"})
+			return
+		}
+
+		codes, hashes, err := newRecoveryCodes(recoveryCodesCount)
+		if err != nil {
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+
+		if err = tfMngr.EnableTOTP(ctx.Request.Context(), userID, step, hashes); err != nil {
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+
+		slog.Info("Two-factor authentication enabled", "user_id", userID)
+		ctx.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+	})
+}
+
+// verifySecondFactor проверяет TOTP-код либо одноразовый код восстановления
+func verifySecondFactor(ctx *gin.Context, tfMngr entities.TwoFactorManager, userID int, tf *entities.TwoFactor, code string) (bool, error) {
+	if step, ok := totp.Validate(code, tf.Secret, time.Now()); ok {
+		return tfMngr.UseTOTPStep(ctx.Request.Context(), userID, step)
+	}
+
+	return tfMngr.UseRecoveryCode(ctx.Request.Context(), userID, utils.Sha256hash(normalizeRecoveryCode(code)))
+}
+
+// newRecoveryCodes генерирует коды вида XXXXX-XXXXX и их хэши для хранения
+func newRecoveryCodes(n int) ([]string, []string, error) {
+	codes := make([]string, n)
+	hashes := make([]string, n)
+	for i := range codes {
+		buf := make([]byte, 7)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, nil, err
+		}
+		raw := base32.StdEncoding.EncodeToString(buf)[:10]
+		codes[i] = raw[:5] + "-" + raw[5:]
+		hashes[i] = utils.Sha256hash(raw)
+	}
+	return codes, hashes, nil
+}
+
+// normalizeRecoveryCode убирает разделители и приводит код к верхнему регистру
+func normalizeRecoveryCode(code string) string {
+	return strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+}
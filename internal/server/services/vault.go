@@ -0,0 +1,152 @@
+package services
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"GophKeeper.ru/internal/entities"
+	"github.com/gin-gonic/gin"
+)
+
+func Vault(group *gin.RouterGroup, mngr entities.VaultManager) {
+	vault(group.Group("/vault"), mngr)
+}
+
+// vault хранение параметров KDF и зашифрованного ключа хранилища.
+// Сервер видит только соль, параметры и обёрнутый ключ.
+func vault(group *gin.RouterGroup, mngr entities.VaultManager) {
+	group.GET("", func(ctx *gin.Context) {
+		userID, ok := ctx.Value("user_id").(int)
+		if !ok {
+			slog.Error("user_id not found", "method", "vault::GET")
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		v, err := mngr.Vault(ctx.Request.Context(), userID)
+		if err != nil {
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+		if v == nil {
+			ctx.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+
+		ctx.JSON(http.StatusOK, v)
+	})
+
+	group.POST("", func(ctx *gin.Context) {
+		userID, ok := ctx.Value("user_id").(int)
+		if !ok {
+			slog.Error("user_id not found", "method", "vault::POST")
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		var v entities.Vault
+		if err := ctx.BindJSON(&v); err != nil {
+			slog.Error("Failed to parse request body", "error", err, "method", "vault::POST")
+			return
+		}
+
+		if !validVault(&v) {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "неполные параметры хранилища"})
+			return
+		}
+
+		err := mngr.SaveVault(ctx.Request.Context(), userID, &v)
+		if errors.Is(err, entities.ErrVaultKeyMismatch) {
+			slog.Warn("Attempt to replace vault key", "user_id", userID, "method", "vault::POST")
+			ctx.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+
+		ctx.JSON(http.StatusOK, gin.H{"status": "ok"})
+	})
+	group.POST("/recovery", func(ctx *gin.Context) {
+		userID, ok := ctx.Value("user_id").(int)
+		if !ok {
+			slog.Error("user_id not found", "method", "vault::recovery")
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		var req struct {
+			KeyID       string `json:"key_id"`
+			RecoveryKey string `json:"recovery_key"`
+		}
+		if err := ctx.BindJSON(&req); err != nil {
+			slog.Error("Failed to parse request body", "error", err, "method", "vault::recovery")
+			return
+		}
+
+		if req.KeyID == "" || req.RecoveryKey == "" {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "неполные параметры ключа восстановления"})
+			return
+		}
+
+		err := mngr.SetRecoveryKey(ctx.Request.Context(), userID, req.KeyID, req.RecoveryKey)
+		if errors.Is(err, entities.ErrVaultKeyMismatch) {
+			ctx.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+
+		slog.Info("Recovery key replaced", "user_id", userID, "method", "vault::recovery")
+		ctx.JSON(http.StatusOK, gin.H{"status": "ok"})
+	})
+	group.POST("/keypair", func(ctx *gin.Context) {
+		userID, ok := ctx.Value("user_id").(int)
+		if !ok {
+			slog.Error("user_id not found", "method", "vault::keypair")
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		var req struct {
+			KeyID      string `json:"key_id"`
+			PublicKey  string `json:"public_key"`
+			PrivateKey string `json:"private_key"`
+		}
+		if err := ctx.BindJSON(&req); err != nil {
+			slog.Error("Failed to parse request body", "error", err, "method", "vault::keypair")
+			return
+		}
+
+		if req.KeyID == "" || req.PublicKey == "" || req.PrivateKey == "" {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "неполные параметры пары ключей"})
+			return
+		}
+
+		err := mngr.SetKeyPair(ctx.Request.Context(), userID, req.KeyID, req.PublicKey, req.PrivateKey)
+		if errors.Is(err, entities.ErrVaultKeyMismatch) {
+			ctx.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "пара ключей уже создана"})
+			return
+		}
+		if err != nil {
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+
+		ctx.JSON(http.StatusOK, gin.H{"status": "ok"})
+	})
+}
+
+// validVault проверяет, что хранилище содержит полный набор параметров KDF
+// в пределах, которые клиент согласится выполнить
+func validVault(v *entities.Vault) bool {
+	return v.KDF.Version > 0 && v.KDF.Salt != "" && len(v.KDF.Salt) <= 128 &&
+		v.KDF.Time > 0 && v.KDF.Time <= 10 &&
+		v.KDF.Memory > 0 && v.KDF.Memory <= 1<<20 &&
+		v.KDF.Threads > 0 && v.KDF.Threads <= 64 &&
+		v.WrappedKey != "" && v.KeyID != "" && len(v.LegacyTag) <= 64
+}
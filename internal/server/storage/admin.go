@@ -0,0 +1,278 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"GophKeeper.ru/internal/entities"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// pgUniqueViolation код ошибки PostgreSQL при нарушении уникального индекса
+const pgUniqueViolation = "23505"
+
+// scanUsers читает список пользователей вида id, name, is_disable, is_admin, cert_identity, lockColumns
+func scanUsers(rows *sql.Rows, method string) ([]entities.User, error) {
+	defer rows.Close()
+
+	out := []entities.User{}
+	for rows.Next() {
+		var (
+			u       entities.User
+			certID  sql.NullString
+			until   sql.NullTime
+			seconds sql.NullInt64
+		)
+		if err := rows.Scan(&u.ID, &u.Login, &u.IsDisable, &u.IsAdmin, &certID, &until, &seconds); err != nil {
+			slog.Error("Failed to scan user row",
+				"error", err,
+				"method", method)
+			return nil, err
+		}
+		scanLock(&u, until, seconds)
+		u.CertID = certID.String
+		out = append(out, u)
+	}
+
+	return out, rows.Err()
+}
+
+// Users возвращает всех пользователей.
+func (db *Database) Users(ctx context.Context) ([]entities.User, error) {
+	rows, err := db.conn.QueryContext(ctx, `
+        SELECT id, name, is_disable, is_admin, cert_identity, `+lockColumns+`
+        FROM users
+        ORDER BY id
+    `)
+	if err != nil {
+		slog.Error("Failed to query users",
+			"error", err,
+			"method", "Users")
+		return nil, err
+	}
+
+	return scanUsers(rows, "Users")
+}
+
+// LockedUsers возвращает пользователей, вход которых сейчас заблокирован.
+func (db *Database) LockedUsers(ctx context.Context) ([]entities.User, error) {
+	rows, err := db.conn.QueryContext(ctx, `
+        SELECT id, name, is_disable, is_admin, cert_identity, `+lockColumns+`
+        FROM users
+        WHERE locked_until > CURRENT_TIMESTAMP
+        ORDER BY locked_until DESC
+    `)
+	if err != nil {
+		slog.Error("Failed to query locked users",
+			"error", err,
+			"method", "LockedUsers")
+		return nil, err
+	}
+
+	return scanUsers(rows, "LockedUsers")
+}
+
+// revokeUserSessions завершает все сессии пользователя
+func revokeUserSessions(ctx context.Context, tx *sql.Tx, id int) error {
+	_, err := tx.ExecContext(ctx, `
+        UPDATE refresh_tokens SET revoked_at = CURRENT_TIMESTAMP
+        WHERE user_id = $1 AND revoked_at IS NULL
+    `, id)
+	if err != nil {
+		return fmt.Errorf("failed to revoke refresh tokens: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+        UPDATE sessions SET revoked_at = CURRENT_TIMESTAMP
+        WHERE user_id = $1 AND revoked_at IS NULL
+    `, id)
+	if err != nil {
+		return fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+
+	return nil
+}
+
+// updateUser выполняет изменение пользователя в транзакции,
+// при необходимости завершая все его сессии.
+func (db *Database) updateUser(ctx context.Context, method string, id int, revoke bool, query string, args ...any) error {
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		slog.Error("Failed to begin transaction",
+			"error", err,
+			"method", method)
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		slog.Error("Failed to update user",
+			"user_id", id,
+			"error", err,
+			"method", method)
+		return fmt.Errorf("failed to update user: %w", err)
+	}
+
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return entities.ErrUserNotFound
+	}
+
+	if revoke {
+		if err = revokeUserSessions(ctx, tx, id); err != nil {
+			slog.Error("Failed to revoke user sessions",
+				"user_id", id,
+				"error", err,
+				"method", method)
+			return err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		slog.Error("Failed to commit transaction",
+			"error", err,
+			"method", method)
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// SetUserDisabled отключает или включает пользователя.
+func (db *Database) SetUserDisabled(ctx context.Context, id int, disabled bool) error {
+	return db.updateUser(ctx, "SetUserDisabled", id, disabled, `
+        UPDATE users
+        SET is_disable = $1,
+            locked_until = CASE WHEN $1 THEN locked_until ELSE NULL END
+        WHERE id = $2
+    `, disabled, id)
+}
+
+// ResetPassword задаёт новый пароль пользователю.
+func (db *Database) ResetPassword(ctx context.Context, id int, hash string) error {
+	return db.updateUser(ctx, "ResetPassword", id, true, `
+        UPDATE users SET password = $1, locked_until = NULL
+        WHERE id = $2
+    `, hash, id)
+}
+
+// SetAdmin выдаёт или снимает роль администратора.
+func (db *Database) SetAdmin(ctx context.Context, login string, admin bool) error {
+	res, err := db.conn.ExecContext(ctx,
+		"UPDATE users SET is_admin = $1 WHERE name = $2", admin, login)
+	if err != nil {
+		slog.Error("Failed to set admin role",
+			"login", login,
+			"error", err,
+			"method", "SetAdmin")
+		return fmt.Errorf("failed to set admin role: %w", err)
+	}
+
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return entities.ErrUserNotFound
+	}
+
+	return nil
+}
+
+// DeleteUser удаляет пользователя и все его записи. Строки blobs удалились
+// бы каскадом, но тогда фрагменты остались бы в хранилище без ссылок на
+// них, поэтому они удаляются явно и их ID возвращаются вызывающему.
+func (db *Database) DeleteUser(ctx context.Context, id int) ([]string, error) {
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		slog.Error("Failed to begin transaction",
+			"error", err,
+			"method", "DeleteUser")
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// data ссылается на users с ON DELETE SET NULL, поэтому чистим явно
+	if _, err = tx.ExecContext(ctx, "DELETE FROM data WHERE user_id = $1", id); err != nil {
+		slog.Error("Failed to delete user data",
+			"user_id", id,
+			"error", err,
+			"method", "DeleteUser")
+		return nil, fmt.Errorf("failed to delete user data: %w", err)
+	}
+
+	blobs, err := deleteBlobs(ctx, tx, id)
+	if err != nil {
+		slog.Error("Failed to delete user blobs",
+			"user_id", id,
+			"error", err,
+			"method", "DeleteUser")
+		return nil, fmt.Errorf("failed to delete user blobs: %w", err)
+	}
+
+	res, err := tx.ExecContext(ctx, "DELETE FROM users WHERE id = $1", id)
+	if err != nil {
+		slog.Error("Failed to delete user",
+			"user_id", id,
+			"error", err,
+			"method", "DeleteUser")
+		return nil, fmt.Errorf("failed to delete user: %w", err)
+	}
+
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return nil, entities.ErrUserNotFound
+	}
+
+	if err = tx.Commit(); err != nil {
+		slog.Error("Failed to commit transaction",
+			"error", err,
+			"method", "DeleteUser")
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return blobs, nil
+}
+
+// deleteBlobs удаляет строки двоичных данных пользователя и возвращает их ID
+func deleteBlobs(ctx context.Context, tx *sql.Tx, userID int) ([]string, error) {
+	rows, err := tx.QueryContext(ctx, "DELETE FROM blobs WHERE user_id = $1 RETURNING id", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var blobs []string
+	for rows.Next() {
+		var id string
+		if err = rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		blobs = append(blobs, id)
+	}
+
+	return blobs, rows.Err()
+}
+
+// SetCertIdentity привязывает сертификат клиента к пользователю.
+func (db *Database) SetCertIdentity(ctx context.Context, id int, identity string) error {
+	value := sql.NullString{String: identity, Valid: identity != ""}
+
+	res, err := db.conn.ExecContext(ctx,
+		"UPDATE users SET cert_identity = $1 WHERE id = $2", value, id)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == pgUniqueViolation {
+			return entities.ErrCertIdentityTaken
+		}
+		slog.Error("Failed to set certificate identity",
+			"user_id", id,
+			"error", err,
+			"method", "SetCertIdentity")
+		return fmt.Errorf("failed to set certificate identity: %w", err)
+	}
+
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return entities.ErrUserNotFound
+	}
+
+	return nil
+}
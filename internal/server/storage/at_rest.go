@@ -0,0 +1,165 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"strconv"
+
+	"GophKeeper.ru/internal/server/keyring"
+)
+
+// SetKeyring включает шифрование столбца data.value ключами сервера.
+// Без ключей значения хранятся в том виде, в каком их прислал клиент.
+func (db *Database) SetKeyring(keys *keyring.Keyring) {
+	db.keys = keys
+}
+
+// rowAD связанные данные строки data: значение нельзя перенести в другую строку
+func rowAD(userID int, name string) string {
+	return strconv.Itoa(userID) + ":" + name
+}
+
+// sealValue шифрует значение активным ключом сервера и возвращает его kid
+func (db *Database) sealValue(userID int, name, value string) (sql.NullString, string, error) {
+	if db.keys == nil {
+		return sql.NullString{}, value, nil
+	}
+
+	kid, sealed, err := db.keys.Seal(value, rowAD(userID, name))
+	if err != nil {
+		return sql.NullString{}, "", err
+	}
+
+	return sql.NullString{String: kid, Valid: true}, sealed, nil
+}
+
+// openValue расшифровывает значение строки, kid NULL — значение не зашифровано
+func (db *Database) openValue(userID int, name string, kid sql.NullString, value string) (string, error) {
+	if !kid.Valid {
+		return value, nil
+	}
+
+	if db.keys == nil {
+		return "", fmt.Errorf("%w: %s", keyring.ErrUnknownKey, kid.String)
+	}
+
+	return db.keys.Open(kid.String, value, rowAD(userID, name))
+}
+
+// ReencryptCursor позиция прохода перешифрования: последний просмотренный
+// id в каждой таблице. Строки, которые не удалось перешифровать, остаются
+// позади курсора и не останавливают проход.
+type ReencryptCursor struct {
+	Data    int
+	History int
+}
+
+// ReencryptValues перешифровывает активным ключом до limit строк data и
+// data_history после курсора, зашифрованных прежними ключами или не
+// зашифрованных вовсе, и сдвигает курсор. Возвращает число перешифрованных
+// и число просмотренных строк: проход окончен, когда просмотрено меньше limit.
+func (db *Database) ReencryptValues(ctx context.Context, cur *ReencryptCursor, limit int) (int, int, error) {
+	if db.keys == nil {
+		return 0, 0, nil
+	}
+
+	done, seen, err := db.reencryptTable(ctx, "data", &cur.Data, limit)
+	if err != nil || seen >= limit {
+		return done, seen, err
+	}
+
+	d, s, err := db.reencryptTable(ctx, "data_history", &cur.History, limit-seen)
+	return done + d, seen + s, err
+}
+
+// reencryptTable перешифровывает до limit строк одной таблицы значений с id
+// больше *after. Строку, которую не удалось расшифровать, пропускает с записью
+// в журнал. Имя таблицы берётся только из констант вызывающего кода.
+func (db *Database) reencryptTable(ctx context.Context, table string, after *int, limit int) (int, int, error) {
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		slog.Error("Failed to begin transaction",
+			"error", err,
+			"table", table,
+			"method", "reencryptTable")
+		return 0, 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `
+        SELECT id, user_id, name, value, value_kid FROM `+table+`
+        WHERE id > $1 AND user_id IS NOT NULL AND value_kid IS DISTINCT FROM $2
+        ORDER BY id
+        LIMIT $3
+        FOR UPDATE SKIP LOCKED
+    `, *after, db.keys.Active(), limit)
+	if err != nil {
+		slog.Error("Failed to query rows for re-encryption",
+			"error", err,
+			"table", table,
+			"method", "reencryptTable")
+		return 0, 0, err
+	}
+
+	type row struct {
+		id, userID  int
+		name, value string
+		kid         sql.NullString
+	}
+	var batch []row
+	for rows.Next() {
+		var r row
+		if err = rows.Scan(&r.id, &r.userID, &r.name, &r.value, &r.kid); err != nil {
+			rows.Close()
+			return 0, 0, err
+		}
+		batch = append(batch, r)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return 0, 0, err
+	}
+
+	last, done := *after, 0
+	for _, r := range batch {
+		last = r.id
+
+		plain, err := db.openValue(r.userID, r.name, r.kid, r.value)
+		if err != nil {
+			// Повреждённая строка или строка под утерянным ключом не должна
+			// блокировать перешифрование остальных
+			slog.Error("Failed to decrypt row for re-encryption, skipped",
+				"id", r.id,
+				"kid", r.kid.String,
+				"error", err,
+				"table", table,
+				"method", "reencryptTable")
+			continue
+		}
+
+		kid, sealed, err := db.sealValue(r.userID, r.name, plain)
+		if err != nil {
+			return 0, 0, err
+		}
+
+		// update_id не меняется: клиентские данные остались прежними
+		if _, err = tx.ExecContext(ctx, "UPDATE "+table+" SET value = $1, value_kid = $2 WHERE id = $3",
+			sealed, kid, r.id); err != nil {
+			return 0, 0, fmt.Errorf("failed to re-encrypt row: %w", err)
+		}
+		done++
+	}
+
+	if err = tx.Commit(); err != nil {
+		slog.Error("Failed to commit transaction",
+			"error", err,
+			"table", table,
+			"method", "reencryptTable")
+		return 0, 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	*after = last
+	return done, len(batch), nil
+}
@@ -0,0 +1,348 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"GophKeeper.ru/internal/entities"
+)
+
+// CreateBlob начинает загрузку двоичных данных. Квота проверяется под
+// блокировкой пользователя, чтобы параллельные загрузки не обошли её.
+func (db *Database) CreateBlob(ctx context.Context, userID int, b *entities.Blob, quota entities.BlobQuota) error {
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		slog.Error("Failed to begin transaction",
+			"error", err,
+			"method", "CreateBlob")
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.ExecContext(ctx, "SELECT 1 FROM users WHERE id = $1 FOR UPDATE", userID); err != nil {
+		return fmt.Errorf("failed to lock user: %w", err)
+	}
+
+	var (
+		used int64
+		open int
+	)
+	err = tx.QueryRowContext(ctx, `
+        SELECT COALESCE(SUM(size), 0), COUNT(*) FILTER (WHERE NOT complete) FROM blobs
+        WHERE user_id = $1
+    `, userID).Scan(&used, &open)
+	if err != nil {
+		return fmt.Errorf("failed to count blobs: %w", err)
+	}
+	if quota.MaxOpen > 0 && open >= quota.MaxOpen {
+		return entities.ErrTooManyUploads
+	}
+	if quota.MaxBytes > 0 && used+b.Size > quota.MaxBytes {
+		return fmt.Errorf("%w: занято %d из %d байт", entities.ErrBlobQuota, used, quota.MaxBytes)
+	}
+
+	err = tx.QueryRowContext(ctx, `
+        INSERT INTO blobs (id, user_id, size, chunks, record)
+        VALUES ($1, $2, $3, $4, NULLIF($5, ''))
+        RETURNING created_at
+    `, b.ID, userID, b.Size, b.Chunks, b.Record).Scan(&b.CreatedAt)
+	if err != nil {
+		slog.Error("Failed to create blob",
+			"user_id", userID,
+			"error", err,
+			"method", "CreateBlob")
+		return fmt.Errorf("failed to create blob: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// Blob возвращает состояние загрузки и номера полученных фрагментов.
+func (db *Database) Blob(ctx context.Context, userID int, id string) (*entities.Blob, error) {
+	return db.blob(ctx, "Blob", userID, id, `
+        SELECT size, chunks, complete, COALESCE(record, ''), created_at FROM blobs
+        WHERE id = $1 AND user_id = $2
+    `)
+}
+
+// ReadableBlob возвращает данные пользователя или файл записи, к которой
+// ему выдан доступ. Получателю доступен только файл текущей версии записи,
+// и только пока она не удалена в корзину.
+func (db *Database) ReadableBlob(ctx context.Context, userID int, id string) (*entities.Blob, error) {
+	return db.blob(ctx, "ReadableBlob", userID, id, `
+        SELECT b.size, b.chunks, b.complete, COALESCE(b.record, ''), b.created_at FROM blobs b
+        WHERE b.id = $1 AND (b.user_id = $2 OR EXISTS (
+            SELECT 1 FROM shares s
+            JOIN data d ON d.user_id = s.owner_id AND d.name = s.record_name
+            WHERE s.recipient_id = $2 AND d.user_id = b.user_id
+                AND d.blob_id = b.id AND d.deleted_at IS NULL
+        ))
+    `)
+}
+
+// blob выполняет запрос загрузки query с параметрами id и userID и
+// дополняет её списком полученных фрагментов
+func (db *Database) blob(ctx context.Context, method string, userID int, id string, query string) (*entities.Blob, error) {
+	b := entities.Blob{ID: id}
+	err := db.conn.QueryRowContext(ctx, query, id, userID).Scan(&b.Size, &b.Chunks, &b.Complete, &b.Record, &b.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, entities.ErrBlobNotFound
+	}
+	if err != nil {
+		slog.Error("Failed to fetch blob",
+			"user_id", userID,
+			"blob_id", id,
+			"error", err,
+			"method", method)
+		return nil, err
+	}
+
+	rows, err := db.conn.QueryContext(ctx, "SELECT n FROM blob_chunks WHERE blob_id = $1 ORDER BY n", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var n int
+		if err = rows.Scan(&n); err != nil {
+			return nil, err
+		}
+		b.Received = append(b.Received, n)
+	}
+
+	return &b, rows.Err()
+}
+
+// PutChunk отмечает фрагмент загруженным. Повторная загрузка фрагмента
+// заменяет его размер, завершённую загрузку изменить нельзя. commit
+// заменяет сам фрагмент в хранилище: он вызывается под блокировкой строки
+// загрузки, поэтому ни завершение, ни удаление загрузки не пройдут между
+// проверкой и заменой файла.
+func (db *Database) PutChunk(ctx context.Context, userID int, id string, n int, size int64, commit func() error) error {
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		slog.Error("Failed to begin transaction",
+			"error", err,
+			"method", "PutChunk")
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var complete bool
+	err = tx.QueryRowContext(ctx, `
+        SELECT complete FROM blobs
+        WHERE id = $1 AND user_id = $2
+        FOR SHARE
+    `, id, userID).Scan(&complete)
+	if errors.Is(err, sql.ErrNoRows) {
+		return entities.ErrBlobNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if complete {
+		return entities.ErrBlobComplete
+	}
+
+	_, err = tx.ExecContext(ctx, `
+        INSERT INTO blob_chunks (blob_id, n, size) VALUES ($1, $2, $3)
+        ON CONFLICT (blob_id, n) DO UPDATE SET size = EXCLUDED.size
+    `, id, n, size)
+	if err != nil {
+		slog.Error("Failed to save blob chunk",
+			"user_id", userID,
+			"blob_id", id,
+			"chunk", n,
+			"error", err,
+			"method", "PutChunk")
+		return fmt.Errorf("failed to save blob chunk: %w", err)
+	}
+
+	if err = commit(); err != nil {
+		return fmt.Errorf("failed to commit blob chunk: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// CompleteBlob завершает загрузку: должны быть получены все фрагменты,
+// а их общий размер — совпадать с заявленным.
+func (db *Database) CompleteBlob(ctx context.Context, userID int, id string) error {
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var (
+		size     int64
+		chunks   int
+		complete bool
+	)
+	err = tx.QueryRowContext(ctx, `
+        SELECT size, chunks, complete FROM blobs
+        WHERE id = $1 AND user_id = $2
+        FOR UPDATE
+    `, id, userID).Scan(&size, &chunks, &complete)
+	if errors.Is(err, sql.ErrNoRows) {
+		return entities.ErrBlobNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if complete {
+		return nil
+	}
+
+	var (
+		received int
+		total    int64
+	)
+	err = tx.QueryRowContext(ctx, `
+        SELECT COUNT(*), COALESCE(SUM(size), 0) FROM blob_chunks
+        WHERE blob_id = $1 AND n < $2
+    `, id, chunks).Scan(&received, &total)
+	if err != nil {
+		return err
+	}
+	if received != chunks || total != size {
+		return fmt.Errorf("%w: %d из %d, %d из %d байт", entities.ErrBlobIncomplete, received, chunks, total, size)
+	}
+
+	if _, err = tx.ExecContext(ctx, "UPDATE blobs SET complete = TRUE WHERE id = $1", id); err != nil {
+		slog.Error("Failed to complete blob",
+			"user_id", userID,
+			"blob_id", id,
+			"error", err,
+			"method", "CompleteBlob")
+		return fmt.Errorf("failed to complete blob: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// RemoveBlob удаляет загрузку вместе с отметками о фрагментах.
+func (db *Database) RemoveBlob(ctx context.Context, userID int, id string) error {
+	res, err := db.conn.ExecContext(ctx, "DELETE FROM blobs WHERE id = $1 AND user_id = $2", id, userID)
+	if err != nil {
+		slog.Error("Failed to remove blob",
+			"user_id", userID,
+			"blob_id", id,
+			"error", err,
+			"method", "RemoveBlob")
+		return fmt.Errorf("failed to remove blob: %w", err)
+	}
+
+	if n, _ := res.RowsAffected(); n == 0 {
+		return entities.ErrBlobNotFound
+	}
+
+	return nil
+}
+
+// LinkBlob связывает двоичные данные с записью, которая на них ссылается.
+// Нужна для данных, загруженных до того, как сервер стал знать о ссылках:
+// после связи они удаляются вместе с записью или без ссылок на них.
+func (db *Database) LinkBlob(ctx context.Context, userID int, id, record string) error {
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		slog.Error("Failed to begin transaction",
+			"error", err,
+			"method", "LinkBlob")
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `
+        UPDATE blobs SET record = $3, tracked = TRUE
+        WHERE id = $1 AND user_id = $2 AND (record IS NULL OR record = $3)
+    `, id, userID, record)
+	if err != nil {
+		slog.Error("Failed to link blob",
+			"user_id", userID,
+			"blob_id", id,
+			"error", err,
+			"method", "LinkBlob")
+		return fmt.Errorf("failed to link blob: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return entities.ErrBlobNotFound
+	}
+
+	res, err = tx.ExecContext(ctx, "UPDATE data SET blob_id = $1 WHERE user_id = $2 AND name = $3", id, userID, record)
+	if err != nil {
+		return fmt.Errorf("failed to link blob: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return entities.ErrRecordNotFound
+	}
+
+	return tx.Commit()
+}
+
+// CollectBlobs удаляет двоичные данные, на которые больше не ссылаются ни
+// записи, ни их версии в истории: заменённый файл живёт, пока жива версия
+// записи с ним. Завершённые загрузки, которые за age так и не попали в
+// запись, тоже удаляются, кроме загрузок прежних клиентов. Возвращает ID
+// удалённых, их фрагменты удаляет вызывающий.
+func (db *Database) CollectBlobs(ctx context.Context, age time.Duration) ([]string, error) {
+	rows, err := db.conn.QueryContext(ctx, `
+        DELETE FROM blobs b
+        WHERE (b.tracked OR (b.complete AND NOT b.legacy
+                AND b.created_at < CURRENT_TIMESTAMP - $1 * INTERVAL '1 second'))
+            AND NOT EXISTS (SELECT 1 FROM data d WHERE d.user_id = b.user_id AND d.blob_id = b.id)
+            AND NOT EXISTS (SELECT 1 FROM data_history h WHERE h.user_id = b.user_id AND h.blob_id = b.id)
+        RETURNING b.id
+    `, int64(age.Seconds()))
+	if err != nil {
+		slog.Error("Failed to collect blobs",
+			"error", err,
+			"method", "CollectBlobs")
+		return nil, fmt.Errorf("failed to collect blobs: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err = rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// PurgeStaleBlobs удаляет загрузки, не завершённые за age. Возвращает ID
+// удалённых, их фрагменты удаляет вызывающий.
+func (db *Database) PurgeStaleBlobs(ctx context.Context, age time.Duration) ([]string, error) {
+	rows, err := db.conn.QueryContext(ctx, `
+        DELETE FROM blobs
+        WHERE NOT complete AND created_at < CURRENT_TIMESTAMP - $1 * INTERVAL '1 second'
+        RETURNING id
+    `, int64(age.Seconds()))
+	if err != nil {
+		slog.Error("Failed to purge stale blobs",
+			"error", err,
+			"method", "PurgeStaleBlobs")
+		return nil, fmt.Errorf("failed to purge stale blobs: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err = rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
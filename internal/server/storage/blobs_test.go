@@ -0,0 +1,147 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"testing"
+	"time"
+
+	"GophKeeper.ru/internal/entities"
+)
+
+// createBlob начинает загрузку из одного фрагмента
+func createBlob(t *testing.T, db *Database, userID int, id, record string, size int64) {
+	t.Helper()
+
+	b := entities.Blob{ID: id, Size: size, Chunks: 1, Record: record}
+	if err := db.CreateBlob(context.Background(), userID, &b, entities.BlobQuota{}); err != nil {
+		t.Fatalf("create blob %s: %v", id, err)
+	}
+}
+
+// completeBlob отмечает единственный фрагмент полученным и завершает загрузку
+func completeBlob(t *testing.T, db *Database, userID int, id string, size int64) {
+	t.Helper()
+
+	ctx := context.Background()
+	if err := db.PutChunk(ctx, userID, id, 0, size, func() error { return nil }); err != nil {
+		t.Fatalf("put chunk of %s: %v", id, err)
+	}
+	if err := db.CompleteBlob(ctx, userID, id); err != nil {
+		t.Fatalf("complete blob %s: %v", id, err)
+	}
+}
+
+// ageBlob сдвигает время создания загрузки в прошлое
+func ageBlob(t *testing.T, db *Database, id string, age time.Duration) {
+	t.Helper()
+
+	_, err := db.conn.Exec("UPDATE blobs SET created_at = created_at - $2 * INTERVAL '1 second' WHERE id = $1",
+		id, int64(age.Seconds()))
+	if err != nil {
+		t.Fatalf("age blob %s: %v", id, err)
+	}
+}
+
+func TestCreateBlobEnforcesQuota(t *testing.T) {
+	db := newTestDatabase(t)
+	ctx := context.Background()
+	userID := newTestUser(t, db, "alice")
+	quota := entities.BlobQuota{MaxBytes: 100, MaxOpen: 2}
+
+	steps := []struct {
+		name string
+		id   string
+		size int64
+		err  error
+	}{
+		{name: "fits", id: "b1", size: 60},
+		{name: "over bytes", id: "b2", size: 50, err: entities.ErrBlobQuota},
+		{name: "fills quota", id: "b3", size: 40},
+		{name: "too many open", id: "b4", size: 0, err: entities.ErrTooManyUploads},
+	}
+	for _, step := range steps {
+		b := entities.Blob{ID: step.id, Size: step.size, Chunks: 1}
+		err := db.CreateBlob(ctx, userID, &b, quota)
+		if step.err == nil && err != nil || step.err != nil && !errors.Is(err, step.err) {
+			t.Fatalf("%s: %v, want %v", step.name, err, step.err)
+		}
+	}
+
+	// Завершённая загрузка не занимает место среди открытых, но её байты считаются
+	completeBlob(t, db, userID, "b1", 60)
+	b := entities.Blob{ID: "b5", Size: 1, Chunks: 1}
+	if err := db.CreateBlob(ctx, userID, &b, quota); !errors.Is(err, entities.ErrBlobQuota) {
+		t.Fatalf("over bytes after complete: %v, want ErrBlobQuota", err)
+	}
+
+	// Квота у каждого пользователя своя
+	bob := newTestUser(t, db, "bob")
+	b = entities.Blob{ID: "b6", Size: 100, Chunks: 1}
+	if err := db.CreateBlob(ctx, bob, &b, quota); err != nil {
+		t.Fatalf("other user: %v", err)
+	}
+}
+
+func TestCollectBlobsRemovesUnlinkedUploads(t *testing.T) {
+	db := newTestDatabase(t)
+	ctx := context.Background()
+	userID := newTestUser(t, db, "alice")
+
+	for _, id := range []string{"orphan", "fresh", "linked", "legacy", "open"} {
+		createBlob(t, db, userID, id, "", 10)
+		if id != "open" {
+			completeBlob(t, db, userID, id, 10)
+		}
+	}
+	putRecord(t, db, userID, entities.Record{Key: "file", Value: "v", Blob: "linked"})
+	if _, err := db.conn.Exec("UPDATE blobs SET legacy = TRUE WHERE id = 'legacy'"); err != nil {
+		t.Fatalf("mark legacy: %v", err)
+	}
+	for _, id := range []string{"orphan", "linked", "legacy", "open"} {
+		ageBlob(t, db, id, 2*time.Hour)
+	}
+
+	ids, err := db.CollectBlobs(ctx, time.Hour)
+	if err != nil {
+		t.Fatalf("collect blobs: %v", err)
+	}
+	if len(ids) != 1 || ids[0] != "orphan" {
+		t.Fatalf("collected %v, want [orphan]", ids)
+	}
+
+	for _, id := range []string{"fresh", "linked", "legacy", "open"} {
+		if _, err = db.Blob(ctx, userID, id); err != nil {
+			t.Errorf("blob %s after collect: %v", id, err)
+		}
+	}
+}
+
+func TestDeleteUserReturnsBlobs(t *testing.T) {
+	db := newTestDatabase(t)
+	ctx := context.Background()
+	userID := newTestUser(t, db, "alice")
+	other := newTestUser(t, db, "bob")
+
+	createBlob(t, db, userID, "a1", "", 10)
+	createBlob(t, db, userID, "a2", "file", 10)
+	createBlob(t, db, other, "b1", "", 10)
+	putRecord(t, db, userID, entities.Record{Key: "file", Value: "v", Blob: "a2"})
+
+	ids, err := db.DeleteUser(ctx, userID)
+	if err != nil {
+		t.Fatalf("delete user: %v", err)
+	}
+	sort.Strings(ids)
+	if len(ids) != 2 || ids[0] != "a1" || ids[1] != "a2" {
+		t.Fatalf("deleted blobs %v, want [a1 a2]", ids)
+	}
+
+	if _, err = db.Blob(ctx, other, "b1"); err != nil {
+		t.Fatalf("other user's blob: %v", err)
+	}
+	if _, err = db.DeleteUser(ctx, userID); !errors.Is(err, entities.ErrUserNotFound) {
+		t.Fatalf("delete again: %v, want ErrUserNotFound", err)
+	}
+}
@@ -3,11 +3,15 @@ package storage
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"time"
 
 	"log/slog"
 
 	"GophKeeper.ru/internal/entities"
+	"GophKeeper.ru/internal/server/keyring"
+	"github.com/jackc/pgx/v5/pgconn"
 
 	_ "github.com/jackc/pgx/v5/stdlib"
 )
@@ -16,6 +20,9 @@ import (
 type Database struct {
 	addr string
 	conn *sql.DB
+	keys *keyring.Keyring // Ключи шифрования data.value, nil — без шифрования
+
+	historyVersions int // Сколько прежних версий записи хранить
 }
 
 // New создаёт новый экземпляр Database и открывает соединение.
@@ -64,12 +71,28 @@ func (db *Database) Stop() error {
 	return nil
 }
 
+// lockColumns блокировка входа: момент окончания и остаток в секундах по часам БД
+const lockColumns = `locked_until,
+    GREATEST(CEIL(EXTRACT(EPOCH FROM locked_until - CURRENT_TIMESTAMP)), 0)::BIGINT`
+
+// scanLock переносит результат lockColumns в пользователя
+func scanLock(user *entities.User, until sql.NullTime, seconds sql.NullInt64) {
+	if until.Valid {
+		user.LockedUntil = &until.Time
+	}
+	user.LockedFor = time.Duration(seconds.Int64) * time.Second
+}
+
 // User возвращает пользователя по его логину.
 func (db *Database) User(ctx context.Context, login string) (*entities.User, error) {
-	var user entities.User
+	var (
+		user    entities.User
+		until   sql.NullTime
+		seconds sql.NullInt64
+	)
 	err := db.conn.QueryRowContext(ctx,
-		"SELECT id, name, password FROM users WHERE name = $1", login).
-		Scan(&user.ID, &user.Login, &user.Password)
+		"SELECT id, name, password, is_disable, is_admin, "+lockColumns+" FROM users WHERE name = $1", login).
+		Scan(&user.ID, &user.Login, &user.Password, &user.IsDisable, &user.IsAdmin, &until, &seconds)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -80,16 +103,21 @@ func (db *Database) User(ctx context.Context, login string) (*entities.User, err
 			"method", "User")
 		return nil, err
 	}
+	scanLock(&user, until, seconds)
 
 	return &user, nil
 }
 
 // UserFromID возвращает пользователя по его ID.
 func (db *Database) UserFromID(ctx context.Context, id int) (*entities.User, error) {
-	var user entities.User
+	var (
+		user    entities.User
+		until   sql.NullTime
+		seconds sql.NullInt64
+	)
 	err := db.conn.QueryRowContext(ctx,
-		"SELECT id, name, password, is_disable FROM users WHERE id = $1", id).
-		Scan(&user.ID, &user.Login, &user.Password, &user.IsDisable)
+		"SELECT id, name, password, is_disable, is_admin, "+lockColumns+" FROM users WHERE id = $1", id).
+		Scan(&user.ID, &user.Login, &user.Password, &user.IsDisable, &user.IsAdmin, &until, &seconds)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -100,14 +128,350 @@ func (db *Database) UserFromID(ctx context.Context, id int) (*entities.User, err
 			"method", "UserFromID")
 		return nil, err
 	}
+	scanLock(&user, until, seconds)
+
+	return &user, nil
+}
+
+// UserFromCertIdentity возвращает пользователя, привязанного к сертификату.
+func (db *Database) UserFromCertIdentity(ctx context.Context, identity string) (*entities.User, error) {
+	var user entities.User
+	err := db.conn.QueryRowContext(ctx,
+		"SELECT id, name, is_disable, is_admin FROM users WHERE cert_identity = $1", identity).
+		Scan(&user.ID, &user.Login, &user.IsDisable, &user.IsAdmin)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		slog.Error("Failed to fetch user by certificate",
+			"identity", identity,
+			"error", err,
+			"method", "UserFromCertIdentity")
+		return nil, err
+	}
+	user.CertID = identity
 
 	return &user, nil
 }
 
-// GetData возвращает все данные пользователя по его ID.
+// CreateUser регистрирует нового пользователя и заполняет его ID.
+// Пользователь создаётся без роли администратора, её выдаёт только SetAdmin.
+// Если логин уже занят, возвращает entities.ErrUserExists.
+func (db *Database) CreateUser(ctx context.Context, user *entities.User) error {
+	err := db.conn.QueryRowContext(ctx, `
+        INSERT INTO users (name, password, is_admin)
+        VALUES ($1, $2, FALSE)
+        ON CONFLICT (name) DO NOTHING
+        RETURNING id
+    `, user.Login, user.Password).Scan(&user.ID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return entities.ErrUserExists
+		}
+		slog.Error("Failed to create user",
+			"login", user.Login,
+			"error", err,
+			"method", "CreateUser")
+		return fmt.Errorf("failed to create user: %w", err)
+	}
+
+	return nil
+}
+
+// UpdatePassword сохраняет новый хэш пароля пользователя.
+func (db *Database) UpdatePassword(ctx context.Context, id int, hash string) error {
+	_, err := db.conn.ExecContext(ctx,
+		"UPDATE users SET password = $1 WHERE id = $2", hash, id)
+	if err != nil {
+		slog.Error("Failed to update password",
+			"user_id", id,
+			"error", err,
+			"method", "UpdatePassword")
+		return fmt.Errorf("failed to update password: %w", err)
+	}
+
+	return nil
+}
+
+// LockUser блокирует вход пользователя на время d.
+func (db *Database) LockUser(ctx context.Context, id int, d time.Duration) error {
+	_, err := db.conn.ExecContext(ctx, `
+        UPDATE users SET locked_until = CURRENT_TIMESTAMP + $1 * INTERVAL '1 second'
+        WHERE id = $2
+    `, int64(d.Seconds()), id)
+	if err != nil {
+		slog.Error("Failed to lock user",
+			"user_id", id,
+			"error", err,
+			"method", "LockUser")
+		return fmt.Errorf("failed to lock user: %w", err)
+	}
+
+	return nil
+}
+
+// CreateRefreshToken сохраняет хэш выданного refresh-токена.
+func (db *Database) CreateRefreshToken(ctx context.Context, t *entities.RefreshToken) error {
+	_, err := db.conn.ExecContext(ctx, `
+        INSERT INTO refresh_tokens (user_id, family_id, token_hash, expires_at)
+        VALUES ($1, $2, $3, CURRENT_TIMESTAMP + $4 * INTERVAL '1 second')
+    `, t.UserID, t.FamilyID, t.Hash, int64(t.TTL.Seconds()))
+	if err != nil {
+		slog.Error("Failed to create refresh token",
+			"user_id", t.UserID,
+			"error", err,
+			"method", "CreateRefreshToken")
+		return fmt.Errorf("failed to create refresh token: %w", err)
+	}
+
+	return nil
+}
+
+// RotateRefreshToken обменивает refresh-токен на следующий в том же семействе.
+// Повторное использование токена отзывает всё семейство.
+func (db *Database) RotateRefreshToken(ctx context.Context, hash string, next *entities.RefreshToken) error {
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		slog.Error("Failed to begin transaction",
+			"error", err,
+			"method", "RotateRefreshToken")
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var (
+		userID   int
+		familyID string
+		used     bool
+		invalid  bool
+	)
+	err = tx.QueryRowContext(ctx, `
+        SELECT user_id, family_id, used_at IS NOT NULL,
+               revoked_at IS NOT NULL OR expires_at < CURRENT_TIMESTAMP
+        FROM refresh_tokens
+        WHERE token_hash = $1
+        FOR UPDATE
+    `, hash).Scan(&userID, &familyID, &used, &invalid)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return entities.ErrTokenNotFound
+		}
+		slog.Error("Failed to fetch refresh token",
+			"error", err,
+			"method", "RotateRefreshToken")
+		return fmt.Errorf("failed to fetch refresh token: %w", err)
+	}
+
+	if used {
+		if err = revokeSession(ctx, tx, familyID); err != nil {
+			return err
+		}
+		if err = tx.Commit(); err != nil {
+			return fmt.Errorf("failed to commit transaction: %w", err)
+		}
+		return entities.ErrTokenReused
+	}
+
+	if invalid {
+		return entities.ErrTokenExpired
+	}
+
+	_, err = tx.ExecContext(ctx, `
+        UPDATE refresh_tokens SET used_at = CURRENT_TIMESTAMP
+        WHERE token_hash = $1
+    `, hash)
+	if err != nil {
+		slog.Error("Failed to mark refresh token as used",
+			"error", err,
+			"method", "RotateRefreshToken")
+		return fmt.Errorf("failed to mark refresh token as used: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+        INSERT INTO refresh_tokens (user_id, family_id, token_hash, expires_at)
+        VALUES ($1, $2, $3, CURRENT_TIMESTAMP + $4 * INTERVAL '1 second')
+    `, userID, familyID, next.Hash, int64(next.TTL.Seconds()))
+	if err != nil {
+		slog.Error("Failed to create refresh token",
+			"user_id", userID,
+			"error", err,
+			"method", "RotateRefreshToken")
+		return fmt.Errorf("failed to create refresh token: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx,
+		"UPDATE sessions SET last_seen_at = CURRENT_TIMESTAMP WHERE id = $1", familyID)
+	if err != nil {
+		slog.Error("Failed to update session last_seen_at",
+			"session_id", familyID,
+			"error", err,
+			"method", "RotateRefreshToken")
+		return fmt.Errorf("failed to update session: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		slog.Error("Failed to commit transaction",
+			"error", err,
+			"method", "RotateRefreshToken")
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	next.UserID = userID
+	next.FamilyID = familyID
+	return nil
+}
+
+// execer общий интерфейс *sql.DB и *sql.Tx для запросов без результата
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// revokeSession помечает сессию завершённой и отзывает её refresh-токены
+func revokeSession(ctx context.Context, conn execer, id string) error {
+	_, err := conn.ExecContext(ctx, `
+        UPDATE sessions SET revoked_at = CURRENT_TIMESTAMP
+        WHERE id = $1 AND revoked_at IS NULL
+    `, id)
+	if err != nil {
+		slog.Error("Failed to revoke session",
+			"session_id", id,
+			"error", err,
+			"method", "revokeSession")
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+
+	_, err = conn.ExecContext(ctx, `
+        UPDATE refresh_tokens SET revoked_at = CURRENT_TIMESTAMP
+        WHERE family_id = $1 AND revoked_at IS NULL
+    `, id)
+	if err != nil {
+		slog.Error("Failed to revoke refresh token family",
+			"family_id", id,
+			"error", err,
+			"method", "revokeSession")
+		return fmt.Errorf("failed to revoke refresh token family: %w", err)
+	}
+
+	return nil
+}
+
+// CreateSession сохраняет новую сессию пользователя.
+func (db *Database) CreateSession(ctx context.Context, userID int, s *entities.Session) error {
+	_, err := db.conn.ExecContext(ctx, `
+        INSERT INTO sessions (id, user_id, user_agent, ip)
+        VALUES ($1, $2, $3, $4)
+    `, s.ID, userID, s.UserAgent, s.IP)
+	if err != nil {
+		slog.Error("Failed to create session",
+			"user_id", userID,
+			"error", err,
+			"method", "CreateSession")
+		return fmt.Errorf("failed to create session: %w", err)
+	}
+
+	return nil
+}
+
+// Sessions возвращает активные сессии пользователя.
+func (db *Database) Sessions(ctx context.Context, userID int) ([]entities.Session, error) {
+	rows, err := db.conn.QueryContext(ctx, `
+        SELECT id, user_agent, ip, created_at, last_seen_at
+        FROM sessions
+        WHERE user_id = $1 AND revoked_at IS NULL
+        ORDER BY last_seen_at DESC
+    `, userID)
+	if err != nil {
+		slog.Error("Failed to query sessions",
+			"user_id", userID,
+			"error", err,
+			"method", "Sessions")
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []entities.Session{}
+	for rows.Next() {
+		var s entities.Session
+		if err := rows.Scan(&s.ID, &s.UserAgent, &s.IP, &s.CreatedAt, &s.LastSeenAt); err != nil {
+			slog.Error("Failed to scan row in Sessions",
+				"error", err,
+				"method", "Sessions")
+			return nil, err
+		}
+		out = append(out, s)
+	}
+
+	return out, rows.Err()
+}
+
+// SessionActive проверяет, что сессия существует и не отозвана.
+func (db *Database) SessionActive(ctx context.Context, id string) (bool, error) {
+	var active bool
+	err := db.conn.QueryRowContext(ctx,
+		"SELECT revoked_at IS NULL FROM sessions WHERE id = $1", id).
+		Scan(&active)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		slog.Error("Failed to check session",
+			"session_id", id,
+			"error", err,
+			"method", "SessionActive")
+		return false, err
+	}
+
+	return active, nil
+}
+
+// RevokeSession завершает сессию пользователя и отзывает её refresh-токены.
+func (db *Database) RevokeSession(ctx context.Context, userID int, id string) error {
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		slog.Error("Failed to begin transaction",
+			"error", err,
+			"method", "RevokeSession")
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	err = tx.QueryRowContext(ctx,
+		"SELECT EXISTS(SELECT 1 FROM sessions WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL)",
+		id, userID).Scan(&exists)
+	if err != nil {
+		slog.Error("Failed to fetch session",
+			"session_id", id,
+			"error", err,
+			"method", "RevokeSession")
+		return fmt.Errorf("failed to fetch session: %w", err)
+	}
+
+	if !exists {
+		return entities.ErrSessionNotFound
+	}
+
+	if err = revokeSession(ctx, tx, id); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		slog.Error("Failed to commit transaction",
+			"error", err,
+			"method", "RevokeSession")
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// GetData возвращает все данные пользователя по его ID, кроме удалённых в корзину.
 func (db *Database) GetData(ctx context.Context, id int) (*entities.Update, error) {
 	out := entities.NewUpdate()
-	rows, err := db.conn.QueryContext(ctx, "SELECT name, value FROM data WHERE user_id = $1", id)
+	rows, err := db.conn.QueryContext(ctx, `
+        SELECT name, value, value_kid, wrapped_key, blind_index, COALESCE(blob_id, '') FROM data
+        WHERE user_id = $1 AND deleted_at IS NULL
+    `, id)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return out, nil
@@ -121,20 +485,65 @@ func (db *Database) GetData(ctx context.Context, id int) (*entities.Update, erro
 	defer rows.Close()
 
 	for rows.Next() {
-		var r entities.Record
-		err := rows.Scan(&r.Key, &r.Value)
+		var (
+			r     entities.Record
+			kid   sql.NullString
+			index sql.NullString
+		)
+		err := rows.Scan(&r.Key, &r.Value, &kid, &r.WrappedKey, &index, &r.Blob)
 		if err != nil {
 			slog.Error("Failed to scan row in GetData",
 				"error", err,
 				"method", "GetData")
 			return nil, err
 		}
+
+		if r.Value, err = db.openValue(id, r.Key, kid, r.Value); err != nil {
+			slog.Error("Failed to decrypt value in GetData",
+				"user_id", id,
+				"kid", kid.String,
+				"error", err,
+				"method", "GetData")
+			return nil, err
+		}
+		r.Index = index.String
 		out.Data = append(out.Data, r)
 	}
 
 	return out, nil
 }
 
+// Record возвращает запись пользователя по слепому индексу имени.
+func (db *Database) Record(ctx context.Context, userID int, index string) (*entities.Record, error) {
+	var kid sql.NullString
+	r := entities.Record{Index: index}
+	err := db.conn.QueryRowContext(ctx, `
+        SELECT name, value, value_kid, wrapped_key FROM data
+        WHERE user_id = $1 AND blind_index = $2 AND deleted_at IS NULL
+    `, userID, index).Scan(&r.Key, &r.Value, &kid, &r.WrappedKey)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, entities.ErrRecordNotFound
+	}
+	if err != nil {
+		slog.Error("Failed to query record",
+			"user_id", userID,
+			"error", err,
+			"method", "Record")
+		return nil, err
+	}
+
+	if r.Value, err = db.openValue(userID, r.Key, kid, r.Value); err != nil {
+		slog.Error("Failed to decrypt value",
+			"user_id", userID,
+			"kid", kid.String,
+			"error", err,
+			"method", "Record")
+		return nil, err
+	}
+
+	return &r, nil
+}
+
 // GetCountUpdate возвращает текущий номер обновления пользователя.
 func (db *Database) GetCountUpdate(ctx context.Context, userID int) (int, error) {
 	var countUpdate int
@@ -153,7 +562,8 @@ func (db *Database) GetCountUpdate(ctx context.Context, userID int) (int, error)
 	return countUpdate, nil
 }
 
-// UpdateRecord добавляет или обновляет запись пользователя в БД.
+// UpdateRecord добавляет или обновляет запись пользователя в БД,
+// сохраняя прежнее значение в истории. Запись из корзины возвращается.
 func (db *Database) UpdateRecord(ctx context.Context, userID int, r entities.Record) error {
 	tx, err := db.conn.BeginTx(ctx, nil)
 	if err != nil {
@@ -164,14 +574,39 @@ func (db *Database) UpdateRecord(ctx context.Context, userID int, r entities.Rec
 	}
 	defer tx.Rollback()
 
+	if err = lockDataWrite(ctx, tx, userID); err != nil {
+		return err
+	}
+
+	kid, value, err := db.sealValue(userID, r.Key, r.Value)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt value: %w", err)
+	}
+
+	// Прежнее значение не теряется, а уходит в историю
+	if err = db.archiveRecord(ctx, tx, userID, r.Key); err != nil {
+		return err
+	}
+
 	_, err = tx.ExecContext(ctx, `
-        INSERT INTO data (user_id, name, value)
-        VALUES ($1, $2, $3)
+        INSERT INTO data (user_id, name, value, value_kid, wrapped_key, blind_index, blob_id)
+        VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), NULLIF($7, ''))
         ON CONFLICT (user_id, name) DO UPDATE SET
             value = EXCLUDED.value,
+            value_kid = EXCLUDED.value_kid,
+            wrapped_key = EXCLUDED.wrapped_key,
+            blind_index = EXCLUDED.blind_index,
+            blob_id = EXCLUDED.blob_id,
+            deleted_at = NULL,
+            deleted_index = NULL,
+            restored_from = NULL,
             updated_at = CURRENT_TIMESTAMP
-    `, userID, r.Key, r.Value)
+    `, userID, r.Key, value, kid, r.WrappedKey, r.Index, r.Blob)
 
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == pgUniqueViolation {
+		return entities.ErrRecordExists
+	}
 	if err != nil {
 		slog.Error("Failed to update record",
 			"user_id", userID,
@@ -181,6 +616,14 @@ func (db *Database) UpdateRecord(ctx context.Context, userID int, r entities.Rec
 		return fmt.Errorf("failed to update: %w", err)
 	}
 
+	// Ссылка на данные известна, без ссылок их удалит сборщик
+	if r.Blob != "" {
+		_, err = tx.ExecContext(ctx, "UPDATE blobs SET tracked = TRUE WHERE id = $1 AND user_id = $2", r.Blob, userID)
+		if err != nil {
+			return fmt.Errorf("failed to track blob: %w", err)
+		}
+	}
+
 	_, err = tx.ExecContext(ctx, `
         UPDATE users
         SET update_id = update_id + 1
@@ -205,7 +648,8 @@ func (db *Database) UpdateRecord(ctx context.Context, userID int, r entities.Rec
 	return nil
 }
 
-// RemoveRecord удаляет запись пользователя из БД.
+// RemoveRecord переносит запись пользователя в корзину. Слепой индекс
+// освобождается до возврата записи, история хранится до окончательного удаления.
 func (db *Database) RemoveRecord(ctx context.Context, userID int, key string) error {
 	tx, err := db.conn.BeginTx(ctx, nil)
 	if err != nil {
@@ -216,9 +660,16 @@ func (db *Database) RemoveRecord(ctx context.Context, userID int, key string) er
 	}
 	defer tx.Rollback()
 
+	if err = lockDataWrite(ctx, tx, userID); err != nil {
+		return err
+	}
+
 	_, err = tx.ExecContext(ctx, `
-        DELETE FROM data
-        WHERE user_id = $1 AND name = $2
+        UPDATE data SET
+            deleted_at = CURRENT_TIMESTAMP,
+            deleted_index = blind_index,
+            blind_index = NULL
+        WHERE user_id = $1 AND name = $2 AND deleted_at IS NULL
     `, userID, key)
 
 	if err != nil {
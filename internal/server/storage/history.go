@@ -0,0 +1,212 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"GophKeeper.ru/internal/entities"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// defaultHistoryVersions сколько прежних версий записи хранится по умолчанию
+const defaultHistoryVersions = 10
+
+// SetHistoryVersions задаёт, сколько прежних версий каждой записи хранить.
+func (db *Database) SetHistoryVersions(n int) {
+	db.historyVersions = n
+}
+
+// archiveRecord переносит текущее значение записи в историю и удаляет
+// версии сверх лимита. Записи может ещё не быть — тогда ничего не делает.
+// Двоичные данные удалённых версий удаляет CollectBlobs.
+func (db *Database) archiveRecord(ctx context.Context, tx *sql.Tx, userID int, name string) error {
+	_, err := tx.ExecContext(ctx, `
+        INSERT INTO data_history (user_id, name, value, value_kid, wrapped_key, blob_id, restored_from)
+        SELECT user_id, name, value, value_kid, wrapped_key, blob_id, restored_from FROM data
+        WHERE user_id = $1 AND name = $2
+    `, userID, name)
+	if err != nil {
+		slog.Error("Failed to archive record",
+			"user_id", userID,
+			"key", name,
+			"error", err,
+			"method", "archiveRecord")
+		return fmt.Errorf("failed to archive record: %w", err)
+	}
+
+	limit := db.historyVersions
+	if limit <= 0 {
+		limit = defaultHistoryVersions
+	}
+
+	_, err = tx.ExecContext(ctx, `
+        DELETE FROM data_history
+        WHERE user_id = $1 AND name = $2 AND id NOT IN (
+            SELECT id FROM data_history
+            WHERE user_id = $1 AND name = $2
+            ORDER BY id DESC
+            LIMIT $3
+        )
+    `, userID, name, limit)
+	if err != nil {
+		return fmt.Errorf("failed to prune record history: %w", err)
+	}
+
+	return nil
+}
+
+// History возвращает прежние версии записи, новые первыми.
+func (db *Database) History(ctx context.Context, userID int, key string) ([]entities.Version, error) {
+	rows, err := db.conn.QueryContext(ctx, `
+        SELECT id, value, value_kid, wrapped_key, COALESCE(restored_from, 0), created_at FROM data_history
+        WHERE user_id = $1 AND name = $2
+        ORDER BY id DESC
+    `, userID, key)
+	if err != nil {
+		slog.Error("Failed to query record history",
+			"user_id", userID,
+			"key", key,
+			"error", err,
+			"method", "History")
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []entities.Version{}
+	for rows.Next() {
+		var (
+			v   entities.Version
+			kid sql.NullString
+		)
+		if err = rows.Scan(&v.ID, &v.Value, &kid, &v.WrappedKey, &v.RestoredFrom, &v.CreatedAt); err != nil {
+			return nil, err
+		}
+
+		if v.Value, err = db.openValue(userID, key, kid, v.Value); err != nil {
+			slog.Error("Failed to decrypt history value",
+				"user_id", userID,
+				"version", v.ID,
+				"kid", kid.String,
+				"error", err,
+				"method", "History")
+			return nil, err
+		}
+		out = append(out, v)
+	}
+
+	return out, rows.Err()
+}
+
+// RestoreRecord делает прежнюю версию текущей. Значение присылает клиент:
+// он расшифровывает версию и шифрует её заново текущим ключом данных, сервер
+// проверить расшифровку не может. Текущее значение уходит в историю, у
+// нового отмечается, из какой версии оно восстановлено.
+func (db *Database) RestoreRecord(ctx context.Context, userID int, version int, r entities.Record) error {
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		slog.Error("Failed to begin transaction",
+			"error", err,
+			"method", "RestoreRecord")
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err = lockDataWrite(ctx, tx, userID); err != nil {
+		return err
+	}
+
+	var exists bool
+	err = tx.QueryRowContext(ctx,
+		"SELECT EXISTS (SELECT 1 FROM data WHERE user_id = $1 AND name = $2 AND deleted_at IS NULL)", userID, r.Key).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return entities.ErrRecordNotFound
+	}
+
+	err = tx.QueryRowContext(ctx,
+		"SELECT EXISTS (SELECT 1 FROM data_history WHERE id = $1 AND user_id = $2 AND name = $3)", version, userID, r.Key).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return entities.ErrVersionNotFound
+	}
+
+	kid, value, err := db.sealValue(userID, r.Key, r.Value)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt value: %w", err)
+	}
+
+	if err = db.archiveRecord(ctx, tx, userID, r.Key); err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+        UPDATE data SET
+            value = $3,
+            value_kid = $4,
+            wrapped_key = $5,
+            blind_index = NULLIF($6, ''),
+            blob_id = NULLIF($7, ''),
+            restored_from = $8,
+            updated_at = CURRENT_TIMESTAMP
+        WHERE user_id = $1 AND name = $2
+    `, userID, r.Key, value, kid, r.WrappedKey, r.Index, r.Blob, version)
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == pgUniqueViolation {
+		return entities.ErrRecordExists
+	}
+	if err != nil {
+		slog.Error("Failed to restore record",
+			"user_id", userID,
+			"key", r.Key,
+			"version", version,
+			"error", err,
+			"method", "RestoreRecord")
+		return fmt.Errorf("failed to restore record: %w", err)
+	}
+
+	if r.Blob != "" {
+		_, err = tx.ExecContext(ctx, "UPDATE blobs SET tracked = TRUE WHERE id = $1 AND user_id = $2", r.Blob, userID)
+		if err != nil {
+			return fmt.Errorf("failed to track blob: %w", err)
+		}
+	}
+
+	if _, err = tx.ExecContext(ctx, "UPDATE users SET update_id = update_id + 1 WHERE id = $1", userID); err != nil {
+		return fmt.Errorf("failed to update user's update_id: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		slog.Error("Failed to commit transaction",
+			"error", err,
+			"method", "RestoreRecord")
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// PruneHistory удаляет версии старше age. Возвращает число удалённых.
+// Двоичные данные удалённых версий удаляет CollectBlobs.
+func (db *Database) PruneHistory(ctx context.Context, age time.Duration) (int64, error) {
+	res, err := db.conn.ExecContext(ctx, `
+        DELETE FROM data_history
+        WHERE created_at < CURRENT_TIMESTAMP - $1 * INTERVAL '1 second'
+    `, int64(age.Seconds()))
+	if err != nil {
+		slog.Error("Failed to prune history",
+			"error", err,
+			"method", "PruneHistory")
+		return 0, fmt.Errorf("failed to prune history: %w", err)
+	}
+
+	return res.RowsAffected()
+}
@@ -0,0 +1,98 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"GophKeeper.ru/internal/entities"
+)
+
+func TestArchiveRecordKeepsLastVersions(t *testing.T) {
+	db := newTestDatabase(t)
+	db.SetHistoryVersions(3)
+	ctx := context.Background()
+	userID := newTestUser(t, db, "alice")
+
+	for i := 0; i < 6; i++ {
+		putRecord(t, db, userID, entities.Record{Key: "a", Value: fmt.Sprintf("v%d", i)})
+	}
+	putRecord(t, db, userID, entities.Record{Key: "b", Value: "b0"})
+
+	versions, err := db.History(ctx, userID, "a")
+	if err != nil {
+		t.Fatalf("history: %v", err)
+	}
+
+	want := []string{"v4", "v3", "v2"}
+	if len(versions) != len(want) {
+		t.Fatalf("got %d versions, want %d", len(versions), len(want))
+	}
+	for i, v := range versions {
+		if v.Value != want[i] {
+			t.Errorf("version %d: %q, want %q", i, v.Value, want[i])
+		}
+	}
+
+	// Новая запись истории не заводит, у чужих записей своя
+	if versions, err = db.History(ctx, userID, "b"); err != nil || len(versions) != 0 {
+		t.Fatalf("history of b: %+v, %v", versions, err)
+	}
+}
+
+func TestRestoreRecordMarksSourceVersion(t *testing.T) {
+	db := newTestDatabase(t)
+	ctx := context.Background()
+	userID := newTestUser(t, db, "alice")
+
+	putRecord(t, db, userID, entities.Record{Key: "a", Value: "v0", WrappedKey: "k"})
+	putRecord(t, db, userID, entities.Record{Key: "a", Value: "v1", WrappedKey: "k"})
+
+	versions, err := db.History(ctx, userID, "a")
+	if err != nil || len(versions) != 1 {
+		t.Fatalf("history: %+v, %v", versions, err)
+	}
+	source := versions[0].ID
+
+	err = db.RestoreRecord(ctx, userID, source, entities.Record{Key: "a", Value: "v0*", WrappedKey: "k"})
+	if err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	if got := currentValues(t, db, userID)["a"]; got.Value != "v0*" {
+		t.Fatalf("current value %q, want v0*", got.Value)
+	}
+
+	// Восстановленное значение уходит в историю с отметкой источника
+	putRecord(t, db, userID, entities.Record{Key: "a", Value: "v2", WrappedKey: "k"})
+	if versions, err = db.History(ctx, userID, "a"); err != nil || len(versions) != 3 {
+		t.Fatalf("history after restore: %+v, %v", versions, err)
+	}
+	if versions[0].Value != "v0*" || versions[0].RestoredFrom != source {
+		t.Fatalf("restored version %+v, want restored from %d", versions[0], source)
+	}
+	if versions[1].Value != "v1" || versions[1].RestoredFrom != 0 {
+		t.Fatalf("replaced version %+v", versions[1])
+	}
+
+	putRecord(t, db, userID, entities.Record{Key: "b", Value: "b0"})
+	tests := []struct {
+		name    string
+		version int
+		key     string
+		err     error
+	}{
+		{name: "unknown version", version: source + 100, key: "a", err: entities.ErrVersionNotFound},
+		{name: "version of other record", version: source, key: "b", err: entities.ErrVersionNotFound},
+		{name: "no record", version: source, key: "c", err: entities.ErrRecordNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := db.RestoreRecord(ctx, userID, tt.version, entities.Record{Key: tt.key, Value: "x"})
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("restore: %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,11 @@
+CREATE TABLE sessions (
+    id           TEXT PRIMARY KEY,
+    user_id      INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    user_agent   TEXT NOT NULL DEFAULT '',
+    ip           TEXT NOT NULL DEFAULT '',
+    created_at   TIMESTAMP(3) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_seen_at TIMESTAMP(3) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    revoked_at   TIMESTAMP(3)
+);
+
+CREATE INDEX idx_sessions_user ON sessions(user_id);
@@ -0,0 +1 @@
+ALTER TABLE users DROP COLUMN locked_until;
\ No newline at end of file
@@ -0,0 +1,3 @@
+DROP INDEX idx_users_cert_identity;
+
+ALTER TABLE users DROP COLUMN cert_identity;
@@ -0,0 +1,3 @@
+ALTER TABLE users ADD COLUMN cert_identity TEXT;
+
+CREATE UNIQUE INDEX idx_users_cert_identity ON users(cert_identity);
@@ -0,0 +1,11 @@
+CREATE TABLE vaults (
+    user_id      INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    kdf_version  INTEGER NOT NULL,
+    kdf_salt     TEXT NOT NULL,
+    kdf_time     INTEGER NOT NULL,
+    kdf_memory   INTEGER NOT NULL,
+    kdf_threads  SMALLINT NOT NULL,
+    wrapped_key  TEXT NOT NULL,
+    key_id       TEXT NOT NULL,
+    updated_at   TIMESTAMP(3) NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
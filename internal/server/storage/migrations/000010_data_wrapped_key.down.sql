@@ -0,0 +1 @@
+ALTER TABLE data DROP COLUMN wrapped_key;
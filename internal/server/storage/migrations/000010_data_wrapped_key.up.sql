@@ -0,0 +1 @@
+ALTER TABLE data ADD COLUMN wrapped_key TEXT NOT NULL DEFAULT '';
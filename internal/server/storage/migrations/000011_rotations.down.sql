@@ -0,0 +1,2 @@
+DROP TABLE rotation_records;
+DROP TABLE rotations;
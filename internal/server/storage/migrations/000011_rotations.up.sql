@@ -0,0 +1,19 @@
+CREATE TABLE rotations (
+    user_id      INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    kdf_version  INTEGER NOT NULL,
+    kdf_salt     TEXT NOT NULL,
+    kdf_time     INTEGER NOT NULL,
+    kdf_memory   INTEGER NOT NULL,
+    kdf_threads  SMALLINT NOT NULL,
+    wrapped_key  TEXT NOT NULL,
+    key_id       TEXT NOT NULL,
+    started_at   TIMESTAMP(3) NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE rotation_records (
+    user_id      INTEGER NOT NULL REFERENCES rotations(user_id) ON DELETE CASCADE,
+    name         TEXT NOT NULL,
+    value        TEXT NOT NULL,
+    wrapped_key  TEXT NOT NULL,
+    PRIMARY KEY (user_id, name)
+);
@@ -0,0 +1,4 @@
+DROP INDEX idx_user_data_blind_index;
+
+ALTER TABLE rotation_records DROP COLUMN blind_index;
+ALTER TABLE data DROP COLUMN blind_index;
@@ -0,0 +1,4 @@
+ALTER TABLE data ADD COLUMN blind_index TEXT;
+ALTER TABLE rotation_records ADD COLUMN blind_index TEXT NOT NULL DEFAULT '';
+
+CREATE UNIQUE INDEX idx_user_data_blind_index ON data(user_id, blind_index);
@@ -0,0 +1,4 @@
+DROP INDEX idx_data_value_kid;
+
+ALTER TABLE rotation_records DROP COLUMN value_kid;
+ALTER TABLE data DROP COLUMN value_kid;
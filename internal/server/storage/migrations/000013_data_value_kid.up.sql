@@ -0,0 +1,4 @@
+ALTER TABLE data ADD COLUMN value_kid TEXT;
+ALTER TABLE rotation_records ADD COLUMN value_kid TEXT;
+
+CREATE INDEX idx_data_value_kid ON data(value_kid);
@@ -0,0 +1 @@
+ALTER TABLE vaults DROP COLUMN recovery_key;
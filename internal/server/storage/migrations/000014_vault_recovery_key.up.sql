@@ -0,0 +1 @@
+ALTER TABLE vaults ADD COLUMN recovery_key TEXT;
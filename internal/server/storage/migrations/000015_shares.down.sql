@@ -0,0 +1,5 @@
+DROP TABLE shares;
+
+ALTER TABLE rotations DROP COLUMN private_key;
+ALTER TABLE vaults DROP COLUMN private_key;
+ALTER TABLE vaults DROP COLUMN public_key;
@@ -0,0 +1,16 @@
+ALTER TABLE vaults ADD COLUMN public_key TEXT;
+ALTER TABLE vaults ADD COLUMN private_key TEXT;
+ALTER TABLE rotations ADD COLUMN private_key TEXT;
+
+CREATE TABLE shares (
+    id           SERIAL PRIMARY KEY,
+    owner_id     INTEGER NOT NULL,
+    record_name  TEXT NOT NULL,
+    recipient_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    wrapped_key  TEXT NOT NULL,
+    created_at   TIMESTAMP(3) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (owner_id, record_name) REFERENCES data(user_id, name) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX idx_shares_record_recipient ON shares(owner_id, record_name, recipient_id);
+CREATE INDEX idx_shares_recipient ON shares(recipient_id);
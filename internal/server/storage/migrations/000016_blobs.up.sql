@@ -0,0 +1,17 @@
+CREATE TABLE blobs (
+    id          TEXT PRIMARY KEY,
+    user_id     INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    size        BIGINT NOT NULL,
+    chunks      INTEGER NOT NULL,
+    complete    BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at  TIMESTAMP(3) NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_blobs_user ON blobs(user_id);
+
+CREATE TABLE blob_chunks (
+    blob_id     TEXT NOT NULL REFERENCES blobs(id) ON DELETE CASCADE,
+    n           INTEGER NOT NULL,
+    size        BIGINT NOT NULL,
+    PRIMARY KEY (blob_id, n)
+);
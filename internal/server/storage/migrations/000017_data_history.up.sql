@@ -0,0 +1,12 @@
+CREATE TABLE data_history (
+    id           SERIAL PRIMARY KEY,
+    user_id      INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name         TEXT NOT NULL,
+    value        TEXT NOT NULL,
+    value_kid    TEXT,
+    wrapped_key  TEXT NOT NULL DEFAULT '',
+    created_at   TIMESTAMP(3) NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_data_history_record ON data_history(user_id, name, id);
+CREATE INDEX idx_data_history_created ON data_history(created_at);
@@ -0,0 +1,8 @@
+DROP INDEX idx_blobs_record;
+ALTER TABLE blobs DROP COLUMN record;
+
+DELETE FROM data WHERE deleted_at IS NOT NULL;
+
+DROP INDEX idx_data_deleted;
+ALTER TABLE data DROP COLUMN deleted_index;
+ALTER TABLE data DROP COLUMN deleted_at;
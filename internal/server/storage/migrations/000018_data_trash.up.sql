@@ -0,0 +1,8 @@
+ALTER TABLE data ADD COLUMN deleted_at TIMESTAMP(3);
+ALTER TABLE data ADD COLUMN deleted_index TEXT;
+
+CREATE INDEX idx_data_deleted ON data(deleted_at) WHERE deleted_at IS NOT NULL;
+
+ALTER TABLE blobs ADD COLUMN record TEXT;
+
+CREATE INDEX idx_blobs_record ON blobs(user_id, record);
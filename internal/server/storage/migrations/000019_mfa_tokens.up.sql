@@ -0,0 +1,6 @@
+CREATE TABLE used_mfa_tokens (
+    id          TEXT PRIMARY KEY,
+    expires_at  TIMESTAMP(3) NOT NULL
+);
+
+CREATE INDEX idx_used_mfa_tokens_expires ON used_mfa_tokens(expires_at);
@@ -0,0 +1,2 @@
+UPDATE users SET cert_identity = substring(cert_identity FROM position(':' IN cert_identity) + 1)
+WHERE cert_identity IS NOT NULL;
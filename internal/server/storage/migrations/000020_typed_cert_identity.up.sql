@@ -0,0 +1,8 @@
+-- Привязки без типа получают тип по виду значения
+UPDATE users SET cert_identity = CASE
+    WHEN cert_identity LIKE '%://%' THEN 'uri:' || cert_identity
+    WHEN cert_identity LIKE '%=%' THEN 'subject:' || cert_identity
+    WHEN cert_identity LIKE '%@%' THEN 'email:' || cert_identity
+    ELSE 'cn:' || cert_identity
+END
+WHERE cert_identity IS NOT NULL;
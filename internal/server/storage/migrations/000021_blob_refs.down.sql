@@ -0,0 +1,6 @@
+DROP INDEX idx_data_history_blob;
+DROP INDEX idx_data_blob;
+
+ALTER TABLE blobs DROP COLUMN tracked;
+ALTER TABLE data_history DROP COLUMN blob_id;
+ALTER TABLE data DROP COLUMN blob_id;
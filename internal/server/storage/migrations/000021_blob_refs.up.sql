@@ -0,0 +1,9 @@
+ALTER TABLE data ADD COLUMN blob_id TEXT;
+ALTER TABLE data_history ADD COLUMN blob_id TEXT;
+
+-- tracked: ссылки на данные известны серверу, без ссылок их можно удалить.
+-- Загрузки прежних клиентов не отмечены и сборщиком не трогаются.
+ALTER TABLE blobs ADD COLUMN tracked BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE INDEX idx_data_blob ON data(user_id, blob_id) WHERE blob_id IS NOT NULL;
+CREATE INDEX idx_data_history_blob ON data_history(user_id, blob_id) WHERE blob_id IS NOT NULL;
@@ -0,0 +1 @@
+DROP INDEX idx_blobs_open;
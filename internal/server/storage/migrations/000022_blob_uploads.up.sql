@@ -0,0 +1,2 @@
+-- Незавершённые загрузки ищут по возрасту при очистке и считают для квоты
+CREATE INDEX idx_blobs_open ON blobs(created_at) WHERE NOT complete;
@@ -0,0 +1,3 @@
+-- legacy_tag: хранилище создано поверх записей прежнего -k, клиент ещё
+-- расшифровывает их старым ключом. Подпись ставит клиент ключом хранилища.
+ALTER TABLE vaults ADD COLUMN legacy_tag TEXT;
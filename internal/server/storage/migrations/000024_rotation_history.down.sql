@@ -0,0 +1 @@
+DROP TABLE rotation_history;
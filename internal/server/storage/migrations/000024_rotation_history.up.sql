@@ -0,0 +1,7 @@
+-- Ключи данных версий в истории, перешифрованные новым ключом хранилища
+CREATE TABLE rotation_history (
+    user_id      INTEGER NOT NULL REFERENCES rotations(user_id) ON DELETE CASCADE,
+    history_id   INTEGER NOT NULL REFERENCES data_history(id) ON DELETE CASCADE,
+    wrapped_key  TEXT NOT NULL,
+    PRIMARY KEY (user_id, history_id)
+);
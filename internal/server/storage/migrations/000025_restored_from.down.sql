@@ -0,0 +1,2 @@
+ALTER TABLE data_history DROP COLUMN restored_from;
+ALTER TABLE data DROP COLUMN restored_from;
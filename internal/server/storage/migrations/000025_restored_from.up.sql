@@ -0,0 +1,3 @@
+-- restored_from: версия истории, из которой клиент восстановил значение
+ALTER TABLE data ADD COLUMN restored_from INTEGER;
+ALTER TABLE data_history ADD COLUMN restored_from INTEGER;
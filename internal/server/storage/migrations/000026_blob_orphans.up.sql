@@ -0,0 +1,5 @@
+-- legacy: загрузки прежних клиентов, о ссылках на которые сервер ещё не
+-- знает. Остальные завершённые, но так и не связанные с записью загрузки
+-- (клиент упал между завершением и сохранением записи) удаляет сборщик.
+ALTER TABLE blobs ADD COLUMN legacy BOOLEAN NOT NULL DEFAULT FALSE;
+UPDATE blobs SET legacy = TRUE WHERE NOT tracked AND complete;
@@ -0,0 +1,411 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"GophKeeper.ru/internal/entities"
+)
+
+// lockDataWrite блокирует строку пользователя до конца транзакции и
+// проверяет, что смена ключа не идёт. Смена ключа берёт ту же блокировку,
+// поэтому запись данных и начало смены не пересекаются.
+func lockDataWrite(ctx context.Context, tx *sql.Tx, userID int) error {
+	var rotating bool
+	err := tx.QueryRowContext(ctx, `
+        SELECT EXISTS (SELECT 1 FROM rotations WHERE user_id = $1)
+        FROM users WHERE id = $1
+        FOR UPDATE
+    `, userID).Scan(&rotating)
+	if err != nil {
+		return fmt.Errorf("failed to lock user: %w", err)
+	}
+
+	if rotating {
+		return entities.ErrRotationInProgress
+	}
+
+	return nil
+}
+
+// Rotation возвращает незавершённую смену ключа пользователя.
+func (db *Database) Rotation(ctx context.Context, userID int) (*entities.Rotation, error) {
+	return rotation(ctx, db.conn, userID)
+}
+
+// querier общий интерфейс *sql.DB и *sql.Tx для чтения
+type querier interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// rotation читает смену ключа и ID уже перешифрованных записей
+func rotation(ctx context.Context, conn querier, userID int) (*entities.Rotation, error) {
+	var (
+		r       entities.Rotation
+		private sql.NullString
+	)
+	v := &r.Vault
+	err := conn.QueryRowContext(ctx, `
+        SELECT kdf_version, kdf_salt, kdf_time, kdf_memory, kdf_threads, wrapped_key, key_id, private_key
+        FROM rotations WHERE user_id = $1
+    `, userID).Scan(&v.KDF.Version, &v.KDF.Salt, &v.KDF.Time, &v.KDF.Memory, &v.KDF.Threads,
+		&v.WrappedKey, &v.KeyID, &private)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		slog.Error("Failed to fetch rotation",
+			"user_id", userID,
+			"error", err,
+			"method", "Rotation")
+		return nil, err
+	}
+	v.PrivateKey = private.String
+
+	rows, err := conn.QueryContext(ctx, "SELECT name FROM rotation_records WHERE user_id = $1", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	r.Done = []string{}
+	for rows.Next() {
+		var name string
+		if err = rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		r.Done = append(r.Done, name)
+	}
+
+	return &r, rows.Err()
+}
+
+// BeginRotation начинает смену ключа. Повторный вызов с тем же key_id
+// продолжает начатую смену, с другим — ErrRotationInProgress.
+func (db *Database) BeginRotation(ctx context.Context, userID int, v *entities.Vault) (*entities.Rotation, error) {
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		slog.Error("Failed to begin transaction",
+			"error", err,
+			"method", "BeginRotation")
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = lockDataWrite(ctx, tx, userID)
+	if errors.Is(err, entities.ErrRotationInProgress) {
+		current, err := rotation(ctx, tx, userID)
+		if err != nil {
+			return nil, err
+		}
+		if current.Vault.KeyID != v.KeyID {
+			return nil, entities.ErrRotationInProgress
+		}
+		return current, nil
+	}
+	if err != nil {
+		slog.Error("Failed to lock user",
+			"user_id", userID,
+			"error", err,
+			"method", "BeginRotation")
+		return nil, err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+        INSERT INTO rotations (user_id, kdf_version, kdf_salt, kdf_time, kdf_memory, kdf_threads,
+            wrapped_key, key_id, private_key)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NULLIF($9, ''))
+    `, userID, v.KDF.Version, v.KDF.Salt, v.KDF.Time, v.KDF.Memory, v.KDF.Threads,
+		v.WrappedKey, v.KeyID, v.PrivateKey)
+	if err != nil {
+		slog.Error("Failed to create rotation",
+			"user_id", userID,
+			"error", err,
+			"method", "BeginRotation")
+		return nil, fmt.Errorf("failed to create rotation: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		slog.Error("Failed to commit transaction",
+			"error", err,
+			"method", "BeginRotation")
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return &entities.Rotation{Vault: *v, Done: []string{}}, nil
+}
+
+// StageRotation сохраняет пачку перешифрованных записей. Записи, которых
+// нет в data, отклоняются с ErrRecordNotFound, повторная отправка перезаписывает прежнюю.
+func (db *Database) StageRotation(ctx context.Context, userID int, records []entities.Record) error {
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		slog.Error("Failed to begin transaction",
+			"error", err,
+			"method", "StageRotation")
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	err = tx.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM rotations WHERE user_id = $1)", userID).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return entities.ErrRotationNotFound
+	}
+
+	for _, r := range records {
+		kid, value, err := db.sealValue(userID, r.Key, r.Value)
+		if err != nil {
+			return fmt.Errorf("failed to encrypt value: %w", err)
+		}
+
+		res, err := tx.ExecContext(ctx, `
+            INSERT INTO rotation_records (user_id, name, value, value_kid, wrapped_key, blind_index)
+            SELECT $1, d.name, $3, $4, $5, $6
+            FROM data d
+            WHERE d.user_id = $1 AND d.name = $2
+            ON CONFLICT (user_id, name) DO UPDATE SET
+                value = EXCLUDED.value,
+                value_kid = EXCLUDED.value_kid,
+                wrapped_key = EXCLUDED.wrapped_key,
+                blind_index = EXCLUDED.blind_index
+        `, userID, r.Key, value, kid, r.WrappedKey, r.Index)
+		if err != nil {
+			slog.Error("Failed to stage record",
+				"user_id", userID,
+				"key", r.Key,
+				"error", err,
+				"method", "StageRotation")
+			return fmt.Errorf("failed to stage record: %w", err)
+		}
+
+		if n, _ := res.RowsAffected(); n == 0 {
+			return fmt.Errorf("%w: %s", entities.ErrRecordNotFound, r.Key)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		slog.Error("Failed to commit transaction",
+			"error", err,
+			"method", "StageRotation")
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// RotationHistory возвращает ключи данных версий в истории, которые ещё не
+// перешифрованы для идущей смены ключа. Версии старых форматов без ключа
+// данных не возвращаются.
+func (db *Database) RotationHistory(ctx context.Context, userID int) ([]entities.HistoryKey, error) {
+	rows, err := db.conn.QueryContext(ctx, `
+        SELECT h.id, h.name, h.wrapped_key FROM data_history h
+        JOIN rotations r ON r.user_id = h.user_id
+        LEFT JOIN rotation_history rh ON rh.user_id = h.user_id AND rh.history_id = h.id
+        WHERE h.user_id = $1 AND h.wrapped_key <> '' AND rh.history_id IS NULL
+        ORDER BY h.id
+    `, userID)
+	if err != nil {
+		slog.Error("Failed to query history keys",
+			"user_id", userID,
+			"error", err,
+			"method", "RotationHistory")
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []entities.HistoryKey{}
+	for rows.Next() {
+		var k entities.HistoryKey
+		if err = rows.Scan(&k.ID, &k.Record, &k.WrappedKey); err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+
+	return keys, rows.Err()
+}
+
+// StageHistoryKeys сохраняет пачку перешифрованных ключей версий. Версии,
+// которой нет у пользователя, отклоняются с ErrVersionNotFound.
+func (db *Database) StageHistoryKeys(ctx context.Context, userID int, keys []entities.HistoryKey) error {
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		slog.Error("Failed to begin transaction",
+			"error", err,
+			"method", "StageHistoryKeys")
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	err = tx.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM rotations WHERE user_id = $1)", userID).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return entities.ErrRotationNotFound
+	}
+
+	for _, k := range keys {
+		res, err := tx.ExecContext(ctx, `
+            INSERT INTO rotation_history (user_id, history_id, wrapped_key)
+            SELECT $1, h.id, $3 FROM data_history h
+            WHERE h.user_id = $1 AND h.id = $2
+            ON CONFLICT (user_id, history_id) DO UPDATE SET wrapped_key = EXCLUDED.wrapped_key
+        `, userID, k.ID, k.WrappedKey)
+		if err != nil {
+			slog.Error("Failed to stage history key",
+				"user_id", userID,
+				"version", k.ID,
+				"error", err,
+				"method", "StageHistoryKeys")
+			return fmt.Errorf("failed to stage history key: %w", err)
+		}
+
+		if n, _ := res.RowsAffected(); n == 0 {
+			return fmt.Errorf("%w: %d", entities.ErrVersionNotFound, k.ID)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// CommitRotation заменяет записи перешифрованными и устанавливает новое
+// хранилище одной транзакцией. Обёртка ключом восстановления относится к
+// старому ключу и сбрасывается, закрытый ключ X25519 берётся перешифрованным.
+// Записей старых форматов после смены не остаётся, их отметка тоже сбрасывается.
+// Записи в корзине тоже перешифровываются, их слепой индекс остаётся скрытым.
+// Ключи данных версий в истории заменяются перешифрованными: иначе старый
+// ключ хранилища открывал бы по ним и текущие записи с тем же ключом данных.
+// Если перешифрованы не все записи или ключи версий — ErrRotationIncomplete,
+// смена остаётся открытой.
+func (db *Database) CommitRotation(ctx context.Context, userID int) error {
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		slog.Error("Failed to begin transaction",
+			"error", err,
+			"method", "CommitRotation")
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = lockDataWrite(ctx, tx, userID)
+	if err == nil {
+		return entities.ErrRotationNotFound
+	}
+	if !errors.Is(err, entities.ErrRotationInProgress) {
+		return err
+	}
+
+	var missing int
+	err = tx.QueryRowContext(ctx, `
+        SELECT COUNT(*) FROM data d
+        LEFT JOIN rotation_records r ON r.user_id = d.user_id AND r.name = d.name
+        WHERE d.user_id = $1 AND r.name IS NULL
+    `, userID).Scan(&missing)
+	if err != nil {
+		return err
+	}
+	if missing > 0 {
+		slog.Warn("Rotation commit with missing records",
+			"user_id", userID,
+			"missing", missing,
+			"method", "CommitRotation")
+		return fmt.Errorf("%w: осталось %d", entities.ErrRotationIncomplete, missing)
+	}
+
+	err = tx.QueryRowContext(ctx, `
+        SELECT COUNT(*) FROM data_history h
+        LEFT JOIN rotation_history r ON r.user_id = h.user_id AND r.history_id = h.id
+        WHERE h.user_id = $1 AND h.wrapped_key <> '' AND r.history_id IS NULL
+    `, userID).Scan(&missing)
+	if err != nil {
+		return err
+	}
+	if missing > 0 {
+		slog.Warn("Rotation commit with missing history keys",
+			"user_id", userID,
+			"missing", missing,
+			"method", "CommitRotation")
+		return fmt.Errorf("%w: осталось ключей версий %d", entities.ErrRotationIncomplete, missing)
+	}
+
+	queries := []string{`
+        UPDATE data d SET
+            value = r.value,
+            value_kid = r.value_kid,
+            wrapped_key = r.wrapped_key,
+            blind_index = CASE WHEN d.deleted_at IS NULL THEN NULLIF(r.blind_index, '') END,
+            deleted_index = CASE WHEN d.deleted_at IS NOT NULL THEN NULLIF(r.blind_index, '') END,
+            updated_at = CURRENT_TIMESTAMP
+        FROM rotation_records r
+        WHERE r.user_id = d.user_id AND r.name = d.name AND d.user_id = $1
+    `, `
+        UPDATE data_history h SET wrapped_key = r.wrapped_key
+        FROM rotation_history r
+        WHERE r.user_id = h.user_id AND r.history_id = h.id AND h.user_id = $1
+    `, `
+        UPDATE vaults v SET
+            kdf_version  = r.kdf_version,
+            kdf_salt     = r.kdf_salt,
+            kdf_time     = r.kdf_time,
+            kdf_memory   = r.kdf_memory,
+            kdf_threads  = r.kdf_threads,
+            wrapped_key  = r.wrapped_key,
+            key_id       = r.key_id,
+            recovery_key = NULL,
+            legacy_tag   = NULL,
+            private_key  = r.private_key,
+            public_key   = CASE WHEN r.private_key IS NULL THEN NULL ELSE v.public_key END,
+            updated_at   = CURRENT_TIMESTAMP
+        FROM rotations r
+        WHERE r.user_id = v.user_id AND v.user_id = $1
+    `,
+		"DELETE FROM rotations WHERE user_id = $1",
+		"UPDATE users SET update_id = update_id + 1 WHERE id = $1",
+	}
+	for _, query := range queries {
+		if _, err = tx.ExecContext(ctx, query, userID); err != nil {
+			slog.Error("Failed to commit rotation",
+				"user_id", userID,
+				"error", err,
+				"method", "CommitRotation")
+			return fmt.Errorf("failed to commit rotation: %w", err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		slog.Error("Failed to commit transaction",
+			"error", err,
+			"method", "CommitRotation")
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// AbortRotation удаляет незавершённую смену ключа вместе с перешифрованными записями.
+func (db *Database) AbortRotation(ctx context.Context, userID int) error {
+	res, err := db.conn.ExecContext(ctx, "DELETE FROM rotations WHERE user_id = $1", userID)
+	if err != nil {
+		slog.Error("Failed to abort rotation",
+			"user_id", userID,
+			"error", err,
+			"method", "AbortRotation")
+		return fmt.Errorf("failed to abort rotation: %w", err)
+	}
+
+	if n, _ := res.RowsAffected(); n == 0 {
+		return entities.ErrRotationNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,109 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"GophKeeper.ru/internal/entities"
+)
+
+func TestCommitRotationRejectsIncompleteStaging(t *testing.T) {
+	db := newTestDatabase(t)
+	ctx := context.Background()
+	userID := newTestUser(t, db, "alice")
+
+	putRecord(t, db, userID, entities.Record{Key: "a", Value: "a1", WrappedKey: "old-a"})
+	putRecord(t, db, userID, entities.Record{Key: "a", Value: "a2", WrappedKey: "old-a"})
+	putRecord(t, db, userID, entities.Record{Key: "b", Value: "b1", WrappedKey: "old-b"})
+
+	vault := entities.Vault{
+		KDF:        entities.KDFParams{Version: 1, Salt: "salt", Time: 1, Memory: 64, Threads: 1},
+		WrappedKey: "new-vault",
+		KeyID:      "new-id",
+	}
+	if _, err := db.BeginRotation(ctx, userID, &vault); err != nil {
+		t.Fatalf("begin rotation: %v", err)
+	}
+
+	if err := db.StageRotation(ctx, userID, []entities.Record{{Key: "a", Value: "a2*", WrappedKey: "new-a"}}); err != nil {
+		t.Fatalf("stage a: %v", err)
+	}
+	if err := db.CommitRotation(ctx, userID); !errors.Is(err, entities.ErrRotationIncomplete) {
+		t.Fatalf("commit without b: %v, want ErrRotationIncomplete", err)
+	}
+
+	if err := db.StageRotation(ctx, userID, []entities.Record{{Key: "b", Value: "b1*", WrappedKey: "new-b"}}); err != nil {
+		t.Fatalf("stage b: %v", err)
+	}
+	if err := db.CommitRotation(ctx, userID); !errors.Is(err, entities.ErrRotationIncomplete) {
+		t.Fatalf("commit without history keys: %v, want ErrRotationIncomplete", err)
+	}
+
+	// Отказ не должен был изменить ни записи, ни смену ключа
+	if got := currentValues(t, db, userID)["a"]; got.Value != "a2" || got.WrappedKey != "old-a" {
+		t.Fatalf("record a after rejected commit: %+v", got)
+	}
+
+	keys, err := db.RotationHistory(ctx, userID)
+	if err != nil {
+		t.Fatalf("rotation history: %v", err)
+	}
+	if len(keys) != 1 || keys[0].Record != "a" || keys[0].WrappedKey != "old-a" {
+		t.Fatalf("history keys %+v, want one old key of a", keys)
+	}
+
+	keys[0].WrappedKey = "new-a"
+	if err = db.StageHistoryKeys(ctx, userID, keys); err != nil {
+		t.Fatalf("stage history keys: %v", err)
+	}
+	if err = db.CommitRotation(ctx, userID); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+
+	records := currentValues(t, db, userID)
+	if records["a"].Value != "a2*" || records["b"].WrappedKey != "new-b" {
+		t.Fatalf("records after commit: %+v", records)
+	}
+
+	versions, err := db.History(ctx, userID, "a")
+	if err != nil {
+		t.Fatalf("history: %v", err)
+	}
+	if len(versions) != 1 || versions[0].WrappedKey != "new-a" {
+		t.Fatalf("history after commit: %+v, want key re-wrapped", versions)
+	}
+
+	if rot, err := db.Rotation(ctx, userID); err != nil || rot != nil {
+		t.Fatalf("rotation after commit: %+v, %v", rot, err)
+	}
+}
+
+func TestStageHistoryKeysRejectsForeignVersion(t *testing.T) {
+	db := newTestDatabase(t)
+	ctx := context.Background()
+	alice := newTestUser(t, db, "alice")
+	bob := newTestUser(t, db, "bob")
+
+	putRecord(t, db, bob, entities.Record{Key: "x", Value: "x1", WrappedKey: "bob-x"})
+	putRecord(t, db, bob, entities.Record{Key: "x", Value: "x2", WrappedKey: "bob-x"})
+
+	versions, err := db.History(ctx, bob, "x")
+	if err != nil || len(versions) != 1 {
+		t.Fatalf("bob history: %+v, %v", versions, err)
+	}
+
+	vault := entities.Vault{
+		KDF:        entities.KDFParams{Version: 1, Salt: "salt", Time: 1, Memory: 64, Threads: 1},
+		WrappedKey: "new-vault",
+		KeyID:      "new-id",
+	}
+	if _, err = db.BeginRotation(ctx, alice, &vault); err != nil {
+		t.Fatalf("begin rotation: %v", err)
+	}
+
+	err = db.StageHistoryKeys(ctx, alice, []entities.HistoryKey{{ID: versions[0].ID, WrappedKey: "stolen"}})
+	if !errors.Is(err, entities.ErrVersionNotFound) {
+		t.Fatalf("stage foreign version: %v, want ErrVersionNotFound", err)
+	}
+}
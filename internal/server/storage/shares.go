@@ -0,0 +1,165 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"GophKeeper.ru/internal/entities"
+)
+
+// PublicKey возвращает открытый ключ X25519 пользователя по логину.
+func (db *Database) PublicKey(ctx context.Context, login string) (string, error) {
+	var key sql.NullString
+	err := db.conn.QueryRowContext(ctx, `
+        SELECT v.public_key FROM users u
+        LEFT JOIN vaults v ON v.user_id = u.id
+        WHERE u.name = $1 AND NOT u.is_disable
+    `, login).Scan(&key)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", entities.ErrUserNotFound
+	}
+	if err != nil {
+		slog.Error("Failed to fetch public key",
+			"login", login,
+			"error", err,
+			"method", "PublicKey")
+		return "", err
+	}
+
+	return key.String, nil
+}
+
+// CreateShare выдаёт получателю доступ к записи владельца. Повторная выдача
+// заменяет зашифрованный ключ данных.
+func (db *Database) CreateShare(ctx context.Context, ownerID int, s *entities.Share) error {
+	var recipientID int
+	err := db.conn.QueryRowContext(ctx,
+		"SELECT id FROM users WHERE name = $1 AND NOT is_disable", s.Recipient).Scan(&recipientID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return entities.ErrUserNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if recipientID == ownerID {
+		return entities.ErrShareSelf
+	}
+
+	err = db.conn.QueryRowContext(ctx, `
+        INSERT INTO shares (owner_id, record_name, recipient_id, wrapped_key)
+        SELECT $1, d.name, $3, $4 FROM data d
+        WHERE d.user_id = $1 AND d.name = $2 AND d.deleted_at IS NULL
+        ON CONFLICT (owner_id, record_name, recipient_id) DO UPDATE SET
+            wrapped_key = EXCLUDED.wrapped_key,
+            created_at = CURRENT_TIMESTAMP
+        RETURNING id, created_at
+    `, ownerID, s.Record, recipientID, s.WrappedKey).Scan(&s.ID, &s.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return entities.ErrRecordNotFound
+	}
+	if err != nil {
+		slog.Error("Failed to create share",
+			"owner_id", ownerID,
+			"recipient_id", recipientID,
+			"error", err,
+			"method", "CreateShare")
+		return fmt.Errorf("failed to create share: %w", err)
+	}
+
+	return nil
+}
+
+// Shares возвращает доступы, выданные владельцем.
+func (db *Database) Shares(ctx context.Context, ownerID int) ([]entities.Share, error) {
+	rows, err := db.conn.QueryContext(ctx, `
+        SELECT s.id, s.record_name, u.name, s.created_at
+        FROM shares s JOIN users u ON u.id = s.recipient_id
+        WHERE s.owner_id = $1
+        ORDER BY s.id
+    `, ownerID)
+	if err != nil {
+		slog.Error("Failed to query shares",
+			"owner_id", ownerID,
+			"error", err,
+			"method", "Shares")
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []entities.Share{}
+	for rows.Next() {
+		var s entities.Share
+		if err = rows.Scan(&s.ID, &s.Record, &s.Recipient, &s.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+
+	return out, rows.Err()
+}
+
+// SharedWith возвращает записи, доступ к которым выдан пользователю,
+// вместе с их зашифрованными значениями.
+func (db *Database) SharedWith(ctx context.Context, recipientID int) ([]entities.Share, error) {
+	rows, err := db.conn.QueryContext(ctx, `
+        SELECT s.id, s.record_name, u.name, s.wrapped_key, s.created_at, d.user_id, d.value, d.value_kid
+        FROM shares s
+        JOIN users u ON u.id = s.owner_id
+        JOIN data d ON d.user_id = s.owner_id AND d.name = s.record_name
+        WHERE s.recipient_id = $1 AND NOT u.is_disable AND d.deleted_at IS NULL
+        ORDER BY s.id
+    `, recipientID)
+	if err != nil {
+		slog.Error("Failed to query shared records",
+			"recipient_id", recipientID,
+			"error", err,
+			"method", "SharedWith")
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []entities.Share{}
+	for rows.Next() {
+		var (
+			s       entities.Share
+			ownerID int
+			kid     sql.NullString
+		)
+		if err = rows.Scan(&s.ID, &s.Record, &s.Owner, &s.WrappedKey, &s.CreatedAt, &ownerID, &s.Value, &kid); err != nil {
+			return nil, err
+		}
+
+		if s.Value, err = db.openValue(ownerID, s.Record, kid, s.Value); err != nil {
+			slog.Error("Failed to decrypt shared value",
+				"share_id", s.ID,
+				"error", err,
+				"method", "SharedWith")
+			return nil, err
+		}
+		out = append(out, s)
+	}
+
+	return out, rows.Err()
+}
+
+// RevokeShare отзывает доступ, выданный владельцем.
+func (db *Database) RevokeShare(ctx context.Context, ownerID, id int) error {
+	res, err := db.conn.ExecContext(ctx, "DELETE FROM shares WHERE id = $1 AND owner_id = $2", id, ownerID)
+	if err != nil {
+		slog.Error("Failed to revoke share",
+			"owner_id", ownerID,
+			"share_id", id,
+			"error", err,
+			"method", "RevokeShare")
+		return fmt.Errorf("failed to revoke share: %w", err)
+	}
+
+	if n, _ := res.RowsAffected(); n == 0 {
+		return entities.ErrShareNotFound
+	}
+
+	return nil
+}
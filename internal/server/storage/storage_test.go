@@ -0,0 +1,120 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"GophKeeper.ru/internal/entities"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+// testDSNEnv адрес тестовой базы PostgreSQL. Без него тесты хранилища
+// пропускаются. Каждый тест работает в своей схеме и удаляет её после себя.
+const testDSNEnv = "GOPHKEEPER_TEST_DSN"
+
+// newTestDatabase открывает базу в новой схеме с применёнными миграциями
+func newTestDatabase(t *testing.T) *Database {
+	t.Helper()
+
+	dsn := os.Getenv(testDSNEnv)
+	if dsn == "" {
+		t.Skipf("%s не задан, тесты хранилища пропущены", testDSNEnv)
+	}
+
+	admin, err := sql.Open("pgx", dsn)
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	t.Cleanup(func() { admin.Close() })
+
+	schema := fmt.Sprintf("gk_test_%d", time.Now().UnixNano())
+	if _, err = admin.Exec("CREATE SCHEMA " + schema); err != nil {
+		t.Fatalf("create schema: %v", err)
+	}
+	t.Cleanup(func() {
+		if _, err := admin.Exec("DROP SCHEMA " + schema + " CASCADE"); err != nil {
+			t.Errorf("drop schema: %v", err)
+		}
+	})
+
+	cfg, err := pgx.ParseConfig(dsn)
+	if err != nil {
+		t.Fatalf("parse %s: %v", testDSNEnv, err)
+	}
+	cfg.RuntimeParams["search_path"] = schema
+
+	conn := stdlib.OpenDB(*cfg)
+	t.Cleanup(func() { conn.Close() })
+
+	migrate(t, conn)
+
+	return &Database{conn: conn}
+}
+
+// migrate применяет миграции из каталога migrations по порядку
+func migrate(t *testing.T, conn *sql.DB) {
+	t.Helper()
+
+	files, err := filepath.Glob(filepath.Join("migrations", "*.up.sql"))
+	if err != nil {
+		t.Fatalf("list migrations: %v", err)
+	}
+	sort.Strings(files)
+
+	for _, file := range files {
+		query, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("read %s: %v", file, err)
+		}
+		// Без параметров запрос идёт простым протоколом и может
+		// содержать несколько команд
+		if _, err = conn.Exec(string(query)); err != nil {
+			t.Fatalf("apply %s: %v", file, err)
+		}
+	}
+}
+
+// newTestUser создаёт пользователя и возвращает его ID
+func newTestUser(t *testing.T, db *Database, login string) int {
+	t.Helper()
+
+	user := entities.User{Login: login, Password: "hash"}
+	if err := db.CreateUser(context.Background(), &user); err != nil {
+		t.Fatalf("create user %s: %v", login, err)
+	}
+
+	return user.ID
+}
+
+// putRecord сохраняет запись и падает при ошибке
+func putRecord(t *testing.T, db *Database, userID int, r entities.Record) {
+	t.Helper()
+
+	if err := db.UpdateRecord(context.Background(), userID, r); err != nil {
+		t.Fatalf("update record %s: %v", r.Key, err)
+	}
+}
+
+// currentValues текущие значения записей пользователя по имени
+func currentValues(t *testing.T, db *Database, userID int) map[string]entities.Record {
+	t.Helper()
+
+	update, err := db.GetData(context.Background(), userID)
+	if err != nil {
+		t.Fatalf("get data: %v", err)
+	}
+
+	records := map[string]entities.Record{}
+	for _, r := range update.Data {
+		records[r.Key] = r
+	}
+
+	return records
+}
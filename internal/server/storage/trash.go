@@ -0,0 +1,243 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"GophKeeper.ru/internal/entities"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// purgeQuery окончательно удаляет записи из корзины, подходящие под условие,
+// вместе с историей и двоичными данными. Доступы удаляются каскадом.
+// Данные находятся по записи, к которой они загружены, или по ссылке из
+// записи: у загруженных до появления корзины записи нет.
+// Возвращает по строке на запись и её двоичные данные.
+const purgeQuery = `
+    WITH purged AS (
+        DELETE FROM data WHERE deleted_at IS NOT NULL AND %s
+        RETURNING user_id, name, blob_id
+    ), purged_history AS (
+        DELETE FROM data_history h USING purged p
+        WHERE h.user_id = p.user_id AND h.name = p.name
+    ), purged_blobs AS (
+        DELETE FROM blobs b USING purged p
+        WHERE b.user_id = p.user_id AND (b.record = p.name OR b.id = p.blob_id)
+        RETURNING b.id, b.user_id, b.record
+    )
+    SELECT p.user_id, p.name, b.id FROM purged p
+    LEFT JOIN purged_blobs b ON b.user_id = p.user_id AND (b.record = p.name OR b.id = p.blob_id)
+`
+
+// Trash возвращает записи пользователя из корзины.
+func (db *Database) Trash(ctx context.Context, userID int) ([]entities.TrashItem, error) {
+	rows, err := db.conn.QueryContext(ctx, `
+        SELECT name, value, value_kid, wrapped_key, deleted_index, COALESCE(blob_id, ''), deleted_at FROM data
+        WHERE user_id = $1 AND deleted_at IS NOT NULL
+        ORDER BY deleted_at DESC
+    `, userID)
+	if err != nil {
+		slog.Error("Failed to query trash",
+			"user_id", userID,
+			"error", err,
+			"method", "Trash")
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []entities.TrashItem{}
+	for rows.Next() {
+		var (
+			t     entities.TrashItem
+			kid   sql.NullString
+			index sql.NullString
+		)
+		if err = rows.Scan(&t.Key, &t.Value, &kid, &t.WrappedKey, &index, &t.Blob, &t.DeletedAt); err != nil {
+			return nil, err
+		}
+
+		if t.Value, err = db.openValue(userID, t.Key, kid, t.Value); err != nil {
+			slog.Error("Failed to decrypt value in trash",
+				"user_id", userID,
+				"kid", kid.String,
+				"error", err,
+				"method", "Trash")
+			return nil, err
+		}
+		t.Index = index.String
+		out = append(out, t)
+	}
+
+	return out, rows.Err()
+}
+
+// UndeleteRecord возвращает запись из корзины вместе с её слепым индексом.
+func (db *Database) UndeleteRecord(ctx context.Context, userID int, key string) (*entities.Record, error) {
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		slog.Error("Failed to begin transaction",
+			"error", err,
+			"method", "UndeleteRecord")
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err = lockDataWrite(ctx, tx, userID); err != nil {
+		return nil, err
+	}
+
+	var (
+		r     = entities.Record{Key: key}
+		kid   sql.NullString
+		index sql.NullString
+	)
+	err = tx.QueryRowContext(ctx, `
+        UPDATE data SET
+            blind_index = deleted_index,
+            deleted_index = NULL,
+            deleted_at = NULL,
+            updated_at = CURRENT_TIMESTAMP
+        WHERE user_id = $1 AND name = $2 AND deleted_at IS NOT NULL
+        RETURNING value, value_kid, wrapped_key, blind_index
+    `, userID, key).Scan(&r.Value, &kid, &r.WrappedKey, &index)
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == pgUniqueViolation {
+		return nil, entities.ErrRecordExists
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, entities.ErrRecordNotFound
+	}
+	if err != nil {
+		slog.Error("Failed to undelete record",
+			"user_id", userID,
+			"key", key,
+			"error", err,
+			"method", "UndeleteRecord")
+		return nil, fmt.Errorf("failed to undelete record: %w", err)
+	}
+	r.Index = index.String
+
+	if _, err = tx.ExecContext(ctx, "UPDATE users SET update_id = update_id + 1 WHERE id = $1", userID); err != nil {
+		return nil, fmt.Errorf("failed to update user's update_id: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		slog.Error("Failed to commit transaction",
+			"error", err,
+			"method", "UndeleteRecord")
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	if r.Value, err = db.openValue(userID, key, kid, r.Value); err != nil {
+		return nil, err
+	}
+
+	return &r, nil
+}
+
+// PurgeRecord окончательно удаляет запись из корзины.
+// Возвращает ID двоичных данных, фрагменты которых нужно удалить.
+func (db *Database) PurgeRecord(ctx context.Context, userID int, key string) ([]string, error) {
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		slog.Error("Failed to begin transaction",
+			"error", err,
+			"method", "PurgeRecord")
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err = lockDataWrite(ctx, tx, userID); err != nil {
+		return nil, err
+	}
+
+	records, blobs, err := purge(ctx, tx, "user_id = $1 AND name = $2", userID, key)
+	if err != nil {
+		slog.Error("Failed to purge record",
+			"user_id", userID,
+			"key", key,
+			"error", err,
+			"method", "PurgeRecord")
+		return nil, err
+	}
+	if records == 0 {
+		return nil, entities.ErrRecordNotFound
+	}
+
+	if err = tx.Commit(); err != nil {
+		slog.Error("Failed to commit transaction",
+			"error", err,
+			"method", "PurgeRecord")
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return blobs, nil
+}
+
+// PurgeTrash окончательно удаляет записи, пролежавшие в корзине дольше age.
+// Возвращает число удалённых записей и ID их двоичных данных.
+func (db *Database) PurgeTrash(ctx context.Context, age time.Duration) (int, []string, error) {
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		slog.Error("Failed to begin transaction",
+			"error", err,
+			"method", "PurgeTrash")
+		return 0, nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	records, blobs, err := purge(ctx, tx,
+		"deleted_at < CURRENT_TIMESTAMP - $1 * INTERVAL '1 second'", int64(age.Seconds()))
+	if err != nil {
+		slog.Error("Failed to purge trash",
+			"error", err,
+			"method", "PurgeTrash")
+		return 0, nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		slog.Error("Failed to commit transaction",
+			"error", err,
+			"method", "PurgeTrash")
+		return 0, nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return records, blobs, nil
+}
+
+// purge выполняет purgeQuery с условием cond. Условие задаёт только
+// вызывающий код этого пакета, значения передаются параметрами.
+func purge(ctx context.Context, tx *sql.Tx, cond string, args ...any) (int, []string, error) {
+	rows, err := tx.QueryContext(ctx, fmt.Sprintf(purgeQuery, cond), args...)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to purge records: %w", err)
+	}
+	defer rows.Close()
+
+	type record struct {
+		userID int
+		name   string
+	}
+	purged := map[record]bool{}
+	var blobs []string
+	for rows.Next() {
+		var (
+			r    record
+			blob sql.NullString
+		)
+		if err = rows.Scan(&r.userID, &r.name, &blob); err != nil {
+			return 0, nil, err
+		}
+		purged[r] = true
+		if blob.Valid {
+			blobs = append(blobs, blob.String)
+		}
+	}
+
+	return len(purged), blobs, rows.Err()
+}
@@ -0,0 +1,97 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"testing"
+	"time"
+
+	"GophKeeper.ru/internal/entities"
+)
+
+func TestPurgeRecordRemovesHistoryAndBlobs(t *testing.T) {
+	db := newTestDatabase(t)
+	ctx := context.Background()
+	userID := newTestUser(t, db, "alice")
+
+	// Файл заменён: прежний остаётся в истории, текущий — в записи
+	createBlob(t, db, userID, "old", "file", 10)
+	putRecord(t, db, userID, entities.Record{Key: "file", Value: "v0", Blob: "old"})
+	createBlob(t, db, userID, "new", "file", 10)
+	putRecord(t, db, userID, entities.Record{Key: "file", Value: "v1", Blob: "new"})
+	// Соседняя запись и её файл не затрагиваются
+	createBlob(t, db, userID, "keep", "other", 10)
+	putRecord(t, db, userID, entities.Record{Key: "other", Value: "o0", Blob: "keep"})
+	putRecord(t, db, userID, entities.Record{Key: "other", Value: "o1", Blob: "keep"})
+
+	if _, err := db.PurgeRecord(ctx, userID, "file"); !errors.Is(err, entities.ErrRecordNotFound) {
+		t.Fatalf("purge before delete: %v, want ErrRecordNotFound", err)
+	}
+
+	if err := db.RemoveRecord(ctx, userID, "file"); err != nil {
+		t.Fatalf("remove record: %v", err)
+	}
+	blobs, err := db.PurgeRecord(ctx, userID, "file")
+	if err != nil {
+		t.Fatalf("purge record: %v", err)
+	}
+	sort.Strings(blobs)
+	if len(blobs) != 2 || blobs[0] != "new" || blobs[1] != "old" {
+		t.Fatalf("purged blobs %v, want [new old]", blobs)
+	}
+
+	if versions, err := db.History(ctx, userID, "file"); err != nil || len(versions) != 0 {
+		t.Fatalf("history after purge: %+v, %v", versions, err)
+	}
+	for _, id := range blobs {
+		if _, err = db.Blob(ctx, userID, id); !errors.Is(err, entities.ErrBlobNotFound) {
+			t.Errorf("blob %s after purge: %v, want ErrBlobNotFound", id, err)
+		}
+	}
+	if trash, err := db.Trash(ctx, userID); err != nil || len(trash) != 0 {
+		t.Fatalf("trash after purge: %+v, %v", trash, err)
+	}
+
+	if versions, err := db.History(ctx, userID, "other"); err != nil || len(versions) != 1 {
+		t.Fatalf("history of other record: %+v, %v", versions, err)
+	}
+	if _, err = db.Blob(ctx, userID, "keep"); err != nil {
+		t.Fatalf("blob of other record: %v", err)
+	}
+}
+
+func TestPurgeTrashKeepsRecentRecords(t *testing.T) {
+	db := newTestDatabase(t)
+	ctx := context.Background()
+	userID := newTestUser(t, db, "alice")
+
+	putRecord(t, db, userID, entities.Record{Key: "old", Value: "v0"})
+	putRecord(t, db, userID, entities.Record{Key: "old", Value: "v1"})
+	putRecord(t, db, userID, entities.Record{Key: "recent", Value: "v0"})
+	for _, key := range []string{"old", "recent"} {
+		if err := db.RemoveRecord(ctx, userID, key); err != nil {
+			t.Fatalf("remove %s: %v", key, err)
+		}
+	}
+	_, err := db.conn.Exec("UPDATE data SET deleted_at = deleted_at - INTERVAL '2 days' WHERE name = 'old'")
+	if err != nil {
+		t.Fatalf("age record: %v", err)
+	}
+
+	n, _, err := db.PurgeTrash(ctx, 24*time.Hour)
+	if err != nil {
+		t.Fatalf("purge trash: %v", err)
+	}
+	if n != 1 {
+		t.Fatalf("purged %d records, want 1", n)
+	}
+
+	trash, err := db.Trash(ctx, userID)
+	if err != nil || len(trash) != 1 || trash[0].Key != "recent" {
+		t.Fatalf("trash after purge: %+v, %v", trash, err)
+	}
+	if versions, err := db.History(ctx, userID, "old"); err != nil || len(versions) != 0 {
+		t.Fatalf("history of purged record: %+v, %v", versions, err)
+	}
+}
@@ -0,0 +1,176 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"GophKeeper.ru/internal/entities"
+)
+
+// TwoFactor возвращает состояние TOTP пользователя.
+func (db *Database) TwoFactor(ctx context.Context, userID int) (*entities.TwoFactor, error) {
+	var (
+		tf     entities.TwoFactor
+		secret sql.NullString
+	)
+	err := db.conn.QueryRowContext(ctx,
+		"SELECT totp_secret, totp_enabled, totp_last_step FROM users WHERE id = $1", userID).
+		Scan(&secret, &tf.Enabled, &tf.LastStep)
+	if err != nil {
+		slog.Error("Failed to fetch TOTP state",
+			"user_id", userID,
+			"error", err,
+			"method", "TwoFactor")
+		return nil, err
+	}
+	tf.Secret = secret.String
+
+	return &tf, nil
+}
+
+// SetTOTPSecret сохраняет секрет, ожидающий подтверждения. Включённую 2FA не трогает.
+func (db *Database) SetTOTPSecret(ctx context.Context, userID int, secret string) error {
+	_, err := db.conn.ExecContext(ctx, `
+        UPDATE users SET totp_secret = $1
+        WHERE id = $2 AND NOT totp_enabled
+    `, secret, userID)
+	if err != nil {
+		slog.Error("Failed to set TOTP secret",
+			"user_id", userID,
+			"error", err,
+			"method", "SetTOTPSecret")
+		return fmt.Errorf("failed to set totp secret: %w", err)
+	}
+
+	return nil
+}
+
+// EnableTOTP включает 2FA и сохраняет хэши новых кодов восстановления.
+func (db *Database) EnableTOTP(ctx context.Context, userID int, step int64, codeHashes []string) error {
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		slog.Error("Failed to begin transaction",
+			"error", err,
+			"method", "EnableTOTP")
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+        UPDATE users SET totp_enabled = TRUE, totp_last_step = $1
+        WHERE id = $2
+    `, step, userID)
+	if err != nil {
+		slog.Error("Failed to enable TOTP",
+			"user_id", userID,
+			"error", err,
+			"method", "EnableTOTP")
+		return fmt.Errorf("failed to enable totp: %w", err)
+	}
+
+	if _, err = tx.ExecContext(ctx, "DELETE FROM recovery_codes WHERE user_id = $1", userID); err != nil {
+		slog.Error("Failed to delete recovery codes",
+			"user_id", userID,
+			"error", err,
+			"method", "EnableTOTP")
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+
+	for _, hash := range codeHashes {
+		_, err = tx.ExecContext(ctx,
+			"INSERT INTO recovery_codes (user_id, code_hash) VALUES ($1, $2)", userID, hash)
+		if err != nil {
+			slog.Error("Failed to store recovery code",
+				"user_id", userID,
+				"error", err,
+				"method", "EnableTOTP")
+			return fmt.Errorf("failed to store recovery code: %w", err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		slog.Error("Failed to commit transaction",
+			"error", err,
+			"method", "EnableTOTP")
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// UseTOTPStep запоминает последний принятый шаг TOTP,
+// чтобы один и тот же код нельзя было предъявить дважды.
+func (db *Database) UseTOTPStep(ctx context.Context, userID int, step int64) (bool, error) {
+	res, err := db.conn.ExecContext(ctx, `
+        UPDATE users SET totp_last_step = $1
+        WHERE id = $2 AND totp_last_step < $1
+    `, step, userID)
+	if err != nil {
+		slog.Error("Failed to update TOTP step",
+			"user_id", userID,
+			"error", err,
+			"method", "UseTOTPStep")
+		return false, fmt.Errorf("failed to update totp step: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return n == 1, nil
+}
+
+// UseRecoveryCode гасит неиспользованный код восстановления.
+func (db *Database) UseRecoveryCode(ctx context.Context, userID int, hash string) (bool, error) {
+	res, err := db.conn.ExecContext(ctx, `
+        UPDATE recovery_codes SET used_at = CURRENT_TIMESTAMP
+        WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL
+    `, userID, hash)
+	if err != nil {
+		slog.Error("Failed to use recovery code",
+			"user_id", userID,
+			"error", err,
+			"method", "UseRecoveryCode")
+		return false, fmt.Errorf("failed to use recovery code: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return n == 1, nil
+}
+
+// UseMFAToken запоминает предъявленный токен второго шага входа до истечения
+// его срока, чтобы с одним токеном нельзя было перебирать коды.
+func (db *Database) UseMFAToken(ctx context.Context, id string, expires time.Time) (bool, error) {
+	if _, err := db.conn.ExecContext(ctx,
+		"DELETE FROM used_mfa_tokens WHERE expires_at < CURRENT_TIMESTAMP"); err != nil {
+		slog.Warn("Failed to delete expired 2FA tokens",
+			"error", err,
+			"method", "UseMFAToken")
+	}
+
+	res, err := db.conn.ExecContext(ctx, `
+        INSERT INTO used_mfa_tokens (id, expires_at) VALUES ($1, $2)
+        ON CONFLICT (id) DO NOTHING
+    `, id, expires)
+	if err != nil {
+		slog.Error("Failed to use 2FA token",
+			"error", err,
+			"method", "UseMFAToken")
+		return false, fmt.Errorf("failed to use 2fa token: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return n == 1, nil
+}
@@ -0,0 +1,122 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"GophKeeper.ru/internal/entities"
+)
+
+// Vault возвращает параметры KDF и зашифрованный ключ хранилища пользователя.
+func (db *Database) Vault(ctx context.Context, userID int) (*entities.Vault, error) {
+	var (
+		v               entities.Vault
+		recovery        sql.NullString
+		public, private sql.NullString
+		legacy          sql.NullString
+	)
+	err := db.conn.QueryRowContext(ctx, `
+        SELECT kdf_version, kdf_salt, kdf_time, kdf_memory, kdf_threads, wrapped_key, key_id,
+            recovery_key, public_key, private_key, legacy_tag
+        FROM vaults WHERE user_id = $1
+    `, userID).Scan(&v.KDF.Version, &v.KDF.Salt, &v.KDF.Time, &v.KDF.Memory, &v.KDF.Threads,
+		&v.WrappedKey, &v.KeyID, &recovery, &public, &private, &legacy)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		slog.Error("Failed to fetch vault",
+			"user_id", userID,
+			"error", err,
+			"method", "Vault")
+		return nil, err
+	}
+	v.RecoveryKey = recovery.String
+	v.PublicKey = public.String
+	v.PrivateKey = private.String
+	v.LegacyTag = legacy.String
+
+	return &v, nil
+}
+
+// SaveVault создаёт хранилище либо обновляет параметры KDF и обёртку ключа.
+// Замена самого ключа (другой key_id) отклоняется с ErrVaultKeyMismatch.
+// Отметку старых записей перешифровка не снимает, её сбрасывает смена ключа.
+func (db *Database) SaveVault(ctx context.Context, userID int, v *entities.Vault) error {
+	var keyID string
+	err := db.conn.QueryRowContext(ctx, `
+        INSERT INTO vaults (user_id, kdf_version, kdf_salt, kdf_time, kdf_memory, kdf_threads, wrapped_key, key_id, legacy_tag)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NULLIF($9, ''))
+        ON CONFLICT (user_id) DO UPDATE SET
+            kdf_version = EXCLUDED.kdf_version,
+            kdf_salt    = EXCLUDED.kdf_salt,
+            kdf_time    = EXCLUDED.kdf_time,
+            kdf_memory  = EXCLUDED.kdf_memory,
+            kdf_threads = EXCLUDED.kdf_threads,
+            wrapped_key = EXCLUDED.wrapped_key,
+            legacy_tag  = COALESCE(vaults.legacy_tag, EXCLUDED.legacy_tag),
+            updated_at  = CURRENT_TIMESTAMP
+        WHERE vaults.key_id = EXCLUDED.key_id
+        RETURNING key_id
+    `, userID, v.KDF.Version, v.KDF.Salt, v.KDF.Time, v.KDF.Memory, v.KDF.Threads,
+		v.WrappedKey, v.KeyID, v.LegacyTag).Scan(&keyID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return entities.ErrVaultKeyMismatch
+	}
+	if err != nil {
+		slog.Error("Failed to save vault",
+			"user_id", userID,
+			"error", err,
+			"method", "SaveVault")
+		return fmt.Errorf("failed to save vault: %w", err)
+	}
+
+	return nil
+}
+
+// SetRecoveryKey сохраняет ключ хранилища, зашифрованный ключом восстановления.
+// Если ключ хранилища уже другой — ErrVaultKeyMismatch.
+func (db *Database) SetRecoveryKey(ctx context.Context, userID int, keyID, wrapped string) error {
+	res, err := db.conn.ExecContext(ctx, `
+        UPDATE vaults SET recovery_key = $1, updated_at = CURRENT_TIMESTAMP
+        WHERE user_id = $2 AND key_id = $3
+    `, wrapped, userID, keyID)
+	if err != nil {
+		slog.Error("Failed to set recovery key",
+			"user_id", userID,
+			"error", err,
+			"method", "SetRecoveryKey")
+		return fmt.Errorf("failed to set recovery key: %w", err)
+	}
+
+	if n, _ := res.RowsAffected(); n == 0 {
+		return entities.ErrVaultKeyMismatch
+	}
+
+	return nil
+}
+
+// SetKeyPair сохраняет пару ключей X25519. Существующую пару не заменяет:
+// по открытому ключу уже могли выдать доступ к записям.
+func (db *Database) SetKeyPair(ctx context.Context, userID int, keyID, public, private string) error {
+	res, err := db.conn.ExecContext(ctx, `
+        UPDATE vaults SET public_key = $1, private_key = $2, updated_at = CURRENT_TIMESTAMP
+        WHERE user_id = $3 AND key_id = $4 AND public_key IS NULL
+    `, public, private, userID, keyID)
+	if err != nil {
+		slog.Error("Failed to set key pair",
+			"user_id", userID,
+			"error", err,
+			"method", "SetKeyPair")
+		return fmt.Errorf("failed to set key pair: %w", err)
+	}
+
+	if n, _ := res.RowsAffected(); n == 0 {
+		return entities.ErrVaultKeyMismatch
+	}
+
+	return nil
+}
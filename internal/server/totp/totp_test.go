@@ -0,0 +1,97 @@
+package totp
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret общий секрет тестовых векторов RFC 4226 и RFC 6238 (SHA-1)
+var rfcSecret = encoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestHOTPVectors(t *testing.T) {
+	// RFC 4226, приложение D
+	want := []string{"755224", "287082", "359152", "969429", "338314",
+		"254676", "287922", "162583", "399871", "520489"}
+
+	for counter, code := range want {
+		if got := hotp([]byte("12345678901234567890"), int64(counter)); got != code {
+			t.Errorf("counter %d: got %s, want %s", counter, got, code)
+		}
+	}
+}
+
+func TestValidateRFC6238Vectors(t *testing.T) {
+	// RFC 6238, приложение B: младшие 6 цифр восьмизначных кодов SHA-1
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+
+	for _, tt := range tests {
+		step, ok := Validate(tt.code, rfcSecret, time.Unix(tt.unix, 0))
+		if !ok {
+			t.Errorf("T=%d: code %s rejected", tt.unix, tt.code)
+			continue
+		}
+		if step != tt.unix/period {
+			t.Errorf("T=%d: step %d, want %d", tt.unix, step, tt.unix/period)
+		}
+	}
+}
+
+func TestValidateSkew(t *testing.T) {
+	at := time.Unix(1111111109, 0)
+
+	tests := []struct {
+		name  string
+		shift time.Duration
+		ok    bool
+	}{
+		{"same step", 0, true},
+		{"one step later", period * time.Second, true},
+		{"one step earlier", -period * time.Second, true},
+		{"two steps later", 2 * period * time.Second, false},
+		{"two steps earlier", -2 * period * time.Second, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, ok := Validate("081804", rfcSecret, at.Add(tt.shift)); ok != tt.ok {
+				t.Fatalf("ok %v, want %v", ok, tt.ok)
+			}
+		})
+	}
+}
+
+func TestValidateRejectsMalformed(t *testing.T) {
+	at := time.Unix(59, 0)
+
+	for _, tt := range []struct{ code, secret string }{
+		{"28708", rfcSecret},
+		{"2870820", rfcSecret},
+		{"287083", rfcSecret},
+		{"287082", "not base32!"},
+	} {
+		if _, ok := Validate(tt.code, tt.secret, at); ok {
+			t.Errorf("code %q secret %q accepted", tt.code, tt.secret)
+		}
+	}
+
+	// Секрет в нижнем регистре принимается
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, _ := encoding.DecodeString(secret)
+	code := hotp(key, at.Unix()/period)
+	if _, ok := Validate(code, strings.ToLower(secret), at); !ok {
+		t.Fatal("lower-case secret rejected")
+	}
+}
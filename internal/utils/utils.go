@@ -4,6 +4,7 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"errors"
+	"fmt"
 
 	"GophKeeper.ru/internal/entities"
 	"github.com/golang-jwt/jwt/v5"
@@ -15,19 +16,56 @@ func Sha256hash(value string) string {
 	return hex.EncodeToString(hash.Sum(nil))
 }
 
-func LoginFromToken(sToken, secretKey string) (int, error) {
+// LoginFromToken проверяет токен и возвращает ID пользователя
+func LoginFromToken(sToken string, cfg *entities.TokenConfig) (int, error) {
+	claims, err := ClaimsFromToken(sToken, cfg)
+	if err != nil {
+		return -1, err
+	}
+
+	return claims.IDUser, nil
+}
+
+// ClaimsFromToken проверяет access-токен
+func ClaimsFromToken(sToken string, cfg *entities.TokenConfig) (*entities.Claims, error) {
+	return PurposeClaimsFromToken(sToken, "", cfg)
+}
+
+// PurposeClaimsFromToken проверяет подпись, обязательные поля
+// и назначение токена (пустое для access-токена)
+func PurposeClaimsFromToken(sToken, purpose string, cfg *entities.TokenConfig) (*entities.Claims, error) {
 	claims := &entities.Claims{}
 	tkn, err := jwt.ParseWithClaims(sToken, claims, func(jwtKey *jwt.Token) (any, error) {
-		return []byte(secretKey), nil
-	})
+		kid, _ := jwtKey.Header["kid"].(string)
+		key, ok := cfg.Keys[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown key id %q", kid)
+		}
+		return key, nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(cfg.Issuer),
+		jwt.WithAudience(cfg.Audience),
+		jwt.WithIssuedAt(),
+		jwt.WithExpirationRequired(),
+	)
 
 	if err != nil {
-		return -1, err
+		return nil, err
 	}
 
 	if !tkn.Valid {
-		return -1, errors.New("no valid token")
+		return nil, errors.New("no valid token")
 	}
 
-	return claims.IDUser, nil
+	// Библиотека проверяет nbf и iat только при их наличии
+	if claims.NotBefore == nil || claims.IssuedAt == nil {
+		return nil, errors.New("token without nbf or iat")
+	}
+
+	if claims.Purpose != purpose {
+		return nil, fmt.Errorf("unexpected token purpose %q", claims.Purpose)
+	}
+
+	return claims, nil
 }